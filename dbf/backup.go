@@ -0,0 +1,111 @@
+package dbf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"Timelancer/shared"
+	"Timelancer/shared/tr"
+	"Timelancer/sqlite"
+)
+
+const (
+	BackupGenerations   = 7 // number of kept daily backups
+	backupDirName       = "backups"
+	backupSuffix        = ".sqlite"
+	backupDateLayout    = "2006-01-02"
+	backupCheckInterval = time.Hour
+)
+
+// BackupDir returns directory of the daily backups (created if needed).
+func BackupDir() string {
+	if appDir := shared.AppDir(); appDir != "" {
+		dir := filepath.Join(appDir, backupDirName)
+		if shared.CreateDirIfNeeded(dir) {
+			return dir
+		}
+	}
+	return ""
+}
+
+// StartBackups writes today's backup (if there is none yet) and then checks
+// every hour if a new day has begun. Returned function stops the scheduler.
+func StartBackups(db *sqlite.Database, dir string, generations int) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(backupCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := DailyBackup(db, dir, generations, time.Now()); err != nil {
+				tr.Error("backup failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// DailyBackup writes backup of db for the day of 'now' if it doesn't exist
+// and removes backups older than the last 'generations' ones.
+func DailyBackup(db *sqlite.Database, dir string, generations int, now time.Time) error {
+	filePath := filepath.Join(dir, backupFileName(now))
+	if !shared.ExistsFile(filePath) {
+		// a half written file must never look like a valid backup
+		tmpPath := filePath + ".tmp"
+		if err := db.Backup(tmpPath); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+		if err := os.Rename(tmpPath, filePath); err != nil {
+			return err
+		}
+	}
+	return rotateBackups(dir, generations)
+}
+
+// Backups returns paths of all backups in dir, the newest first.
+func Backups(dir string) []string {
+	pattern := filepath.Join(dir, shared.AppName+"-*"+backupSuffix)
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil
+	}
+	// names contain ISO dates, so alphabetical order is chronological
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files
+}
+
+func rotateBackups(dir string, generations int) error {
+	files := Backups(dir)
+	if generations < 1 || len(files) <= generations {
+		return nil
+	}
+	for _, filePath := range files[generations:] {
+		if err := os.Remove(filePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func backupFileName(t time.Time) string {
+	return shared.AppName + "-" + t.Format(backupDateLayout) + backupSuffix
+}
@@ -1,45 +1,44 @@
 package dbf
 
 import (
-	"GamerHash/shared"
-	"Timelancer/shared/tr"
-	"Timelancer/sqlite"
-)
+	"fmt"
 
-const (
-	scheme = `
-CREATE TABLE company
-(
-	id       INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-	shortcut TEXT NOT NULL COLLATE NOCASE UNIQUE,
-	name     TEXT NOT NULL COLLATE NOCASE UNIQUE,
-	used     INTEGER NOT NULL CHECK(used==0 OR used==1) DEFAULT 1
-);
-CREATE TABLE timer
-(
-	id         INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-	company_id INTEGER NOT NULL,
-	start      INTEGER NOT NULL,
-	finish     INTEGER NOT NULL,
-	FOREIGN KEY (company_id) REFERENCES company(id)
-)
-`
+	"Timelancer/sqlite"
 )
 
-var db *sqlite.Database = sqlite.SQLite()
+// OpenOrCreate opens the application database (creating it if needed)
+// and migrates it to the current scheme. opts may be nil (defaults),
+// the Create option is always set and foreign keys are always enforced.
+// Fails with sqlite.ErrForeignKeys if they are not.
+func OpenOrCreate(filePath string, opts *sqlite.Options) (*sqlite.Database, error) {
+	options := sqlite.Options{}
+	if opts != nil {
+		options = *opts
+	}
+	options.Create = true
+	pragmas := sqlite.DefaultPragmas()
+	if options.Pragmas != nil {
+		*pragmas = *options.Pragmas
+	}
+	pragmas.ForeignKeys = true
+	options.Pragmas = pragmas
 
-func OpenOrCreate(filePath string) bool {
-	if shared.FileExists(filePath) {
-		if db.Open(filePath) {
-			return true
+	db, err := sqlite.Open(filePath, &options)
+	if err != nil {
+		return nil, fmt.Errorf("can't open database %s: %w", filePath, err)
+	}
+	// checked again, the storage must not run without them
+	if p, err := db.Pragmas(); err != nil || !p.ForeignKeys {
+		db.Close()
+		if err == nil {
+			err = sqlite.ErrForeignKeys
 		}
-		tr.Error("can't open database: %v", filePath)
-		return false
+		return nil, fmt.Errorf("can't open database %s: %w", filePath, err)
 	}
 
-	if db.Create(filePath, scheme) {
-		return true
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
 	}
-	tr.Error("can't create database: %v", filePath)
-	return false
+	return db, nil
 }
@@ -0,0 +1,189 @@
+package dbf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"Timelancer/shared"
+	"Timelancer/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func version(t *testing.T, db *sqlite.Database) int {
+	version, err := db.UserVersion()
+	assert.Nil(t, err)
+	return version
+}
+
+func count(t *testing.T, db *sqlite.Database, table string) int64 {
+	n, err := db.Count(table)
+	assert.Nil(t, err)
+	return n
+}
+
+func Test_OpenOrCreateNewDatabase(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "new.sqlite")
+
+	db, err := OpenOrCreate(filePath, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, SchemeVersion(), version(t, db))
+	assert.Equal(t, int64(0), count(t, db, "company"))
+	assert.Equal(t, int64(0), count(t, db, "timer"))
+	db.Close()
+
+	// second open must not apply anything
+	db, err = OpenOrCreate(filePath, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, SchemeVersion(), version(t, db))
+	db.Close()
+}
+
+func Test_OpenOrCreateLegacyDatabase(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "legacy.sqlite")
+
+	// database created before migrations (user_version 0, scheme present)
+	legacy := sqlite.New()
+	assert.Nil(t, legacy.Create(filePath, migrations[0].query))
+	assert.Nil(t, legacy.ExecQuery("INSERT INTO company (shortcut, name) VALUES ('ACME', 'Acme')"))
+	assert.Nil(t, legacy.ExecQuery("INSERT INTO timer (company_id, start, finish) VALUES (1, 100, 200)"))
+	legacy.Close()
+
+	db, err := OpenOrCreate(filePath, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, SchemeVersion(), version(t, db))
+	assert.Equal(t, int64(1), count(t, db, "company"))
+
+	// the timer was moved to the default project of its company
+	n, err := db.CountWhere("timer", "project_id=(SELECT id FROM project WHERE company_id=1 AND code='DEFAULT')")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	db.Close()
+}
+
+func Test_OpenOrCreateEnforcesForeignKeys(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "db.sqlite")
+
+	db, err := OpenOrCreate(filePath, &sqlite.Options{Pragmas: &sqlite.Pragmas{ForeignKeys: false}})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer db.Close()
+	err = db.ExecQuery("INSERT INTO timer (company_id, start, finish) VALUES (7, 100, 200)")
+	assert.True(t, sqlite.IsForeignKeyViolation(err))
+}
+
+func Test_OpenOrCreateNewerDatabase(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "newer.sqlite")
+
+	newer := sqlite.New()
+	assert.Nil(t, newer.Create(filePath, ""))
+	assert.Nil(t, newer.SetUserVersion(SchemeVersion()+1))
+	newer.Close()
+
+	_, err := OpenOrCreate(filePath, nil)
+	assert.ErrorIs(t, err, ErrNewerDatabase)
+}
+
+func Test_DailyBackup(t *testing.T) {
+	db, err := OpenOrCreate(filepath.Join(t.TempDir(), "db.sqlite"), nil)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	dir := t.TempDir()
+	day := time.Date(2020, 1, 30, 12, 0, 0, 0, time.Local)
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, DailyBackup(db, dir, 3, day.AddDate(0, 0, i)))
+		// second backup the same day does nothing
+		assert.Nil(t, DailyBackup(db, dir, 3, day.AddDate(0, 0, i)))
+	}
+
+	var names []string
+	for _, filePath := range Backups(dir) {
+		names = append(names, filepath.Base(filePath))
+	}
+	assert.Equal(t, []string{
+		"timelancer-2020-02-03.sqlite",
+		"timelancer-2020-02-02.sqlite",
+		"timelancer-2020-02-01.sqlite",
+	}, names)
+}
+
+// newDatabase creates a migrated database and runs the query with
+// foreign keys not enforced (so invalid rows can be written).
+func newDatabase(t *testing.T, filePath, query string) {
+	db, err := OpenOrCreate(filePath, &sqlite.Options{Pragmas: &sqlite.Pragmas{JournalMode: "DELETE"}})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, db.Close())
+
+	db, err = sqlite.Open(filePath, &sqlite.Options{Pragmas: &sqlite.Pragmas{}})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, db.ExecQuery(query))
+	assert.Nil(t, db.Close())
+}
+
+func Test_CheckHealth(t *testing.T) {
+	dir := t.TempDir()
+
+	report := CheckHealth(filepath.Join(dir, "none.sqlite"))
+	assert.True(t, report.Healthy())
+
+	healthy := filepath.Join(dir, "healthy.sqlite")
+	newDatabase(t, healthy, `INSERT INTO company (shortcut, name) VALUES ('ACME', 'Acme');
+		INSERT INTO timer (company_id, start, finish) VALUES (1, 100, 200);`)
+	report = CheckHealth(healthy)
+	assert.True(t, report.Healthy(), report.String())
+
+	invalid := filepath.Join(dir, "invalid.sqlite")
+	newDatabase(t, invalid, `INSERT INTO company (shortcut, name) VALUES ('ACME', 'Acme');
+		INSERT INTO timer (company_id, start, finish) VALUES (1, 200, 200);
+		INSERT INTO timer (company_id, start, finish) VALUES (7, 100, 200);`)
+	assert.Equal(t, []string{
+		"foreign key: timer 2: missing row in company",
+		"timer 1: finish is not after start",
+	}, CheckHealth(invalid).Problems)
+
+	truncated := filepath.Join(dir, "truncated.sqlite")
+	assert.Nil(t, os.WriteFile(truncated, []byte("SQLite format"), 0600))
+	report = CheckHealth(truncated)
+	if assert.Len(t, report.Problems, 1) {
+		assert.Contains(t, report.Problems[0], sqlite.ErrInvalidHeader.Error())
+	}
+}
+
+func Test_QuarantineAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	backupDir := t.TempDir()
+	now := time.Date(2020, 1, 30, 12, 0, 0, 0, time.Local)
+
+	filePath := filepath.Join(dir, "db.sqlite")
+	assert.Nil(t, os.WriteFile(filePath, []byte("not a database"), 0600))
+
+	_, err := RestoreNewestBackup(filePath, backupDir, now)
+	assert.ErrorIs(t, err, ErrNoHealthyBackup)
+
+	// the newest backup is damaged, the older one is used
+	older := filepath.Join(backupDir, backupFileName(now.AddDate(0, 0, -2)))
+	newDatabase(t, older, "INSERT INTO company (shortcut, name) VALUES ('ACME', 'Acme')")
+	assert.Nil(t, os.WriteFile(filepath.Join(backupDir, backupFileName(now.AddDate(0, 0, -1))), []byte("damaged"), 0600))
+
+	backupPath, err := RestoreNewestBackup(filePath, backupDir, now)
+	assert.Nil(t, err)
+	assert.Equal(t, older, backupPath)
+	assert.True(t, CheckHealth(filePath).Healthy())
+
+	quarantined := filePath + ".damaged-20200130-120000"
+	data, err := os.ReadFile(quarantined)
+	assert.Nil(t, err)
+	assert.Equal(t, "not a database", string(data))
+
+	path, err := Quarantine(filePath, now.Add(time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, filePath+".damaged-20200130-120001", path)
+	assert.False(t, shared.ExistsFile(filePath))
+}
@@ -0,0 +1,153 @@
+package dbf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"Timelancer/sqlite"
+	"Timelancer/sqlite/row"
+)
+
+const quarantineLayout = "20060102-150405"
+
+var ErrNoHealthyBackup = errors.New("there is no healthy backup")
+
+// Report is the result of the database health check.
+type Report struct {
+	FilePath string
+	Problems []string
+}
+
+func (r *Report) Healthy() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *Report) String() string {
+	if r.Healthy() {
+		return fmt.Sprintf("database %s is healthy", r.FilePath)
+	}
+	return fmt.Sprintf("database %s has problems:\n%s", r.FilePath, strings.Join(r.Problems, "\n"))
+}
+
+// CheckHealth validates the header of the database file, runs SQLite
+// integrity and foreign key checks and looks for invalid timers.
+// A missing file is healthy (a new database will be created).
+func CheckHealth(filePath string) *Report {
+	report := &Report{FilePath: filePath}
+	if err := sqlite.CheckHeader(filePath); err != nil {
+		if !errors.Is(err, sqlite.ErrNotExists) {
+			report.add("header: %v", err)
+		}
+		return report
+	}
+
+	db, err := sqlite.Open(filePath, &sqlite.Options{ReadOnly: true})
+	if err != nil {
+		report.add("can't open: %v", err)
+		return report
+	}
+	defer db.Close()
+
+	problems, err := db.IntegrityCheck()
+	if err != nil {
+		report.add("integrity check failed: %v", err)
+		// there is no point in reading a damaged database
+		return report
+	}
+	for _, problem := range problems {
+		report.add("integrity: %s", problem)
+	}
+
+	if violations, err := db.ForeignKeyCheck(); err != nil {
+		report.add("foreign key check failed: %v", err)
+	} else {
+		for _, v := range violations {
+			report.add("foreign key: %s", v)
+		}
+	}
+
+	err = db.SelectAndHandle("SELECT id FROM timer WHERE finish<=start ORDER BY id", func(r row.Row) {
+		if f := r.At(0); f != nil {
+			id, _ := f.Int64()
+			report.add("timer %d: finish is not after start", id)
+		}
+	})
+	if err != nil {
+		report.add("timers check failed: %v", err)
+	}
+	return report
+}
+
+// Quarantine moves the database (with its WAL files) aside, so a new one
+// can be created in its place. Returns the new path of the database.
+func Quarantine(filePath string, now time.Time) (string, error) {
+	quarantinePath := filePath + ".damaged-" + now.Format(quarantineLayout)
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Rename(filePath+suffix, quarantinePath+suffix); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	if err := os.Rename(filePath, quarantinePath); err != nil {
+		return "", err
+	}
+	return quarantinePath, nil
+}
+
+// RestoreNewestBackup quarantines the database and puts a copy of the newest
+// healthy backup from dir in its place. Returns path of the used backup.
+func RestoreNewestBackup(filePath, dir string, now time.Time) (string, error) {
+	backupPath := ""
+	for _, path := range Backups(dir) {
+		if CheckHealth(path).Healthy() {
+			backupPath = path
+			break
+		}
+	}
+	if backupPath == "" {
+		return "", ErrNoHealthyBackup
+	}
+
+	if _, err := Quarantine(filePath, now); err != nil {
+		return "", err
+	}
+	// a half copied file must never look like a restored database
+	tmpPath := filePath + ".tmp"
+	if err := copyFile(tmpPath, backupPath); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+func (r *Report) add(format string, a ...interface{}) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, a...))
+}
+
+func copyFile(dest, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
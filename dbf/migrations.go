@@ -0,0 +1,189 @@
+package dbf
+
+import (
+	"errors"
+	"fmt"
+
+	"Timelancer/sqlite"
+)
+
+var ErrNewerDatabase = errors.New("database is newer than application")
+
+type migration struct {
+	version int
+	query   string
+}
+
+// Ordered list of scheme changes. Number of the last one is the scheme
+// version of this binary. Never edit a released migration, append a new one.
+var migrations = []migration{
+	{
+		// Scheme of databases created before versioning was introduced,
+		// hence 'IF NOT EXISTS'.
+		version: 1,
+		query: `
+CREATE TABLE IF NOT EXISTS company
+(
+	id       INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	shortcut TEXT NOT NULL COLLATE NOCASE UNIQUE,
+	name     TEXT NOT NULL COLLATE NOCASE UNIQUE,
+	used     INTEGER NOT NULL CHECK(used==0 OR used==1) DEFAULT 1
+);
+CREATE TABLE IF NOT EXISTS timer
+(
+	id         INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	company_id INTEGER NOT NULL,
+	start      INTEGER NOT NULL,
+	finish     INTEGER NOT NULL,
+	FOREIGN KEY (company_id) REFERENCES company(id)
+);
+`,
+	},
+	{
+		// what was done in the working time
+		version: 2,
+		query: `
+ALTER TABLE timer ADD COLUMN description TEXT NOT NULL DEFAULT '';
+`,
+	},
+	{
+		// labels of timers, independent of companies
+		version: 3,
+		query: `
+CREATE TABLE tag
+(
+	id   INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL COLLATE NOCASE UNIQUE
+);
+CREATE TABLE timer_tag
+(
+	timer_id INTEGER NOT NULL,
+	tag_id   INTEGER NOT NULL,
+	PRIMARY KEY (timer_id, tag_id),
+	FOREIGN KEY (timer_id) REFERENCES timer(id) ON DELETE CASCADE,
+	FOREIGN KEY (tag_id) REFERENCES tag(id) ON DELETE CASCADE
+);
+CREATE INDEX timer_tag_tag_id ON timer_tag(tag_id);
+`,
+	},
+	{
+		// engagements of companies, existing timers get the default project
+		// of their company (see project.DefaultName and project.DefaultCode)
+		version: 4,
+		query: `
+CREATE TABLE project
+(
+	id           INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	company_id   INTEGER NOT NULL,
+	name         TEXT NOT NULL COLLATE NOCASE,
+	code         TEXT NOT NULL COLLATE NOCASE,
+	active       INTEGER NOT NULL CHECK(active==0 OR active==1) DEFAULT 1,
+	budget_hours REAL NOT NULL CHECK(budget_hours>=0) DEFAULT 0,
+	UNIQUE (company_id, name),
+	UNIQUE (company_id, code),
+	FOREIGN KEY (company_id) REFERENCES company(id)
+);
+INSERT INTO project (company_id, name, code) SELECT id, 'default', 'DEFAULT' FROM company;
+ALTER TABLE timer ADD COLUMN project_id INTEGER REFERENCES project(id);
+UPDATE timer SET project_id=(SELECT id FROM project WHERE project.company_id=timer.company_id);
+CREATE INDEX timer_project_id ON timer(project_id);
+`,
+	},
+	{
+		// hourly rates, amounts are in the currency of the company
+		version: 5,
+		query: `
+ALTER TABLE company ADD COLUMN currency TEXT NOT NULL DEFAULT 'EUR';
+CREATE TABLE rate
+(
+	id             INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	company_id     INTEGER NOT NULL,
+	project_id     INTEGER NOT NULL DEFAULT 0,
+	cents_per_hour INTEGER NOT NULL CHECK(cents_per_hour>=0),
+	valid_from     INTEGER NOT NULL DEFAULT 0,
+	valid_until    INTEGER NOT NULL DEFAULT 0,
+	FOREIGN KEY (company_id) REFERENCES company(id)
+);
+CREATE INDEX rate_company_id ON rate(company_id);
+`,
+	},
+	{
+		// invoices, seller and buyer are copied as they were at the issue,
+		// invoiced timers keep the invoice id (0 if not invoiced yet)
+		version: 6,
+		query: `
+ALTER TABLE company ADD COLUMN address TEXT NOT NULL DEFAULT '';
+ALTER TABLE company ADD COLUMN tax_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE company ADD COLUMN vat_percent REAL NOT NULL DEFAULT 0;
+CREATE TABLE invoice
+(
+	id             INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	year           INTEGER NOT NULL,
+	sequence       INTEGER NOT NULL,
+	issued         INTEGER NOT NULL,
+	company_id     INTEGER NOT NULL,
+	seller_name    TEXT NOT NULL,
+	seller_address TEXT NOT NULL,
+	seller_tax_id  TEXT NOT NULL,
+	buyer_name     TEXT NOT NULL,
+	buyer_address  TEXT NOT NULL,
+	buyer_tax_id   TEXT NOT NULL,
+	currency       TEXT NOT NULL,
+	vat_percent    REAL NOT NULL CHECK(vat_percent>=0),
+	UNIQUE (year, sequence),
+	FOREIGN KEY (company_id) REFERENCES company(id)
+);
+CREATE TABLE invoice_line
+(
+	invoice_id     INTEGER NOT NULL,
+	position       INTEGER NOT NULL,
+	description    TEXT NOT NULL,
+	seconds        INTEGER NOT NULL,
+	cents_per_hour INTEGER NOT NULL,
+	net_cents      INTEGER NOT NULL,
+	PRIMARY KEY (invoice_id, position),
+	FOREIGN KEY (invoice_id) REFERENCES invoice(id) ON DELETE CASCADE
+);
+ALTER TABLE timer ADD COLUMN invoice_id INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX timer_invoice_id ON timer(invoice_id);
+`,
+	},
+}
+
+func SchemeVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// Migrate applies (in one transaction) all migrations newer than
+// the database 'user_version'.
+func Migrate(db *sqlite.Database) error {
+	current, err := db.UserVersion()
+	if err != nil {
+		return fmt.Errorf("can't read database version: %w", err)
+	}
+	if current > SchemeVersion() {
+		return fmt.Errorf("%w (database: %d, application: %d)", ErrNewerDatabase, current, SchemeVersion())
+	}
+	if current == SchemeVersion() {
+		return nil
+	}
+
+	return db.WithTx(func(tx *sqlite.Tx) error {
+		for _, m := range migrations {
+			if m.version <= current {
+				continue
+			}
+			if err := applyMigration(tx, m); err != nil {
+				return fmt.Errorf("database migration to version %d failed: %w", m.version, err)
+			}
+		}
+		return nil
+	})
+}
+
+func applyMigration(tx *sqlite.Tx, m migration) error {
+	if err := tx.ExecQuery(m.query); err != nil {
+		return err
+	}
+	return tx.SetUserVersion(m.version)
+}
@@ -0,0 +1,22 @@
+package dbf
+
+import (
+	"time"
+
+	"Timelancer/shared/tr"
+	"Timelancer/sqlite"
+)
+
+// StartTrace logs (with tr) every statement run on db, statements running
+// threshold or longer are logged as warnings.
+func StartTrace(db *sqlite.Database, threshold time.Duration) error {
+	return db.SetTrace(threshold, logStatement)
+}
+
+func logStatement(e sqlite.TraceEvent) {
+	if e.Slow {
+		tr.Warning("slow query (%v, %d rows): %s", e.Duration, e.Rows, e.SQL)
+		return
+	}
+	tr.Info("query (%v, %d rows): %s", e.Duration, e.Rows, e.SQL)
+}
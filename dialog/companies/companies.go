@@ -32,23 +32,31 @@ import (
 	"strconv"
 
 	"Timelancer/dialog/company"
+	"Timelancer/dialog/projects"
+	"Timelancer/dialog/rates"
+	"Timelancer/dialog/ui"
 	companyData "Timelancer/model/company"
 
 	"Timelancer/shared/tr"
+	"Timelancer/storage"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 )
 
 const (
-	dialogTitle      = "all companies table"
-	cancelBtnText    = "return"
-	addBtnText       = "add new"
-	editBtnText      = "edit"
-	deleteBtnText    = "remove"
-	cancelBtnTooltip = "close this dialog"
-	addBtnTooltip    = "add new company"
-	editBtnTooltip   = "edit selected company"
-	deleteBtnTooltip = "remove selected company"
+	dialogTitle        = "all companies table"
+	cancelBtnText      = "return"
+	addBtnText         = "add new"
+	editBtnText        = "edit"
+	deleteBtnText      = "remove"
+	projectsBtnText    = "projects"
+	ratesBtnText       = "rates"
+	cancelBtnTooltip   = "close this dialog"
+	addBtnTooltip      = "add new company"
+	editBtnTooltip     = "edit selected company"
+	deleteBtnTooltip   = "remove selected company"
+	projectsBtnTooltip = "projects of selected company"
+	ratesBtnTooltip    = "hourly rates of selected company"
 
 	idColumnIdx       = 0
 	shortcutColumnIdx = 1
@@ -62,21 +70,25 @@ type Dialog struct {
 	addBtn      *gtk.Button
 	editBtn     *gtk.Button
 	deleteBtn   *gtk.Button
+	projectsBtn *gtk.Button
+	ratesBtn    *gtk.Button
 	scroll      *gtk.ScrolledWindow
 	treeView    *gtk.TreeView
 	listStore   *gtk.ListStore
 	parent      *gtk.Window
+	store       storage.Repositories
 	selectedRow int
+	unsubscribe func()
 }
 
-func New(parent *gtk.Window) *Dialog {
+func New(parent *gtk.Window, store storage.Repositories) *Dialog {
 	if dialog, err := gtk.DialogNew(); tr.IsOK(err) {
 		dialog.SetTransientFor(parent)
 		dialog.SetBorderWidth(6)
 		dialog.SetTitle(dialogTitle)
 		//dialog.SetSizeRequest(400, 200)
 
-		instance := &Dialog{self: dialog, parent: parent, selectedRow: -1}
+		instance := &Dialog{self: dialog, parent: parent, store: store, selectedRow: -1}
 
 		if contentArea, err := dialog.GetContentArea(); tr.IsOK(err) {
 			if buttonBox := instance.createButtons(); buttonBox != nil {
@@ -87,6 +99,7 @@ func New(parent *gtk.Window) *Dialog {
 						contentArea.PackEnd(separator, true, false, 1)
 						contentArea.PackEnd(instance.scroll, true, true, 1)
 
+						instance.unsubscribe = store.SubscribeCompanies(instance.companiesChanged)
 						return instance
 					}
 				}
@@ -106,12 +119,13 @@ func (d *Dialog) Run() gtk.ResponseType {
 }
 
 func (d *Dialog) Destroy() {
+	d.unsubscribe()
 	d.self.Destroy()
 }
 
 func (d *Dialog) UpdateTable() {
 	d.listStore.Clear()
-	if companiesData := companyData.Companies(); len(companiesData) > 0 {
+	if companiesData, err := d.store.Companies(); tr.IsOK(err) {
 		for _, c := range companiesData {
 			d.updateDataAtIter(d.listStore.Append(), c)
 		}
@@ -120,14 +134,26 @@ func (d *Dialog) UpdateTable() {
 	d.updateButtonStates()
 }
 
+func (d *Dialog) companiesChanged() {
+	selected := d.selectedCompany()
+	d.UpdateTable()
+	if selected != nil {
+		d.selectRowWithID(selected.ID())
+	}
+}
+
 func (d *Dialog) updateButtonStates() {
 	if _, ok := d.listStore.GetIterFirst(); ok {
 		d.deleteBtn.SetSensitive(true)
 		d.editBtn.SetSensitive(true)
+		d.projectsBtn.SetSensitive(true)
+		d.ratesBtn.SetSensitive(true)
 		return
 	}
 	d.deleteBtn.SetSensitive(false)
 	d.editBtn.SetSensitive(false)
+	d.projectsBtn.SetSensitive(false)
+	d.ratesBtn.SetSensitive(false)
 }
 
 func (d *Dialog) createButtons() *gtk.Box {
@@ -137,25 +163,35 @@ func (d *Dialog) createButtons() *gtk.Box {
 		if d.addBtn, err = gtk.ButtonNewWithLabel(addBtnText); tr.IsOK(err) {
 			if d.editBtn, err = gtk.ButtonNewWithLabel(editBtnText); tr.IsOK(err) {
 				if d.deleteBtn, err = gtk.ButtonNewWithLabel(deleteBtnText); tr.IsOK(err) {
-					if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); tr.IsOK(err) {
-						d.cancelBtn.SetTooltipText(cancelBtnTooltip)
-						d.addBtn.SetTooltipText(addBtnTooltip)
-						d.editBtn.SetTooltipText(editBtnTooltip)
-						d.deleteBtn.SetTooltipText(deleteBtnTooltip)
-
-						box.PackEnd(d.cancelBtn, false, false, 2)
-						box.PackEnd(d.addBtn, false, false, 2)
-						box.PackEnd(d.editBtn, false, false, 2)
-						box.PackEnd(d.deleteBtn, false, false, 2)
-
-						d.cancelBtn.Connect("clicked", func() {
-							d.self.Response(gtk.RESPONSE_OK)
-						})
-						d.addBtn.Connect("clicked", d.addActionHandler)
-						d.editBtn.Connect("clicked", d.editActionHandler)
-						d.deleteBtn.Connect("clicked", d.deleteActionHandler)
-
-						return box
+					if d.projectsBtn, err = gtk.ButtonNewWithLabel(projectsBtnText); tr.IsOK(err) {
+						if d.ratesBtn, err = gtk.ButtonNewWithLabel(ratesBtnText); tr.IsOK(err) {
+							if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); tr.IsOK(err) {
+								d.cancelBtn.SetTooltipText(cancelBtnTooltip)
+								d.addBtn.SetTooltipText(addBtnTooltip)
+								d.editBtn.SetTooltipText(editBtnTooltip)
+								d.deleteBtn.SetTooltipText(deleteBtnTooltip)
+								d.projectsBtn.SetTooltipText(projectsBtnTooltip)
+								d.ratesBtn.SetTooltipText(ratesBtnTooltip)
+
+								box.PackEnd(d.cancelBtn, false, false, 2)
+								box.PackEnd(d.addBtn, false, false, 2)
+								box.PackEnd(d.editBtn, false, false, 2)
+								box.PackEnd(d.deleteBtn, false, false, 2)
+								box.PackStart(d.projectsBtn, false, false, 2)
+								box.PackStart(d.ratesBtn, false, false, 2)
+
+								d.cancelBtn.Connect("clicked", func() {
+									d.self.Response(gtk.RESPONSE_OK)
+								})
+								d.addBtn.Connect("clicked", d.addActionHandler)
+								d.editBtn.Connect("clicked", d.editActionHandler)
+								d.deleteBtn.Connect("clicked", d.deleteActionHandler)
+								d.projectsBtn.Connect("clicked", d.projectsActionHandler)
+								d.ratesBtn.Connect("clicked", d.ratesActionHandler)
+
+								return box
+							}
+						}
 					}
 				}
 			}
@@ -177,13 +213,14 @@ func (d *Dialog) addActionHandler() {
 		dialog.ShowAll()
 		if dialog.Run() == gtk.RESPONSE_OK {
 			if c := dialog.Company(); c != nil && c.Valid() {
-				if c.Save() {
+				err := d.store.SaveCompany(c)
+				if err == nil {
 					d.UpdateTable()
 					d.selectRowWithID(c.ID())
 					return
 				}
+				company.SaveFailure(&d.self.Window, c, err)
 			}
-			d.saveFailure()
 		}
 	}
 }
@@ -196,37 +233,55 @@ func (d *Dialog) editActionHandler() {
 			dialog.ShowAll()
 			if dialog.Run() == gtk.RESPONSE_OK {
 				if c := dialog.Company(); c != nil && c.Valid() {
-					if c.Save() {
+					err := d.store.SaveCompany(c)
+					if err == nil {
 						d.updateDataInSelectedRow(c)
 						return
 					}
+					company.SaveFailure(&d.self.Window, c, err)
 				}
-				d.saveFailure()
 			}
 		}
 	}
 }
 
+func (d *Dialog) projectsActionHandler() {
+	if c := d.selectedCompany(); c != nil {
+		if dialog := projects.New(&d.self.Window, d.store, c); dialog != nil {
+			defer dialog.Destroy()
+
+			dialog.UpdateTable()
+			dialog.ShowAll()
+			dialog.Run()
+		}
+	}
+}
+
+func (d *Dialog) ratesActionHandler() {
+	if c := d.selectedCompany(); c != nil {
+		if dialog := rates.New(&d.self.Window, d.store, c); dialog != nil {
+			defer dialog.Destroy()
+
+			dialog.UpdateTable()
+			dialog.ShowAll()
+			dialog.Run()
+		}
+	}
+}
+
 /// Remove selected in table company from database and update table.
 func (d *Dialog) deleteActionHandler() {
-	// TODO: check if it is possible (maybe company was alrady used)
 	if iter := d.currentSelectionIter(); iter != nil {
 		if c := d.companyAtIter(iter); c != nil {
-			if c.Remove() {
-				d.listStore.Remove(iter)
+			if err := d.store.RemoveCompany(c); err != nil {
+				company.RemoveFailure(&d.self.Window, c, err)
+				return
 			}
+			d.listStore.Remove(iter)
 		}
 	}
 }
 
-func (d *Dialog) saveFailure() {
-	if dialog := gtk.MessageDialogNew(&d.self.Window, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_CLOSE, "error"); dialog != nil {
-		defer dialog.Destroy()
-		dialog.FormatSecondaryText("can't save company data to database.")
-		dialog.Run()
-	}
-}
-
 /********************************************************************
 *                                                                   *
 *                             T A B L E                             *
@@ -250,9 +305,9 @@ func (d *Dialog) createTable() bool {
 
 func (d *Dialog) setupTreeView() (*gtk.TreeView, *gtk.ListStore) {
 	if treeView, err := gtk.TreeViewNew(); tr.IsOK(err) {
-		if idColumn := d.createTextColumn("id", idColumnIdx); idColumn != nil {
-			if shortcutColumn := d.createTextColumn("shortcut", shortcutColumnIdx); shortcutColumn != nil {
-				if nameColumn := d.createTextColumn("name", nameColumnIdx); nameColumn != nil {
+		if idColumn := ui.TextColumn("id", idColumnIdx); idColumn != nil {
+			if shortcutColumn := ui.TextColumn("shortcut", shortcutColumnIdx); shortcutColumn != nil {
+				if nameColumn := ui.TextColumn("name", nameColumnIdx); nameColumn != nil {
 					if useColumn := d.createToggleColumn("in use", useColumnIdx); useColumn != nil {
 						idColumn.SetVisible(false)
 
@@ -280,16 +335,6 @@ func (d *Dialog) setupTreeView() (*gtk.TreeView, *gtk.ListStore) {
 	return nil, nil
 }
 
-func (d *Dialog) createTextColumn(title string, idx int) *gtk.TreeViewColumn {
-	if cellRenderer, err := gtk.CellRendererTextNew(); tr.IsOK(err) {
-		if column, err := gtk.TreeViewColumnNewWithAttribute(title, cellRenderer, "text", idx); tr.IsOK(err) {
-			column.SetResizable(true)
-			return column
-		}
-	}
-	return nil
-}
-
 func (d *Dialog) createToggleColumn(title string, idx int) *gtk.TreeViewColumn {
 	if renderer, err := gtk.CellRendererToggleNew(); tr.IsOK(err) {
 		renderer.SetActivatable(true)
@@ -299,9 +344,9 @@ func (d *Dialog) createToggleColumn(title string, idx int) *gtk.TreeViewColumn {
 					if iter, err := d.listStore.GetIter(path); tr.IsOK(err) {
 						if id, ok := d.getID(iter); ok {
 							if use, ok := d.getUse(iter); ok {
-								if c := companyData.CompanyWithID(id); c != nil {
+								if c, err := d.store.CompanyWithID(id); tr.IsOK(err) {
 									c.SetUsed(!use)
-									if c.Save() {
+									if err := d.store.SaveCompany(c); tr.IsOK(err) {
 										d.listStore.SetValue(iter, useColumnIdx, !use)
 									}
 								}
@@ -346,7 +391,9 @@ func (d *Dialog) selectedCompany() *companyData.Company {
 
 func (d *Dialog) companyAtIter(iter *gtk.TreeIter) *companyData.Company {
 	if id, ok := d.getID(iter); ok {
-		return companyData.CompanyWithID(id)
+		if c, err := d.store.CompanyWithID(id); err == nil {
+			return c
+		}
 	}
 	return nil
 }
@@ -378,11 +425,9 @@ func (d *Dialog) iterForID(id int) *gtk.TreeIter {
 		if v, ok := d.getID(iter); ok && v == id {
 			return iter
 		}
-		for {
-			if d.listStore.IterNext(iter) {
-				if v, ok := d.getID(iter); ok && v == id {
-					return iter
-				}
+		for d.listStore.IterNext(iter) {
+			if v, ok := d.getID(iter); ok && v == id {
+				return iter
 			}
 		}
 	}
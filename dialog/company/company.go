@@ -29,11 +29,14 @@
 package company
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
+	"Timelancer/dialog/ui"
 	"Timelancer/model/company"
 	"Timelancer/shared/tr"
+	"Timelancer/storage"
 	"github.com/gotk3/gotk3/gtk"
 )
 
@@ -41,6 +44,11 @@ const (
 	dialogTitle       = "company data"
 	shortcutLabelText = "shortcut:"
 	nameLabelText     = "name:"
+	currencyLabelText = "currency:"
+	addressLabelText  = "address:"
+	taxIDLabelText    = "tax id:"
+	vatLabelText      = "VAT (%):"
+	invoiceTooltip    = "printed on invoices"
 	inUseLabelText    = "is use:"
 	saveBtnText       = "save"
 	cancelBtnText     = "cancel"
@@ -52,9 +60,14 @@ type Dialog struct {
 	self          *gtk.Dialog
 	shortcutLabel *gtk.Label
 	nameLabel     *gtk.Label
+	currencyLabel *gtk.Label
 	usedLabel     *gtk.Label
 	shortcutEntry *gtk.Entry
 	nameEntry     *gtk.Entry
+	currencyEntry *gtk.Entry
+	addressBuffer *gtk.TextBuffer
+	taxIDEntry    *gtk.Entry
+	vatSpin       *gtk.SpinButton
 	usedBox       *gtk.CheckButton
 	company       *company.Company
 }
@@ -148,26 +161,37 @@ func (d *Dialog) createContent() *gtk.Grid {
 		if d.shortcutLabel, err = gtk.LabelNew(shortcutLabelText); tr.IsOK(err) {
 			if d.nameLabel, err = gtk.LabelNew(nameLabelText); tr.IsOK(err) {
 				if d.usedLabel, err = gtk.LabelNew(inUseLabelText); tr.IsOK(err) {
-					if d.shortcutEntry, err = gtk.EntryNew(); tr.IsOK(err) {
-						if d.nameEntry, err = gtk.EntryNew(); tr.IsOK(err) {
-							if d.usedBox, err = gtk.CheckButtonNew(); tr.IsOK(err) {
-								d.shortcutLabel.SetHAlign(gtk.ALIGN_END)
-								d.nameLabel.SetHAlign(gtk.ALIGN_END)
-								d.usedLabel.SetHAlign(gtk.ALIGN_END)
-								d.shortcutEntry.SetMaxWidthChars(5)
-								d.nameEntry.SetWidthChars(35)
-								d.usedBox.SetCanFocus(false)
-
-								grid.Attach(d.shortcutLabel, 0, 0, 1, 1)
-								grid.Attach(d.shortcutEntry, 1, 0, 1, 1)
-								grid.Attach(d.nameLabel, 0, 1, 1, 1)
-								grid.Attach(d.nameEntry, 1, 1, 1, 1)
-								grid.Attach(d.usedLabel, 0, 2, 1, 1)
-								grid.Attach(d.usedBox, 1, 2, 1, 1)
-
-								d.usedBox.Connect("toggled", d.updateFocus)
-
-								return grid
+					if d.currencyLabel, err = gtk.LabelNew(currencyLabelText); tr.IsOK(err) {
+						if d.shortcutEntry, err = gtk.EntryNew(); tr.IsOK(err) {
+							if d.nameEntry, err = gtk.EntryNew(); tr.IsOK(err) {
+								if d.currencyEntry, err = gtk.EntryNew(); tr.IsOK(err) {
+									if d.usedBox, err = gtk.CheckButtonNew(); tr.IsOK(err) {
+										d.shortcutLabel.SetHAlign(gtk.ALIGN_END)
+										d.nameLabel.SetHAlign(gtk.ALIGN_END)
+										d.currencyLabel.SetHAlign(gtk.ALIGN_END)
+										d.usedLabel.SetHAlign(gtk.ALIGN_END)
+										d.shortcutEntry.SetMaxWidthChars(5)
+										d.nameEntry.SetWidthChars(35)
+										d.currencyEntry.SetMaxWidthChars(3)
+										d.currencyEntry.SetHAlign(gtk.ALIGN_START)
+										d.usedBox.SetCanFocus(false)
+
+										grid.Attach(d.shortcutLabel, 0, 0, 1, 1)
+										grid.Attach(d.shortcutEntry, 1, 0, 1, 1)
+										grid.Attach(d.nameLabel, 0, 1, 1, 1)
+										grid.Attach(d.nameEntry, 1, 1, 1, 1)
+										grid.Attach(d.currencyLabel, 0, 2, 1, 1)
+										grid.Attach(d.currencyEntry, 1, 2, 1, 1)
+										grid.Attach(d.usedLabel, 0, 6, 1, 1)
+										grid.Attach(d.usedBox, 1, 6, 1, 1)
+
+										d.usedBox.Connect("toggled", d.updateFocus)
+
+										if d.attachInvoiceContent(grid, 3) {
+											return grid
+										}
+									}
+								}
 							}
 						}
 					}
@@ -178,6 +202,45 @@ func (d *Dialog) createContent() *gtk.Grid {
 	return nil
 }
 
+// attachInvoiceContent attaches (from the row) data printed on invoices.
+func (d *Dialog) attachInvoiceContent(grid *gtk.Grid, row int) bool {
+	if addressLabel, err := gtk.LabelNew(addressLabelText); tr.IsOK(err) {
+		if taxIDLabel, err := gtk.LabelNew(taxIDLabelText); tr.IsOK(err) {
+			if vatLabel, err := gtk.LabelNew(vatLabelText); tr.IsOK(err) {
+				if addressView, err := gtk.TextViewNew(); tr.IsOK(err) {
+					if d.addressBuffer, err = addressView.GetBuffer(); tr.IsOK(err) {
+						if d.taxIDEntry, err = gtk.EntryNew(); tr.IsOK(err) {
+							if d.vatSpin, err = gtk.SpinButtonNewWithRange(0, 100, 1); tr.IsOK(err) {
+								addressLabel.SetHAlign(gtk.ALIGN_END)
+								addressLabel.SetVAlign(gtk.ALIGN_START)
+								taxIDLabel.SetHAlign(gtk.ALIGN_END)
+								vatLabel.SetHAlign(gtk.ALIGN_END)
+								addressView.SetWrapMode(gtk.WRAP_WORD)
+								addressView.SetAcceptsTab(false)
+								addressView.SetSizeRequest(300, 50)
+								addressView.SetTooltipText(invoiceTooltip)
+								d.taxIDEntry.SetTooltipText(invoiceTooltip)
+								d.vatSpin.SetDigits(1)
+								d.vatSpin.SetHAlign(gtk.ALIGN_START)
+								d.vatSpin.SetTooltipText(invoiceTooltip)
+
+								grid.Attach(addressLabel, 0, row, 1, 1)
+								grid.Attach(addressView, 1, row, 1, 1)
+								grid.Attach(taxIDLabel, 0, row+1, 1, 1)
+								grid.Attach(d.taxIDEntry, 1, row+1, 1, 1)
+								grid.Attach(vatLabel, 0, row+2, 1, 1)
+								grid.Attach(d.vatSpin, 1, row+2, 1, 1)
+								return true
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
 func (d *Dialog) widgetsToCompany() bool {
 	if shortcut, err := d.shortcutEntry.GetText(); tr.IsOK(err) {
 		if strings.TrimSpace(shortcut) == "" {
@@ -191,10 +254,26 @@ func (d *Dialog) widgetsToCompany() bool {
 				d.nameEntry.GrabFocus()
 				return false
 			}
-			d.company.SetShortcut(shortcut)
-			d.company.SetName(name)
-			d.company.SetUsed(d.usedBox.GetActive())
-			return true
+			if currency, err := d.currencyEntry.GetText(); tr.IsOK(err) {
+				currency = strings.ToUpper(strings.TrimSpace(currency))
+				if currency == "" {
+					d.canNotBeEmpty("currency")
+					d.currencyEntry.GrabFocus()
+					return false
+				}
+				d.company.SetShortcut(shortcut)
+				d.company.SetName(name)
+				d.company.SetCurrency(currency)
+				d.company.SetUsed(d.usedBox.GetActive())
+				if address, err := d.addressBuffer.GetText(d.addressBuffer.GetStartIter(), d.addressBuffer.GetEndIter(), false); tr.IsOK(err) {
+					if taxID, err := d.taxIDEntry.GetText(); tr.IsOK(err) {
+						d.company.SetAddress(strings.TrimSpace(address))
+						d.company.SetTaxID(strings.TrimSpace(taxID))
+						d.company.SetVATPercent(d.vatSpin.GetValue())
+						return true
+					}
+				}
+			}
 		}
 	}
 	return false
@@ -203,29 +282,73 @@ func (d *Dialog) widgetsToCompany() bool {
 func (d *Dialog) companyToWidgets() {
 	d.shortcutEntry.SetText(d.company.Shortcut())
 	d.nameEntry.SetText(d.company.Name())
+	d.currencyEntry.SetText(d.company.Currency())
+	d.addressBuffer.SetText(d.company.Address())
+	d.taxIDEntry.SetText(d.company.TaxID())
+	d.vatSpin.SetValue(d.company.VATPercent())
 	d.usedBox.SetActive(d.company.Used())
 	d.updateFocus()
 }
 
 func (d *Dialog) canNotBeEmpty(name string) {
-	if dialog := gtk.MessageDialogNew(d.self, gtk.DIALOG_MODAL, gtk.MESSAGE_ERROR, gtk.BUTTONS_OK, ""); dialog != nil {
-		defer dialog.Destroy()
-		dialog.FormatSecondaryText(fmt.Sprintf("field '%s' can not be empty!", name))
-		dialog.Run()
-	}
+	ui.ShowError(d.self, fmt.Sprintf("field '%s' can not be empty!", name))
 }
 
 func (d *Dialog) updateFocus() {
 	if d.usedBox.GetActive() {
 		d.shortcutLabel.SetSensitive(true)
 		d.nameLabel.SetSensitive(true)
+		d.currencyLabel.SetSensitive(true)
 		d.shortcutEntry.SetSensitive(true)
 		d.nameEntry.SetSensitive(true)
+		d.currencyEntry.SetSensitive(true)
 		d.shortcutEntry.GrabFocus()
 	} else {
 		d.shortcutLabel.SetSensitive(false)
 		d.nameLabel.SetSensitive(false)
+		d.currencyLabel.SetSensitive(false)
 		d.shortcutEntry.SetSensitive(false)
 		d.nameEntry.SetSensitive(false)
+		d.currencyEntry.SetSensitive(false)
+	}
+}
+
+/********************************************************************
+*                                                                   *
+*                          F A I L U R E S                          *
+*                                                                   *
+********************************************************************/
+
+// SaveFailure tells the user why the company could not be saved.
+func SaveFailure(parent *gtk.Window, c *company.Company, err error) {
+	ui.ShowError(parent, saveErrorText(c, err))
+}
+
+// RemoveFailure tells the user why the company could not be removed.
+func RemoveFailure(parent *gtk.Window, c *company.Company, err error) {
+	ui.ShowError(parent, removeErrorText(c, err))
+}
+
+func saveErrorText(c *company.Company, err error) string {
+	switch {
+	case errors.Is(err, storage.ErrShortcutExists):
+		return fmt.Sprintf("shortcut %s already exists.", c.Shortcut())
+	case errors.Is(err, storage.ErrNameExists):
+		return fmt.Sprintf("company %s already exists.", c.Name())
+	case errors.Is(err, storage.ErrCurrencyInUse):
+		return fmt.Sprintf("company %s has rates or working times, its currency can't be changed.", c.Shortcut())
+	case errors.Is(err, storage.ErrBusy):
+		return "database is busy, try again later."
+	}
+	return "can't save company data to database."
+}
+
+func removeErrorText(c *company.Company, err error) string {
+	switch {
+	case errors.Is(err, storage.ErrCompanyInUse):
+		return fmt.Sprintf("company %s has saved working times and can't be removed.", c.Shortcut())
+	case errors.Is(err, storage.ErrBusy):
+		return "database is busy, try again later."
 	}
+	return "can't remove company from database."
 }
@@ -0,0 +1,155 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package health
+
+import (
+	"Timelancer/dbf"
+	"Timelancer/shared/tr"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	dialogTitle          = "database problems"
+	restoreBtnText       = "restore backup"
+	quarantineBtnText    = "start with empty database"
+	ignoreBtnText        = "open anyway"
+	quitBtnText          = "quit"
+	restoreBtnTooltip    = "replace the database with the newest healthy backup"
+	quarantineBtnTooltip = "move the database aside and create a new one"
+	ignoreBtnTooltip     = "open the database as it is"
+	quitBtnTooltip       = "close the application"
+	problemsText         = "Problems were found in the database.\nThe damaged file is never removed, it is moved aside.\n\n"
+)
+
+// Responses of the dialog (besides gtk.RESPONSE_CANCEL for quit).
+const (
+	RestoreResponse    gtk.ResponseType = 1
+	QuarantineResponse gtk.ResponseType = 2
+	IgnoreResponse     gtk.ResponseType = 3
+)
+
+type Dialog struct {
+	self       *gtk.Dialog
+	report     *dbf.Report
+	canRestore bool
+}
+
+// New creates the dialog showing the report, restoring is offered
+// only if canRestore (there are backups).
+func New(parent *gtk.Window, report *dbf.Report, canRestore bool) *Dialog {
+	if dialog, err := gtk.DialogNew(); tr.IsOK(err) {
+		if parent != nil {
+			dialog.SetTransientFor(parent)
+		}
+		dialog.SetBorderWidth(6)
+		dialog.SetTitle(dialogTitle)
+
+		instance := &Dialog{self: dialog, report: report, canRestore: canRestore}
+
+		if contentArea, err := dialog.GetContentArea(); tr.IsOK(err) {
+			if buttonBox := instance.createButtons(); buttonBox != nil {
+				if separator, err := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL); tr.IsOK(err) {
+					if scroll := instance.createContent(); scroll != nil {
+						contentArea.PackEnd(buttonBox, false, false, 1)
+						contentArea.PackEnd(separator, true, false, 1)
+						contentArea.PackEnd(scroll, true, true, 1)
+						return instance
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) ShowAll() {
+	d.self.ShowAll()
+}
+
+func (d *Dialog) Run() gtk.ResponseType {
+	return d.self.Run()
+}
+
+func (d *Dialog) Destroy() {
+	d.self.Destroy()
+}
+
+func (d *Dialog) createContent() *gtk.ScrolledWindow {
+	if scroll, err := gtk.ScrolledWindowNew(nil, nil); tr.IsOK(err) {
+		if label, err := gtk.LabelNew(problemsText + d.report.String()); tr.IsOK(err) {
+			label.SetLineWrap(true)
+			label.SetSelectable(true)
+			label.SetXAlign(0)
+
+			scroll.SetPolicy(gtk.POLICY_NEVER, gtk.POLICY_AUTOMATIC)
+			scroll.SetSizeRequest(500, 250)
+			scroll.Add(label)
+			return scroll
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) createButtons() *gtk.Box {
+	if restoreBtn, err := gtk.ButtonNewWithLabel(restoreBtnText); tr.IsOK(err) {
+		if quarantineBtn, err := gtk.ButtonNewWithLabel(quarantineBtnText); tr.IsOK(err) {
+			if ignoreBtn, err := gtk.ButtonNewWithLabel(ignoreBtnText); tr.IsOK(err) {
+				if quitBtn, err := gtk.ButtonNewWithLabel(quitBtnText); tr.IsOK(err) {
+					if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); tr.IsOK(err) {
+						restoreBtn.SetTooltipText(restoreBtnTooltip)
+						quarantineBtn.SetTooltipText(quarantineBtnTooltip)
+						ignoreBtn.SetTooltipText(ignoreBtnTooltip)
+						quitBtn.SetTooltipText(quitBtnTooltip)
+						restoreBtn.SetSensitive(d.canRestore)
+
+						box.PackEnd(quitBtn, false, false, 2)
+						box.PackEnd(ignoreBtn, false, false, 2)
+						box.PackEnd(quarantineBtn, false, false, 2)
+						box.PackEnd(restoreBtn, false, false, 2)
+
+						restoreBtn.Connect("clicked", func() {
+							d.self.Response(RestoreResponse)
+						})
+						quarantineBtn.Connect("clicked", func() {
+							d.self.Response(QuarantineResponse)
+						})
+						ignoreBtn.Connect("clicked", func() {
+							d.self.Response(IgnoreResponse)
+						})
+						quitBtn.Connect("clicked", func() {
+							d.self.Response(gtk.RESPONSE_CANCEL)
+						})
+						return box
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
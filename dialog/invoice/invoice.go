@@ -0,0 +1,551 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package invoice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"Timelancer/dialog/ui"
+	"Timelancer/model/company"
+	"Timelancer/model/invoice"
+	"Timelancer/model/rate"
+	"Timelancer/shared"
+	"Timelancer/shared/tr"
+	"Timelancer/storage"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	dialogTitleFormat = "invoice for %s"
+	messageTitle      = "invoice"
+	sellerLabelText   = "seller:"
+	groupLabelText    = "lines:"
+	issuedLabelText   = "issue date:"
+	periodLabelText   = "worked from:"
+	periodToLabelText = "to:"
+	issueBtnText      = "issue"
+	cancelBtnText     = "cancel"
+	sellerTooltip     = "company data printed as the seller"
+	issuedTooltip     = "YYYY-MM-DD"
+	periodTooltip     = "YYYY-MM-DD, changing the period selects its working times with a rate again"
+	issueTooltip      = "save the invoice with the next number, write PDF and JSON files"
+	cancelTooltip     = "do nothing"
+	totalsFormat      = "net: %s %s, VAT %s%%: %s %s, total: %s %s"
+	savedFormat       = "invoice %s was saved to %s"
+	alteredFormat     = "PDF font has no letters for some of texts, they will be printed without diacritics or with '?':\n\n%s\n\nIssue the invoice anyway?"
+	dateFormat        = "2006-01-02"
+	invoicesDir       = "invoices"
+
+	descriptionColumnIdx = 0
+	hoursColumnIdx       = 1
+	rateColumnIdx        = 2
+	netColumnIdx         = 3
+
+	billColumnIdx             = 0
+	entryDateColumnIdx        = 1
+	entryProjectColumnIdx     = 2
+	entryDescriptionColumnIdx = 3
+	entryHoursColumnIdx       = 4
+	entryRateColumnIdx        = 5
+)
+
+var groups = []string{"by project", "by tag"}
+
+type Dialog struct {
+	self        *gtk.Dialog
+	store       storage.Repositories
+	buyer       *company.Company
+	entries     []storage.TimerEntry
+	selected    []bool
+	sellers     []*company.Company
+	sellerCombo *gtk.ComboBoxText
+	groupCombo  *gtk.ComboBoxText
+	issuedEntry *gtk.Entry
+	fromEntry   *gtk.Entry
+	toEntry     *gtk.Entry
+	entryStore  *gtk.ListStore
+	listStore   *gtk.ListStore
+	totalsLabel *gtk.Label
+	invoice     *invoice.Invoice
+}
+
+// New creates the dialog of the invoice for the buyer, entries are working
+// times which can be billed. Only entries selected by the user are billed
+// (working times without a rate are not selected by default).
+// The invoice is saved by the dialog.
+func New(parent *gtk.Window, store storage.Repositories, buyer *company.Company, entries []storage.TimerEntry) *Dialog {
+	if dialog, err := gtk.DialogNew(); tr.IsOK(err) {
+		dialog.SetTransientFor(parent)
+		dialog.SetBorderWidth(6)
+		dialog.SetTitle(fmt.Sprintf(dialogTitleFormat, buyer.Name()))
+
+		instance := &Dialog{self: dialog, store: store, buyer: buyer, entries: entries, selected: make([]bool, len(entries))}
+
+		if contentArea, err := dialog.GetContentArea(); tr.IsOK(err) {
+			if buttonBox := instance.createButtons(); buttonBox != nil {
+				if separator, err := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL); tr.IsOK(err) {
+					if contentGrid := instance.createContent(); contentGrid != nil {
+						if entriesScroll := instance.createEntriesTable(); entriesScroll != nil {
+							if scroll := instance.createTable(); scroll != nil {
+								if totalsLabel, err := gtk.LabelNew(""); tr.IsOK(err) {
+									contentArea.SetBorderWidth(4)
+									contentArea.SetSpacing(4)
+									totalsLabel.SetHAlign(gtk.ALIGN_END)
+									instance.totalsLabel = totalsLabel
+
+									contentArea.PackEnd(buttonBox, false, false, 0)
+									contentArea.PackEnd(separator, true, true, 1)
+									contentArea.PackEnd(totalsLabel, false, false, 1)
+									contentArea.PackEnd(scroll, true, true, 1)
+									contentArea.PackEnd(entriesScroll, true, true, 1)
+									contentArea.PackEnd(contentGrid, false, false, 0)
+									return instance
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) ShowAll() {
+	d.populateSellerCombo()
+	d.groupCombo.SetActive(int(invoice.ByProject))
+	d.issuedEntry.SetText(time.Now().Format(dateFormat))
+	d.populateEntriesTable()
+	d.setDefaultPeriod()
+	d.self.ShowAll()
+}
+
+func (d *Dialog) Run() gtk.ResponseType {
+	return d.self.Run()
+}
+
+func (d *Dialog) Destroy() {
+	d.self.Destroy()
+}
+
+// Invoice returns the saved invoice (nil if it was not issued).
+func (d *Dialog) Invoice() *invoice.Invoice {
+	return d.invoice
+}
+
+func (d *Dialog) createButtons() *gtk.Box {
+	if issueBtn, err := gtk.ButtonNewWithLabel(issueBtnText); tr.IsOK(err) {
+		if cancelBtn, err := gtk.ButtonNewWithLabel(cancelBtnText); tr.IsOK(err) {
+			if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); tr.IsOK(err) {
+				issueBtn.SetTooltipText(issueTooltip)
+				cancelBtn.SetTooltipText(cancelTooltip)
+
+				box.PackEnd(issueBtn, false, true, 2)
+				box.PackEnd(cancelBtn, false, true, 2)
+
+				issueBtn.Connect("clicked", func() {
+					if d.issue() {
+						d.self.Response(gtk.RESPONSE_OK)
+					}
+				})
+				cancelBtn.Connect("clicked", func() {
+					d.self.Response(gtk.RESPONSE_CANCEL)
+				})
+
+				return box
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) createContent() *gtk.Grid {
+	if grid, err := gtk.GridNew(); tr.IsOK(err) {
+		if sellerLabel, err := gtk.LabelNew(sellerLabelText); tr.IsOK(err) {
+			if groupLabel, err := gtk.LabelNew(groupLabelText); tr.IsOK(err) {
+				if issuedLabel, err := gtk.LabelNew(issuedLabelText); tr.IsOK(err) {
+					if periodLabel, err := gtk.LabelNew(periodLabelText); tr.IsOK(err) {
+						if periodToLabel, err := gtk.LabelNew(periodToLabelText); tr.IsOK(err) {
+							if d.sellerCombo, err = gtk.ComboBoxTextNew(); tr.IsOK(err) {
+								if d.groupCombo, err = gtk.ComboBoxTextNew(); tr.IsOK(err) {
+									if d.issuedEntry, err = gtk.EntryNew(); tr.IsOK(err) {
+										if d.fromEntry, err = gtk.EntryNew(); tr.IsOK(err) {
+											if d.toEntry, err = gtk.EntryNew(); tr.IsOK(err) {
+												grid.SetBorderWidth(8)
+												grid.SetRowSpacing(8)
+												grid.SetColumnSpacing(8)
+
+												sellerLabel.SetHAlign(gtk.ALIGN_END)
+												groupLabel.SetHAlign(gtk.ALIGN_END)
+												issuedLabel.SetHAlign(gtk.ALIGN_END)
+												periodLabel.SetHAlign(gtk.ALIGN_END)
+												periodToLabel.SetHAlign(gtk.ALIGN_END)
+												d.sellerCombo.SetTooltipText(sellerTooltip)
+												d.issuedEntry.SetMaxWidthChars(10)
+												d.issuedEntry.SetTooltipText(issuedTooltip)
+												d.fromEntry.SetMaxWidthChars(10)
+												d.fromEntry.SetTooltipText(periodTooltip)
+												d.toEntry.SetMaxWidthChars(10)
+												d.toEntry.SetTooltipText(periodTooltip)
+												for _, text := range groups {
+													d.groupCombo.AppendText(text)
+												}
+
+												grid.Attach(sellerLabel, 0, 0, 1, 1)
+												grid.Attach(d.sellerCombo, 1, 0, 3, 1)
+												grid.Attach(groupLabel, 0, 1, 1, 1)
+												grid.Attach(d.groupCombo, 1, 1, 3, 1)
+												grid.Attach(issuedLabel, 0, 2, 1, 1)
+												grid.Attach(d.issuedEntry, 1, 2, 1, 1)
+												grid.Attach(periodLabel, 0, 3, 1, 1)
+												grid.Attach(d.fromEntry, 1, 3, 1, 1)
+												grid.Attach(periodToLabel, 2, 3, 1, 1)
+												grid.Attach(d.toEntry, 3, 3, 1, 1)
+
+												d.groupCombo.Connect("changed", d.updatePreview)
+												d.fromEntry.Connect("changed", d.periodChanged)
+												d.toEntry.Connect("changed", d.periodChanged)
+
+												return grid
+											}
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) createTable() *gtk.ScrolledWindow {
+	if scroll, err := gtk.ScrolledWindowNew(nil, nil); tr.IsOK(err) {
+		if treeView, err := gtk.TreeViewNew(); tr.IsOK(err) {
+			if listStore, err := gtk.ListStoreNew(glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING); tr.IsOK(err) {
+				for i, title := range []string{"description", "hours", "rate", "net amount"} {
+					if column := ui.TextColumn(title, i); column != nil {
+						treeView.AppendColumn(column)
+					}
+				}
+				treeView.SetModel(listStore)
+				d.listStore = listStore
+
+				scroll.SetSizeRequest(500, 200)
+				scroll.Add(treeView)
+				return scroll
+			}
+		}
+	}
+	return nil
+}
+
+// createEntriesTable creates the table of working times, the first column
+// tells whether the working time is billed.
+func (d *Dialog) createEntriesTable() *gtk.ScrolledWindow {
+	if scroll, err := gtk.ScrolledWindowNew(nil, nil); tr.IsOK(err) {
+		if treeView, err := gtk.TreeViewNew(); tr.IsOK(err) {
+			if listStore, err := gtk.ListStoreNew(glib.TYPE_BOOLEAN, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING); tr.IsOK(err) {
+				if billColumn := d.createToggleColumn("bill", billColumnIdx); billColumn != nil {
+					treeView.AppendColumn(billColumn)
+				}
+				for i, title := range []string{"date", "project", "description", "hours", "rate"} {
+					if column := ui.TextColumn(title, i+entryDateColumnIdx); column != nil {
+						treeView.AppendColumn(column)
+					}
+				}
+				treeView.SetModel(listStore)
+				d.entryStore = listStore
+
+				scroll.SetSizeRequest(500, 200)
+				scroll.Add(treeView)
+				return scroll
+			}
+		}
+	}
+	return nil
+}
+
+// createToggleColumn creates the column to (un)select the working time,
+// rows of the table are in order of d.entries.
+func (d *Dialog) createToggleColumn(title string, idx int) *gtk.TreeViewColumn {
+	if renderer, err := gtk.CellRendererToggleNew(); tr.IsOK(err) {
+		renderer.SetActivatable(true)
+		renderer.Connect("toggled", func(p *gtk.CellRendererToggle, rowAsString string) {
+			if row, err := strconv.Atoi(rowAsString); tr.IsOK(err) && row >= 0 && row < len(d.selected) {
+				if path, err := gtk.TreePathNewFromIndicesv([]int{row}); tr.IsOK(err) {
+					if iter, err := d.entryStore.GetIter(path); tr.IsOK(err) {
+						d.selected[row] = !d.selected[row]
+						d.entryStore.SetValue(iter, billColumnIdx, d.selected[row])
+						d.updatePreview()
+					}
+				}
+			}
+		})
+		if column, err := gtk.TreeViewColumnNewWithAttribute(title, renderer, "active", idx); tr.IsOK(err) {
+			return column
+		}
+	}
+	return nil
+}
+
+// populateSellerCombo lists other companies, the seller of the last invoice
+// of this year is selected.
+func (d *Dialog) populateSellerCombo() {
+	d.sellers = nil
+	if data, err := d.store.Companies(); tr.IsOK(err) {
+		for _, c := range data {
+			if c.ID() != d.buyer.ID() {
+				d.sellers = append(d.sellers, c)
+			}
+		}
+	}
+
+	lastSeller := ""
+	if data, err := d.store.Invoices(time.Now().Year()); tr.IsOK(err) && len(data) > 0 {
+		lastSeller = data[len(data)-1].Seller().Name
+	}
+	active := 0
+	d.sellerCombo.RemoveAll()
+	for i, c := range d.sellers {
+		d.sellerCombo.AppendText(c.Name())
+		if c.Name() == lastSeller {
+			active = i
+		}
+	}
+	d.sellerCombo.SetActive(active)
+}
+
+func (d *Dialog) selectedGroup() invoice.Group {
+	if row := d.groupCombo.GetActive(); row == int(invoice.ByTag) {
+		return invoice.ByTag
+	}
+	return invoice.ByProject
+}
+
+func (d *Dialog) populateEntriesTable() {
+	d.entryStore.Clear()
+	for _, entry := range d.entries {
+		if iter := d.entryStore.Append(); iter != nil {
+			d.entryStore.SetValue(iter, billColumnIdx, false)
+			d.entryStore.SetValue(iter, entryDateColumnIdx, entry.Start.Format(dateFormat))
+			d.entryStore.SetValue(iter, entryProjectColumnIdx, entry.ProjectName)
+			d.entryStore.SetValue(iter, entryDescriptionColumnIdx, entry.Description)
+			d.entryStore.SetValue(iter, entryHoursColumnIdx, fmt.Sprintf("%.2f", entry.Duration().Hours()))
+			d.entryStore.SetValue(iter, entryRateColumnIdx, rate.FormatAmount(entry.CentsPerHour))
+		}
+	}
+}
+
+// setDefaultPeriod sets the period to the days of all working times,
+// so all of them with a rate are selected.
+func (d *Dialog) setDefaultPeriod() {
+	if len(d.entries) == 0 {
+		d.updatePreview()
+		return
+	}
+	from, to := d.entries[0].Start, d.entries[0].Start
+	for _, entry := range d.entries {
+		if entry.Start.Before(from) {
+			from = entry.Start
+		}
+		if entry.Start.After(to) {
+			to = entry.Start
+		}
+	}
+	d.fromEntry.SetText(from.Format(dateFormat))
+	d.toEntry.SetText(to.Format(dateFormat))
+}
+
+// periodChanged selects working times started in the period (both days
+// included) which have a rate, other ones are unselected.
+// Nothing is changed while the period is not valid.
+func (d *Dialog) periodChanged() {
+	from, ok := entryDate(d.fromEntry)
+	if !ok {
+		return
+	}
+	to, ok := entryDate(d.toEntry)
+	if !ok {
+		return
+	}
+	to = to.AddDate(0, 0, 1)
+
+	for row, entry := range d.entries {
+		d.selected[row] = entry.CentsPerHour > 0 && !entry.Start.Before(from) && entry.Start.Before(to)
+		if path, err := gtk.TreePathNewFromIndicesv([]int{row}); tr.IsOK(err) {
+			if iter, err := d.entryStore.GetIter(path); tr.IsOK(err) {
+				d.entryStore.SetValue(iter, billColumnIdx, d.selected[row])
+			}
+		}
+	}
+	d.updatePreview()
+}
+
+func entryDate(entry *gtk.Entry) (time.Time, bool) {
+	if text, err := entry.GetText(); tr.IsOK(err) {
+		if t, err := time.ParseInLocation(dateFormat, strings.TrimSpace(text), time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// items returns selected working times only.
+func (d *Dialog) items() []invoice.Item {
+	var items []invoice.Item
+	for i, entry := range d.entries {
+		if d.selected[i] {
+			items = append(items, entry.InvoiceItem(d.selectedGroup()))
+		}
+	}
+	return items
+}
+
+// updatePreview shows lines and totals of the invoice to be issued.
+func (d *Dialog) updatePreview() {
+	preview := invoice.New(company.New(), d.buyer, time.Now(), d.items())
+
+	d.listStore.Clear()
+	for _, line := range preview.Lines() {
+		if iter := d.listStore.Append(); iter != nil {
+			d.listStore.SetValue(iter, descriptionColumnIdx, line.Description)
+			d.listStore.SetValue(iter, hoursColumnIdx, fmt.Sprintf("%.2f", line.Duration().Hours()))
+			d.listStore.SetValue(iter, rateColumnIdx, rate.FormatAmount(line.CentsPerHour))
+			d.listStore.SetValue(iter, netColumnIdx, rate.FormatAmount(line.NetCents))
+		}
+	}
+	d.totalsLabel.SetText(totalsText(preview))
+}
+
+func totalsText(inv *invoice.Invoice) string {
+	currency := inv.Currency()
+	vat := strconv.FormatFloat(inv.VATPercent(), 'f', -1, 64)
+	return fmt.Sprintf(totalsFormat, rate.FormatAmount(inv.Net()), currency, vat, rate.FormatAmount(inv.VAT()), currency,
+		rate.FormatAmount(inv.Gross()), currency)
+}
+
+// issue saves the invoice and writes its files.
+func (d *Dialog) issue() bool {
+	row := d.sellerCombo.GetActive()
+	if row < 0 || row >= len(d.sellers) {
+		ui.ShowError(d.self, "select the seller (add your company data first).")
+		return false
+	}
+	text, err := d.issuedEntry.GetText()
+	if !tr.IsOK(err) {
+		return false
+	}
+	issued, err := time.ParseInLocation(dateFormat, strings.TrimSpace(text), time.Local)
+	if err != nil {
+		ui.ShowError(d.self, fmt.Sprintf("invalid issue date '%s'.", text))
+		d.issuedEntry.GrabFocus()
+		return false
+	}
+
+	inv := invoice.New(d.sellers[row], d.buyer, issued, d.items())
+	if !inv.Valid() {
+		ui.ShowError(d.self, "there is nothing to invoice (select working times with a rate).")
+		return false
+	}
+	if altered := inv.AlteredInPDF(); len(altered) > 0 {
+		text := fmt.Sprintf(alteredFormat, strings.Join(altered, "\n"))
+		if !ui.AskQuestion(d.self, messageTitle, text) {
+			return false
+		}
+	}
+	if err := d.store.SaveInvoice(inv); err != nil {
+		ui.ShowError(d.self, saveErrorText(err))
+		return false
+	}
+	d.invoice = inv
+
+	dir, err := writeFiles(inv)
+	if err != nil {
+		tr.IsOK(err)
+		ui.ShowError(d.self, fmt.Sprintf("invoice %s was saved, but its files could not be written.", inv.Number()))
+		return true
+	}
+	ui.ShowInfo(d.self, messageTitle, fmt.Sprintf(savedFormat, inv.Number(), dir))
+	return true
+}
+
+// writeFiles writes PDF and JSON files of the invoice to the application
+// directory, returns the directory.
+func writeFiles(inv *invoice.Invoice) (string, error) {
+	appDir := shared.AppDir()
+	if appDir == "" {
+		return "", errors.New("there is no application directory")
+	}
+	dir := filepath.Join(appDir, invoicesDir)
+	if !shared.CreateDirIfNeeded(dir) {
+		return "", fmt.Errorf("can't create directory %s", dir)
+	}
+	name := strings.ReplaceAll(inv.Number(), "/", "-")
+
+	data, err := json.MarshalIndent(inv, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), data, 0600); err != nil {
+		return "", err
+	}
+
+	file, err := os.Create(filepath.Join(dir, name+".pdf"))
+	if err != nil {
+		return "", err
+	}
+	if err := inv.WritePDF(file); err != nil {
+		file.Close()
+		return "", err
+	}
+	return dir, file.Close()
+}
+
+func saveErrorText(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrInvoiced):
+		return "some of working times are already invoiced."
+	case errors.Is(err, storage.ErrNotFound):
+		return "some of working times don't exist any more."
+	case errors.Is(err, storage.ErrBusy):
+		return "database is busy, try again later."
+	}
+	return "can't save invoice to database."
+}
@@ -0,0 +1,266 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package project
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"Timelancer/dialog/ui"
+	"Timelancer/model/project"
+	"Timelancer/shared/tr"
+	"Timelancer/storage"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	dialogTitle     = "project data"
+	codeLabelText   = "code:"
+	nameLabelText   = "name:"
+	activeLabelText = "active:"
+	budgetLabelText = "budget (hours):"
+	saveBtnText     = "save"
+	cancelBtnText   = "cancel"
+	saveTooltip     = "save data to database"
+	cancelTooltip   = "do nothing"
+	budgetTooltip   = "0 means no budget"
+	maxBudgetHours  = 100000
+)
+
+type Dialog struct {
+	self        *gtk.Dialog
+	codeLabel   *gtk.Label
+	nameLabel   *gtk.Label
+	activeLabel *gtk.Label
+	budgetLabel *gtk.Label
+	codeEntry   *gtk.Entry
+	nameEntry   *gtk.Entry
+	activeBox   *gtk.CheckButton
+	budgetSpin  *gtk.SpinButton
+	project     *project.Project
+}
+
+// New creates the dialog for the project, a new project of the company
+// is created if p is nil.
+func New(win *gtk.Window, companyID int, p *project.Project) *Dialog {
+	if dialog, err := gtk.DialogNew(); tr.IsOK(err) {
+		dialog.SetTransientFor(win)
+		dialog.SetBorderWidth(6)
+		dialog.SetTitle(dialogTitle)
+
+		instance := &Dialog{self: dialog, project: p}
+		if instance.project == nil {
+			instance.project = project.New(companyID)
+		}
+
+		if contentArea, err := dialog.GetContentArea(); tr.IsOK(err) {
+			if buttonBox := instance.createButtons(); buttonBox != nil {
+				if separator, err := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL); tr.IsOK(err) {
+					if contentGrid := instance.createContent(); contentGrid != nil {
+						contentArea.SetBorderWidth(4)
+						contentArea.SetSpacing(4)
+
+						contentArea.PackEnd(buttonBox, false, false, 0)
+						contentArea.PackEnd(separator, true, true, 1)
+						contentArea.PackEnd(contentGrid, false, false, 0)
+						return instance
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) ShowAll() {
+	d.projectToWidgets()
+	d.self.ShowAll()
+	d.self.SetResizable(false)
+}
+
+func (d *Dialog) Run() gtk.ResponseType {
+	return d.self.Run()
+}
+
+func (d *Dialog) Destroy() {
+	d.self.Destroy()
+}
+
+func (d *Dialog) Project() *project.Project {
+	return d.project
+}
+
+func (d *Dialog) createButtons() *gtk.Box {
+	if okBtn, err := gtk.ButtonNewWithLabel(saveBtnText); tr.IsOK(err) {
+		if cancelBtn, err := gtk.ButtonNewWithLabel(cancelBtnText); tr.IsOK(err) {
+			if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); tr.IsOK(err) {
+				okBtn.SetTooltipText(saveTooltip)
+				cancelBtn.SetTooltipText(cancelTooltip)
+
+				box.PackEnd(okBtn, false, true, 2)
+				box.PackEnd(cancelBtn, false, true, 2)
+
+				okBtn.Connect("clicked", func() {
+					if d.widgetsToProject() {
+						d.self.Response(gtk.RESPONSE_OK)
+					}
+				})
+				cancelBtn.Connect("clicked", func() {
+					d.self.Response(gtk.RESPONSE_CANCEL)
+				})
+
+				return box
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) createContent() *gtk.Grid {
+	if grid, err := gtk.GridNew(); tr.IsOK(err) {
+		grid.SetBorderWidth(8)
+		grid.SetRowSpacing(8)
+		grid.SetColumnSpacing(8)
+
+		var err error
+		if d.codeLabel, err = gtk.LabelNew(codeLabelText); tr.IsOK(err) {
+			if d.nameLabel, err = gtk.LabelNew(nameLabelText); tr.IsOK(err) {
+				if d.activeLabel, err = gtk.LabelNew(activeLabelText); tr.IsOK(err) {
+					if d.budgetLabel, err = gtk.LabelNew(budgetLabelText); tr.IsOK(err) {
+						if d.codeEntry, err = gtk.EntryNew(); tr.IsOK(err) {
+							if d.nameEntry, err = gtk.EntryNew(); tr.IsOK(err) {
+								if d.activeBox, err = gtk.CheckButtonNew(); tr.IsOK(err) {
+									if d.budgetSpin, err = gtk.SpinButtonNewWithRange(0, maxBudgetHours, 1); tr.IsOK(err) {
+										d.codeLabel.SetHAlign(gtk.ALIGN_END)
+										d.nameLabel.SetHAlign(gtk.ALIGN_END)
+										d.activeLabel.SetHAlign(gtk.ALIGN_END)
+										d.budgetLabel.SetHAlign(gtk.ALIGN_END)
+										d.codeEntry.SetMaxWidthChars(10)
+										d.nameEntry.SetWidthChars(35)
+										d.activeBox.SetCanFocus(false)
+										d.budgetSpin.SetDigits(1)
+										d.budgetSpin.SetTooltipText(budgetTooltip)
+
+										grid.Attach(d.codeLabel, 0, 0, 1, 1)
+										grid.Attach(d.codeEntry, 1, 0, 1, 1)
+										grid.Attach(d.nameLabel, 0, 1, 1, 1)
+										grid.Attach(d.nameEntry, 1, 1, 1, 1)
+										grid.Attach(d.budgetLabel, 0, 2, 1, 1)
+										grid.Attach(d.budgetSpin, 1, 2, 1, 1)
+										grid.Attach(d.activeLabel, 0, 3, 1, 1)
+										grid.Attach(d.activeBox, 1, 3, 1, 1)
+
+										return grid
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) widgetsToProject() bool {
+	if code, err := d.codeEntry.GetText(); tr.IsOK(err) {
+		if strings.TrimSpace(code) == "" {
+			d.canNotBeEmpty("code")
+			d.codeEntry.GrabFocus()
+			return false
+		}
+		if name, err := d.nameEntry.GetText(); tr.IsOK(err) {
+			if strings.TrimSpace(name) == "" {
+				d.canNotBeEmpty("name")
+				d.nameEntry.GrabFocus()
+				return false
+			}
+			d.project.SetCode(strings.TrimSpace(code))
+			d.project.SetName(strings.TrimSpace(name))
+			d.project.SetActive(d.activeBox.GetActive())
+			d.project.SetBudgetHours(d.budgetSpin.GetValue())
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dialog) projectToWidgets() {
+	d.codeEntry.SetText(d.project.Code())
+	d.nameEntry.SetText(d.project.Name())
+	d.activeBox.SetActive(d.project.Active())
+	d.budgetSpin.SetValue(d.project.BudgetHours())
+	d.codeEntry.GrabFocus()
+}
+
+func (d *Dialog) canNotBeEmpty(name string) {
+	ui.ShowError(d.self, fmt.Sprintf("field '%s' can not be empty!", name))
+}
+
+/********************************************************************
+*                                                                   *
+*                          F A I L U R E S                          *
+*                                                                   *
+********************************************************************/
+
+// SaveFailure tells the user why the project could not be saved.
+func SaveFailure(parent *gtk.Window, p *project.Project, err error) {
+	ui.ShowError(parent, saveErrorText(p, err))
+}
+
+// RemoveFailure tells the user why the project could not be removed.
+func RemoveFailure(parent *gtk.Window, p *project.Project, err error) {
+	ui.ShowError(parent, removeErrorText(p, err))
+}
+
+func saveErrorText(p *project.Project, err error) string {
+	switch {
+	case errors.Is(err, storage.ErrCodeExists):
+		return fmt.Sprintf("project code %s already exists.", p.Code())
+	case errors.Is(err, storage.ErrProjectExists):
+		return fmt.Sprintf("project %s already exists.", p.Name())
+	case errors.Is(err, storage.ErrProjectInUse):
+		return fmt.Sprintf("project %s has saved working times and can't be moved to other company.", p.Code())
+	case errors.Is(err, storage.ErrBusy):
+		return "database is busy, try again later."
+	}
+	return "can't save project data to database."
+}
+
+func removeErrorText(p *project.Project, err error) string {
+	switch {
+	case errors.Is(err, storage.ErrProjectInUse):
+		return fmt.Sprintf("project %s has saved working times and can't be removed.", p.Code())
+	case errors.Is(err, storage.ErrBusy):
+		return "database is busy, try again later."
+	}
+	return "can't remove project from database."
+}
@@ -0,0 +1,394 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package projects
+
+import (
+	"fmt"
+	"strconv"
+
+	"Timelancer/dialog/project"
+	"Timelancer/dialog/ui"
+	companyData "Timelancer/model/company"
+	projectData "Timelancer/model/project"
+
+	"Timelancer/shared/tr"
+	"Timelancer/storage"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	dialogTitleFormat = "projects of %s"
+	cancelBtnText     = "return"
+	addBtnText        = "add new"
+	editBtnText       = "edit"
+	deleteBtnText     = "remove"
+	cancelBtnTooltip  = "close this dialog"
+	addBtnTooltip     = "add new project"
+	editBtnTooltip    = "edit selected project"
+	deleteBtnTooltip  = "remove selected project"
+
+	idColumnIdx     = 0
+	codeColumnIdx   = 1
+	nameColumnIdx   = 2
+	budgetColumnIdx = 3
+	activeColumnIdx = 4
+)
+
+type Dialog struct {
+	self        *gtk.Dialog
+	cancelBtn   *gtk.Button
+	addBtn      *gtk.Button
+	editBtn     *gtk.Button
+	deleteBtn   *gtk.Button
+	scroll      *gtk.ScrolledWindow
+	treeView    *gtk.TreeView
+	listStore   *gtk.ListStore
+	parent      *gtk.Window
+	store       storage.ProjectRepository
+	company     *companyData.Company
+	unsubscribe func()
+}
+
+// New creates the dialog with projects of the company.
+func New(parent *gtk.Window, store storage.ProjectRepository, c *companyData.Company) *Dialog {
+	if dialog, err := gtk.DialogNew(); tr.IsOK(err) {
+		dialog.SetTransientFor(parent)
+		dialog.SetBorderWidth(6)
+		dialog.SetTitle(fmt.Sprintf(dialogTitleFormat, c.Name()))
+
+		instance := &Dialog{self: dialog, parent: parent, store: store, company: c}
+
+		if contentArea, err := dialog.GetContentArea(); tr.IsOK(err) {
+			if buttonBox := instance.createButtons(); buttonBox != nil {
+				if separator, err := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL); tr.IsOK(err) {
+					if instance.createTable() {
+
+						contentArea.PackEnd(buttonBox, false, false, 1)
+						contentArea.PackEnd(separator, true, false, 1)
+						contentArea.PackEnd(instance.scroll, true, true, 1)
+
+						instance.unsubscribe = store.SubscribeProjects(instance.projectsChanged)
+						return instance
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) ShowAll() {
+	d.self.ShowAll()
+	d.self.SetResizable(false)
+}
+
+func (d *Dialog) Run() gtk.ResponseType {
+	return d.self.Run()
+}
+
+func (d *Dialog) Destroy() {
+	d.unsubscribe()
+	d.self.Destroy()
+}
+
+func (d *Dialog) UpdateTable() {
+	d.listStore.Clear()
+	if projectsData, err := d.store.Projects(d.company.ID()); tr.IsOK(err) {
+		for _, p := range projectsData {
+			d.updateDataAtIter(d.listStore.Append(), p)
+		}
+	}
+	d.treeView.GrabFocus()
+	d.updateButtonStates()
+}
+
+func (d *Dialog) projectsChanged() {
+	selected := d.selectedProject()
+	d.UpdateTable()
+	if selected != nil {
+		d.selectRowWithID(selected.ID())
+	}
+}
+
+func (d *Dialog) updateButtonStates() {
+	if _, ok := d.listStore.GetIterFirst(); ok {
+		d.deleteBtn.SetSensitive(true)
+		d.editBtn.SetSensitive(true)
+		return
+	}
+	d.deleteBtn.SetSensitive(false)
+	d.editBtn.SetSensitive(false)
+}
+
+func (d *Dialog) createButtons() *gtk.Box {
+	var err error
+
+	if d.cancelBtn, err = gtk.ButtonNewWithLabel(cancelBtnText); tr.IsOK(err) {
+		if d.addBtn, err = gtk.ButtonNewWithLabel(addBtnText); tr.IsOK(err) {
+			if d.editBtn, err = gtk.ButtonNewWithLabel(editBtnText); tr.IsOK(err) {
+				if d.deleteBtn, err = gtk.ButtonNewWithLabel(deleteBtnText); tr.IsOK(err) {
+					if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); tr.IsOK(err) {
+						d.cancelBtn.SetTooltipText(cancelBtnTooltip)
+						d.addBtn.SetTooltipText(addBtnTooltip)
+						d.editBtn.SetTooltipText(editBtnTooltip)
+						d.deleteBtn.SetTooltipText(deleteBtnTooltip)
+
+						box.PackEnd(d.cancelBtn, false, false, 2)
+						box.PackEnd(d.addBtn, false, false, 2)
+						box.PackEnd(d.editBtn, false, false, 2)
+						box.PackEnd(d.deleteBtn, false, false, 2)
+
+						d.cancelBtn.Connect("clicked", func() {
+							d.self.Response(gtk.RESPONSE_OK)
+						})
+						d.addBtn.Connect("clicked", d.addActionHandler)
+						d.editBtn.Connect("clicked", d.editActionHandler)
+						d.deleteBtn.Connect("clicked", d.deleteActionHandler)
+
+						return box
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+/********************************************************************
+*                                                                   *
+*                B U T T O N   H A N D L E R S                      *
+*                                                                   *
+********************************************************************/
+
+func (d *Dialog) addActionHandler() {
+	if dialog := project.New(&d.self.Window, d.company.ID(), nil); dialog != nil {
+		defer dialog.Destroy()
+
+		dialog.ShowAll()
+		if dialog.Run() == gtk.RESPONSE_OK {
+			if p := dialog.Project(); p != nil && p.Valid() {
+				err := d.store.SaveProject(p)
+				if err == nil {
+					d.UpdateTable()
+					d.selectRowWithID(p.ID())
+					return
+				}
+				project.SaveFailure(&d.self.Window, p, err)
+			}
+		}
+	}
+}
+
+func (d *Dialog) editActionHandler() {
+	if p := d.selectedProject(); p != nil {
+		if dialog := project.New(&d.self.Window, d.company.ID(), p); dialog != nil {
+			defer dialog.Destroy()
+
+			dialog.ShowAll()
+			if dialog.Run() == gtk.RESPONSE_OK {
+				if p := dialog.Project(); p != nil && p.Valid() {
+					err := d.store.SaveProject(p)
+					if err == nil {
+						d.updateDataInSelectedRow(p)
+						return
+					}
+					project.SaveFailure(&d.self.Window, p, err)
+				}
+			}
+		}
+	}
+}
+
+// deleteActionHandler removes selected in table project from database.
+func (d *Dialog) deleteActionHandler() {
+	if iter := d.currentSelectionIter(); iter != nil {
+		if p := d.projectAtIter(iter); p != nil {
+			if err := d.store.RemoveProject(p); err != nil {
+				project.RemoveFailure(&d.self.Window, p, err)
+				return
+			}
+			d.listStore.Remove(iter)
+		}
+	}
+}
+
+/********************************************************************
+*                                                                   *
+*                             T A B L E                             *
+*                                                                   *
+********************************************************************/
+
+func (d *Dialog) createTable() bool {
+	if scroll, err := gtk.ScrolledWindowNew(nil, nil); tr.IsOK(err) {
+		if treeView, listStore := d.setupTreeView(); treeView != nil {
+			d.scroll = scroll
+			d.treeView = treeView
+			d.listStore = listStore
+
+			d.scroll.SetSizeRequest(500, 250)
+			d.scroll.Add(d.treeView)
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dialog) setupTreeView() (*gtk.TreeView, *gtk.ListStore) {
+	if treeView, err := gtk.TreeViewNew(); tr.IsOK(err) {
+		if idColumn := ui.TextColumn("id", idColumnIdx); idColumn != nil {
+			if codeColumn := ui.TextColumn("code", codeColumnIdx); codeColumn != nil {
+				if nameColumn := ui.TextColumn("name", nameColumnIdx); nameColumn != nil {
+					if budgetColumn := ui.TextColumn("budget", budgetColumnIdx); budgetColumn != nil {
+						if activeColumn := d.createToggleColumn("active", activeColumnIdx); activeColumn != nil {
+							idColumn.SetVisible(false)
+
+							treeView.AppendColumn(idColumn)
+							treeView.AppendColumn(codeColumn)
+							treeView.AppendColumn(nameColumn)
+							treeView.AppendColumn(budgetColumn)
+							treeView.AppendColumn(activeColumn)
+							treeView.ColumnsAutosize()
+
+							if listStore, err := gtk.ListStoreNew(glib.TYPE_INT, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_BOOLEAN); tr.IsOK(err) {
+								treeView.SetModel(listStore)
+
+								if selection, err := treeView.GetSelection(); tr.IsOK(err) {
+									selection.SetMode(gtk.SELECTION_SINGLE)
+									return treeView, listStore
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (d *Dialog) createToggleColumn(title string, idx int) *gtk.TreeViewColumn {
+	if renderer, err := gtk.CellRendererToggleNew(); tr.IsOK(err) {
+		renderer.SetActivatable(true)
+		renderer.Connect("toggled", func(p *gtk.CellRendererToggle, rowAsString string) {
+			if row, err := strconv.Atoi(rowAsString); tr.IsOK(err) {
+				if path, err := gtk.TreePathNewFromIndicesv([]int{row}); tr.IsOK(err) {
+					if iter, err := d.listStore.GetIter(path); tr.IsOK(err) {
+						if p := d.projectAtIter(iter); p != nil {
+							p.SetActive(!p.Active())
+							if err := d.store.SaveProject(p); tr.IsOK(err) {
+								d.listStore.SetValue(iter, activeColumnIdx, p.Active())
+							}
+						}
+					}
+				}
+			}
+		})
+		if column, err := gtk.TreeViewColumnNewWithAttribute(title, renderer, "active", idx); tr.IsOK(err) {
+			return column
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) currentSelectionIter() *gtk.TreeIter {
+	if selection, err := d.treeView.GetSelection(); tr.IsOK(err) {
+		if _, iter, ok := selection.GetSelected(); ok {
+			return iter
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) selectedProject() *projectData.Project {
+	if iter := d.currentSelectionIter(); iter != nil {
+		return d.projectAtIter(iter)
+	}
+	return nil
+}
+
+func (d *Dialog) projectAtIter(iter *gtk.TreeIter) *projectData.Project {
+	if id, ok := d.getID(iter); ok {
+		if p, err := d.store.ProjectWithID(id); err == nil {
+			return p
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) getID(iter *gtk.TreeIter) (int, bool) {
+	if value, err := d.listStore.GetValue(iter, idColumnIdx); tr.IsOK(err) {
+		if idValue, err := value.GoValue(); tr.IsOK(err) {
+			if id, ok := idValue.(int); ok {
+				return id, true
+			}
+		}
+	}
+	return -1, false
+}
+
+func (d *Dialog) iterForID(id int) *gtk.TreeIter {
+	if iter, ok := d.listStore.GetIterFirst(); ok {
+		if v, ok := d.getID(iter); ok && v == id {
+			return iter
+		}
+		for d.listStore.IterNext(iter) {
+			if v, ok := d.getID(iter); ok && v == id {
+				return iter
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) selectRowWithID(id int) {
+	if iter := d.iterForID(id); iter != nil {
+		if selection, err := d.treeView.GetSelection(); tr.IsOK(err) {
+			selection.SelectIter(iter)
+		}
+	}
+}
+
+func (d *Dialog) updateDataInSelectedRow(p *projectData.Project) {
+	d.updateDataAtIter(d.currentSelectionIter(), p)
+}
+
+func (d *Dialog) updateDataAtIter(iter *gtk.TreeIter, p *projectData.Project) {
+	if iter != nil && p != nil {
+		budget := ""
+		if p.HasBudget() {
+			budget = fmt.Sprintf("%gh", p.BudgetHours())
+		}
+		d.listStore.SetValue(iter, idColumnIdx, p.ID())
+		d.listStore.SetValue(iter, codeColumnIdx, p.Code())
+		d.listStore.SetValue(iter, nameColumnIdx, p.Name())
+		d.listStore.SetValue(iter, budgetColumnIdx, budget)
+		d.listStore.SetValue(iter, activeColumnIdx, p.Active())
+	}
+}
@@ -0,0 +1,302 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package rate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"Timelancer/dialog/ui"
+	"Timelancer/model/project"
+	"Timelancer/model/rate"
+	"Timelancer/shared/tr"
+	"Timelancer/storage"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	dialogTitle       = "hourly rate"
+	projectLabelText  = "project:"
+	amountLabelFormat = "per hour (%s):"
+	fromLabelText     = "valid from:"
+	untilLabelText    = "valid until:"
+	allProjectsText   = "all projects"
+	saveBtnText       = "save"
+	cancelBtnText     = "cancel"
+	saveTooltip       = "save data to database"
+	cancelTooltip     = "do nothing"
+	amountTooltip     = "e.g. 120 or 120.50"
+	fromTooltip       = "YYYY-MM-DD, empty means always"
+	untilTooltip      = "YYYY-MM-DD (the first day without this rate), empty means no end"
+	dateFormat        = "2006-01-02"
+)
+
+type Dialog struct {
+	self         *gtk.Dialog
+	projectLabel *gtk.Label
+	amountLabel  *gtk.Label
+	fromLabel    *gtk.Label
+	untilLabel   *gtk.Label
+	projectCombo *gtk.ComboBoxText
+	amountEntry  *gtk.Entry
+	fromEntry    *gtk.Entry
+	untilEntry   *gtk.Entry
+	projects     []*project.Project
+	currency     string
+	rate         *rate.Rate
+}
+
+// New creates the dialog for the rate, a new rate of the company
+// is created if r is nil. Projects are the projects of the company.
+func New(win *gtk.Window, companyID int, currency string, projects []*project.Project, r *rate.Rate) *Dialog {
+	if dialog, err := gtk.DialogNew(); tr.IsOK(err) {
+		dialog.SetTransientFor(win)
+		dialog.SetBorderWidth(6)
+		dialog.SetTitle(dialogTitle)
+
+		instance := &Dialog{self: dialog, projects: projects, currency: currency, rate: r}
+		if instance.rate == nil {
+			instance.rate = rate.New(companyID)
+		}
+
+		if contentArea, err := dialog.GetContentArea(); tr.IsOK(err) {
+			if buttonBox := instance.createButtons(); buttonBox != nil {
+				if separator, err := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL); tr.IsOK(err) {
+					if contentGrid := instance.createContent(); contentGrid != nil {
+						contentArea.SetBorderWidth(4)
+						contentArea.SetSpacing(4)
+
+						contentArea.PackEnd(buttonBox, false, false, 0)
+						contentArea.PackEnd(separator, true, true, 1)
+						contentArea.PackEnd(contentGrid, false, false, 0)
+						return instance
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) ShowAll() {
+	d.rateToWidgets()
+	d.self.ShowAll()
+	d.self.SetResizable(false)
+}
+
+func (d *Dialog) Run() gtk.ResponseType {
+	return d.self.Run()
+}
+
+func (d *Dialog) Destroy() {
+	d.self.Destroy()
+}
+
+func (d *Dialog) Rate() *rate.Rate {
+	return d.rate
+}
+
+// FormatDate returns the date as used in the dialog, empty text for zero time.
+func FormatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(dateFormat)
+}
+
+func (d *Dialog) createButtons() *gtk.Box {
+	if okBtn, err := gtk.ButtonNewWithLabel(saveBtnText); tr.IsOK(err) {
+		if cancelBtn, err := gtk.ButtonNewWithLabel(cancelBtnText); tr.IsOK(err) {
+			if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); tr.IsOK(err) {
+				okBtn.SetTooltipText(saveTooltip)
+				cancelBtn.SetTooltipText(cancelTooltip)
+
+				box.PackEnd(okBtn, false, true, 2)
+				box.PackEnd(cancelBtn, false, true, 2)
+
+				okBtn.Connect("clicked", func() {
+					if d.widgetsToRate() {
+						d.self.Response(gtk.RESPONSE_OK)
+					}
+				})
+				cancelBtn.Connect("clicked", func() {
+					d.self.Response(gtk.RESPONSE_CANCEL)
+				})
+
+				return box
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) createContent() *gtk.Grid {
+	if grid, err := gtk.GridNew(); tr.IsOK(err) {
+		grid.SetBorderWidth(8)
+		grid.SetRowSpacing(8)
+		grid.SetColumnSpacing(8)
+
+		var err error
+		if d.projectLabel, err = gtk.LabelNew(projectLabelText); tr.IsOK(err) {
+			if d.amountLabel, err = gtk.LabelNew(fmt.Sprintf(amountLabelFormat, d.currency)); tr.IsOK(err) {
+				if d.fromLabel, err = gtk.LabelNew(fromLabelText); tr.IsOK(err) {
+					if d.untilLabel, err = gtk.LabelNew(untilLabelText); tr.IsOK(err) {
+						if d.projectCombo, err = gtk.ComboBoxTextNew(); tr.IsOK(err) {
+							if d.amountEntry, err = gtk.EntryNew(); tr.IsOK(err) {
+								if d.fromEntry, err = gtk.EntryNew(); tr.IsOK(err) {
+									if d.untilEntry, err = gtk.EntryNew(); tr.IsOK(err) {
+										d.projectLabel.SetHAlign(gtk.ALIGN_END)
+										d.amountLabel.SetHAlign(gtk.ALIGN_END)
+										d.fromLabel.SetHAlign(gtk.ALIGN_END)
+										d.untilLabel.SetHAlign(gtk.ALIGN_END)
+										d.amountEntry.SetMaxWidthChars(12)
+										d.amountEntry.SetTooltipText(amountTooltip)
+										d.fromEntry.SetMaxWidthChars(10)
+										d.fromEntry.SetTooltipText(fromTooltip)
+										d.untilEntry.SetMaxWidthChars(10)
+										d.untilEntry.SetTooltipText(untilTooltip)
+
+										d.projectCombo.AppendText(allProjectsText)
+										for _, p := range d.projects {
+											d.projectCombo.AppendText(p.Name())
+										}
+
+										grid.Attach(d.projectLabel, 0, 0, 1, 1)
+										grid.Attach(d.projectCombo, 1, 0, 1, 1)
+										grid.Attach(d.amountLabel, 0, 1, 1, 1)
+										grid.Attach(d.amountEntry, 1, 1, 1, 1)
+										grid.Attach(d.fromLabel, 0, 2, 1, 1)
+										grid.Attach(d.fromEntry, 1, 2, 1, 1)
+										grid.Attach(d.untilLabel, 0, 3, 1, 1)
+										grid.Attach(d.untilEntry, 1, 3, 1, 1)
+
+										return grid
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) widgetsToRate() bool {
+	if text, err := d.amountEntry.GetText(); tr.IsOK(err) {
+		cents, err := rate.ParseAmount(text)
+		if err != nil {
+			d.invalidValue("per hour")
+			d.amountEntry.GrabFocus()
+			return false
+		}
+		from, ok := d.dateFromEntry(d.fromEntry, "valid from")
+		if !ok {
+			return false
+		}
+		until, ok := d.dateFromEntry(d.untilEntry, "valid until")
+		if !ok {
+			return false
+		}
+		if !from.IsZero() && !until.IsZero() && !from.Before(until) {
+			d.invalidValue("valid until")
+			d.untilEntry.GrabFocus()
+			return false
+		}
+
+		d.rate.SetProjectID(rate.AllProjects)
+		if row := d.projectCombo.GetActive(); row > 0 && row <= len(d.projects) {
+			d.rate.SetProjectID(d.projects[row-1].ID())
+		}
+		d.rate.SetCentsPerHour(cents)
+		d.rate.SetValidFrom(from)
+		d.rate.SetValidUntil(until)
+		return true
+	}
+	return false
+}
+
+func (d *Dialog) rateToWidgets() {
+	active := 0
+	for i, p := range d.projects {
+		if p.ID() == d.rate.ProjectID() {
+			active = i + 1
+		}
+	}
+	d.projectCombo.SetActive(active)
+	d.amountEntry.SetText(rate.FormatAmount(d.rate.CentsPerHour()))
+	d.fromEntry.SetText(FormatDate(d.rate.ValidFrom()))
+	d.untilEntry.SetText(FormatDate(d.rate.ValidUntil()))
+	d.amountEntry.GrabFocus()
+}
+
+// dateFromEntry reads the date (local midnight) from the entry, empty entry gives zero time.
+func (d *Dialog) dateFromEntry(entry *gtk.Entry, name string) (time.Time, bool) {
+	if text, err := entry.GetText(); tr.IsOK(err) {
+		if text = strings.TrimSpace(text); text == "" {
+			return time.Time{}, true
+		}
+		if t, err := time.ParseInLocation(dateFormat, text, time.Local); err == nil {
+			return t, true
+		}
+		d.invalidValue(name)
+		entry.GrabFocus()
+	}
+	return time.Time{}, false
+}
+
+func (d *Dialog) invalidValue(name string) {
+	ui.ShowError(d.self, fmt.Sprintf("field '%s' has invalid value!", name))
+}
+
+/********************************************************************
+*                                                                   *
+*                          F A I L U R E S                          *
+*                                                                   *
+********************************************************************/
+
+// SaveFailure tells the user why the rate could not be saved.
+func SaveFailure(parent *gtk.Window, err error) {
+	ui.ShowError(parent, saveErrorText(err))
+}
+
+func saveErrorText(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrWrongProject):
+		return "the project belongs to another company."
+	case errors.Is(err, storage.ErrNotFound):
+		return "the company or the project doesn't exist any more."
+	case errors.Is(err, storage.ErrBusy):
+		return "database is busy, try again later."
+	}
+	return "can't save rate to database."
+}
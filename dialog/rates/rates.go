@@ -0,0 +1,366 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package rates
+
+import (
+	"fmt"
+
+	"Timelancer/dialog/rate"
+	"Timelancer/dialog/ui"
+	companyData "Timelancer/model/company"
+	rateData "Timelancer/model/rate"
+
+	"Timelancer/shared/tr"
+	"Timelancer/storage"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	dialogTitleFormat = "hourly rates of %s"
+	cancelBtnText     = "return"
+	addBtnText        = "add new"
+	editBtnText       = "edit"
+	deleteBtnText     = "remove"
+	cancelBtnTooltip  = "close this dialog"
+	addBtnTooltip     = "add new rate"
+	editBtnTooltip    = "edit selected rate"
+	deleteBtnTooltip  = "remove selected rate"
+	allProjectsText   = "all projects"
+
+	idColumnIdx      = 0
+	projectColumnIdx = 1
+	rateColumnIdx    = 2
+	fromColumnIdx    = 3
+	untilColumnIdx   = 4
+)
+
+type Dialog struct {
+	self        *gtk.Dialog
+	cancelBtn   *gtk.Button
+	addBtn      *gtk.Button
+	editBtn     *gtk.Button
+	deleteBtn   *gtk.Button
+	scroll      *gtk.ScrolledWindow
+	treeView    *gtk.TreeView
+	listStore   *gtk.ListStore
+	parent      *gtk.Window
+	store       storage.Repositories
+	company     *companyData.Company
+	rates       map[int64]*rateData.Rate
+	unsubscribe func()
+}
+
+// New creates the dialog with hourly rates of the company.
+func New(parent *gtk.Window, store storage.Repositories, c *companyData.Company) *Dialog {
+	if dialog, err := gtk.DialogNew(); tr.IsOK(err) {
+		dialog.SetTransientFor(parent)
+		dialog.SetBorderWidth(6)
+		dialog.SetTitle(fmt.Sprintf(dialogTitleFormat, c.Name()))
+
+		instance := &Dialog{self: dialog, parent: parent, store: store, company: c}
+
+		if contentArea, err := dialog.GetContentArea(); tr.IsOK(err) {
+			if buttonBox := instance.createButtons(); buttonBox != nil {
+				if separator, err := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL); tr.IsOK(err) {
+					if instance.createTable() {
+
+						contentArea.PackEnd(buttonBox, false, false, 1)
+						contentArea.PackEnd(separator, true, false, 1)
+						contentArea.PackEnd(instance.scroll, true, true, 1)
+
+						instance.unsubscribe = store.SubscribeRates(instance.ratesChanged)
+						return instance
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) ShowAll() {
+	d.self.ShowAll()
+	d.self.SetResizable(false)
+}
+
+func (d *Dialog) Run() gtk.ResponseType {
+	return d.self.Run()
+}
+
+func (d *Dialog) Destroy() {
+	d.unsubscribe()
+	d.self.Destroy()
+}
+
+func (d *Dialog) UpdateTable() {
+	d.listStore.Clear()
+	d.rates = make(map[int64]*rateData.Rate)
+	if ratesData, err := d.store.Rates(d.company.ID()); tr.IsOK(err) {
+		projectNames := d.projectNames()
+		for _, r := range ratesData {
+			d.rates[r.ID()] = r
+			d.updateDataAtIter(d.listStore.Append(), r, projectNames)
+		}
+	}
+	d.treeView.GrabFocus()
+	d.updateButtonStates()
+}
+
+func (d *Dialog) ratesChanged() {
+	selected := d.selectedRate()
+	d.UpdateTable()
+	if selected != nil {
+		d.selectRowWithID(selected.ID())
+	}
+}
+
+func (d *Dialog) updateButtonStates() {
+	if _, ok := d.listStore.GetIterFirst(); ok {
+		d.deleteBtn.SetSensitive(true)
+		d.editBtn.SetSensitive(true)
+		return
+	}
+	d.deleteBtn.SetSensitive(false)
+	d.editBtn.SetSensitive(false)
+}
+
+func (d *Dialog) createButtons() *gtk.Box {
+	var err error
+
+	if d.cancelBtn, err = gtk.ButtonNewWithLabel(cancelBtnText); tr.IsOK(err) {
+		if d.addBtn, err = gtk.ButtonNewWithLabel(addBtnText); tr.IsOK(err) {
+			if d.editBtn, err = gtk.ButtonNewWithLabel(editBtnText); tr.IsOK(err) {
+				if d.deleteBtn, err = gtk.ButtonNewWithLabel(deleteBtnText); tr.IsOK(err) {
+					if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); tr.IsOK(err) {
+						d.cancelBtn.SetTooltipText(cancelBtnTooltip)
+						d.addBtn.SetTooltipText(addBtnTooltip)
+						d.editBtn.SetTooltipText(editBtnTooltip)
+						d.deleteBtn.SetTooltipText(deleteBtnTooltip)
+
+						box.PackEnd(d.cancelBtn, false, false, 2)
+						box.PackEnd(d.addBtn, false, false, 2)
+						box.PackEnd(d.editBtn, false, false, 2)
+						box.PackEnd(d.deleteBtn, false, false, 2)
+
+						d.cancelBtn.Connect("clicked", func() {
+							d.self.Response(gtk.RESPONSE_OK)
+						})
+						d.addBtn.Connect("clicked", d.addActionHandler)
+						d.editBtn.Connect("clicked", d.editActionHandler)
+						d.deleteBtn.Connect("clicked", d.deleteActionHandler)
+
+						return box
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+/********************************************************************
+*                                                                   *
+*                B U T T O N   H A N D L E R S                      *
+*                                                                   *
+********************************************************************/
+
+func (d *Dialog) addActionHandler() {
+	d.runRateDialog(nil)
+}
+
+func (d *Dialog) editActionHandler() {
+	if r := d.selectedRate(); r != nil {
+		d.runRateDialog(r)
+	}
+}
+
+// deleteActionHandler removes selected in table rate from database.
+func (d *Dialog) deleteActionHandler() {
+	if iter := d.currentSelectionIter(); iter != nil {
+		if r := d.rateAtIter(iter); r != nil {
+			if err := d.store.RemoveRate(r); tr.IsOK(err) {
+				d.UpdateTable()
+			}
+		}
+	}
+}
+
+func (d *Dialog) runRateDialog(r *rateData.Rate) {
+	if projectsData, err := d.store.Projects(d.company.ID()); tr.IsOK(err) {
+		if dialog := rate.New(&d.self.Window, d.company.ID(), d.company.Currency(), projectsData, r); dialog != nil {
+			defer dialog.Destroy()
+
+			dialog.ShowAll()
+			if dialog.Run() == gtk.RESPONSE_OK {
+				if r := dialog.Rate(); r != nil && r.Valid() {
+					err := d.store.SaveRate(r)
+					if err == nil {
+						d.UpdateTable()
+						d.selectRowWithID(r.ID())
+						return
+					}
+					rate.SaveFailure(&d.self.Window, err)
+				}
+			}
+		}
+	}
+}
+
+/********************************************************************
+*                                                                   *
+*                             T A B L E                             *
+*                                                                   *
+********************************************************************/
+
+func (d *Dialog) createTable() bool {
+	if scroll, err := gtk.ScrolledWindowNew(nil, nil); tr.IsOK(err) {
+		if treeView, listStore := d.setupTreeView(); treeView != nil {
+			d.scroll = scroll
+			d.treeView = treeView
+			d.listStore = listStore
+
+			d.scroll.SetSizeRequest(500, 250)
+			d.scroll.Add(d.treeView)
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dialog) setupTreeView() (*gtk.TreeView, *gtk.ListStore) {
+	if treeView, err := gtk.TreeViewNew(); tr.IsOK(err) {
+		if idColumn := ui.TextColumn("id", idColumnIdx); idColumn != nil {
+			if projectColumn := ui.TextColumn("project", projectColumnIdx); projectColumn != nil {
+				if rateColumn := ui.TextColumn("per hour", rateColumnIdx); rateColumn != nil {
+					if fromColumn := ui.TextColumn("from", fromColumnIdx); fromColumn != nil {
+						if untilColumn := ui.TextColumn("until", untilColumnIdx); untilColumn != nil {
+							idColumn.SetVisible(false)
+
+							treeView.AppendColumn(idColumn)
+							treeView.AppendColumn(projectColumn)
+							treeView.AppendColumn(rateColumn)
+							treeView.AppendColumn(fromColumn)
+							treeView.AppendColumn(untilColumn)
+							treeView.ColumnsAutosize()
+
+							if listStore, err := gtk.ListStoreNew(glib.TYPE_INT64, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING); tr.IsOK(err) {
+								treeView.SetModel(listStore)
+
+								if selection, err := treeView.GetSelection(); tr.IsOK(err) {
+									selection.SetMode(gtk.SELECTION_SINGLE)
+									return treeView, listStore
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (d *Dialog) currentSelectionIter() *gtk.TreeIter {
+	if selection, err := d.treeView.GetSelection(); tr.IsOK(err) {
+		if _, iter, ok := selection.GetSelected(); ok {
+			return iter
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) selectedRate() *rateData.Rate {
+	if iter := d.currentSelectionIter(); iter != nil {
+		return d.rateAtIter(iter)
+	}
+	return nil
+}
+
+func (d *Dialog) rateAtIter(iter *gtk.TreeIter) *rateData.Rate {
+	if id, ok := d.getID(iter); ok {
+		if r, ok := d.rates[id]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) getID(iter *gtk.TreeIter) (int64, bool) {
+	if value, err := d.listStore.GetValue(iter, idColumnIdx); tr.IsOK(err) {
+		if idValue, err := value.GoValue(); tr.IsOK(err) {
+			if id, ok := idValue.(int64); ok {
+				return id, true
+			}
+		}
+	}
+	return -1, false
+}
+
+func (d *Dialog) iterForID(id int64) *gtk.TreeIter {
+	if iter, ok := d.listStore.GetIterFirst(); ok {
+		if v, ok := d.getID(iter); ok && v == id {
+			return iter
+		}
+		for d.listStore.IterNext(iter) {
+			if v, ok := d.getID(iter); ok && v == id {
+				return iter
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) selectRowWithID(id int64) {
+	if iter := d.iterForID(id); iter != nil {
+		if selection, err := d.treeView.GetSelection(); tr.IsOK(err) {
+			selection.SelectIter(iter)
+		}
+	}
+}
+
+func (d *Dialog) projectNames() map[int]string {
+	names := map[int]string{rateData.AllProjects: allProjectsText}
+	if projectsData, err := d.store.Projects(d.company.ID()); tr.IsOK(err) {
+		for _, p := range projectsData {
+			names[p.ID()] = p.Name()
+		}
+	}
+	return names
+}
+
+func (d *Dialog) updateDataAtIter(iter *gtk.TreeIter, r *rateData.Rate, projectNames map[int]string) {
+	if iter != nil && r != nil {
+		d.listStore.SetValue(iter, idColumnIdx, r.ID())
+		d.listStore.SetValue(iter, projectColumnIdx, projectNames[r.ProjectID()])
+		d.listStore.SetValue(iter, rateColumnIdx, rateData.FormatAmount(r.CentsPerHour())+" "+d.company.Currency())
+		d.listStore.SetValue(iter, fromColumnIdx, rate.FormatDate(r.ValidFrom()))
+		d.listStore.SetValue(iter, untilColumnIdx, rate.FormatDate(r.ValidUntil()))
+	}
+}
@@ -29,14 +29,19 @@
 package statistic
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
-	"Timelancer/model/company"
+	"Timelancer/dialog/invoice"
+	"Timelancer/dialog/tags"
+	"Timelancer/dialog/ui"
+	"Timelancer/model/rate"
 	"Timelancer/shared"
 	"Timelancer/shared/tr"
-	"Timelancer/sqlite"
-	"Timelancer/sqlite/row"
+	"Timelancer/storage"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 )
@@ -47,23 +52,45 @@ const (
 	companyTooltip   = "companies you work for"
 	periodLabelText  = "period:"
 	periodTooltip    = "predefined periods of time"
+	projectLabelText = "project:"
+	projectTooltip   = "projects of the selected company"
+	allProjectsText  = "All"
+	tagLabelText     = "tag:"
+	tagTooltip       = "show working times with the tag only"
+	anyTagText       = "any"
 	//startLabelText     = "start date"
 	//endLabelText       = "end date"
 	cancelBtnText    = "return"
 	exportBtnText    = "export"
 	cancelBtnTooltip = "close this dialog"
 	exportBtnTooltip = "save records to csv file"
-
-	idColumnIdx      = 0
-	idColumnName     = "id"
-	nameColumnIdx    = 1
-	nameColumnName   = "name"
-	startColumnIdx   = 2
-	startColumnName  = "start"
-	finishColumnIdx  = 3
-	finishColumnName = "finish"
-	periodColumnIdx  = 4
-	perionColumnName = "period"
+	tagsBtnText      = "tags"
+	tagsBtnTooltip   = "change tags of the selected working time"
+	noTotalsText     = "no tagged working times"
+	noAmountsText    = "no billable working times"
+	invoiceBtnText   = "invoice"
+	invoiceTooltip   = "issue the invoice of working times (not invoiced yet) of the selected company chosen from shown ones"
+	notInvoicedText  = "not invoiced only"
+	noInvoiceText    = "there are no working times to invoice."
+
+	idColumnIdx           = 0
+	idColumnName          = "id"
+	nameColumnIdx         = 1
+	nameColumnName        = "name"
+	startColumnIdx        = 2
+	startColumnName       = "start"
+	finishColumnIdx       = 3
+	finishColumnName      = "finish"
+	periodColumnIdx       = 4
+	perionColumnName      = "period"
+	descriptionColumnIdx  = 5
+	descriptionColumnName = "description"
+	tagsColumnIdx         = 6
+	tagsColumnName        = "tags"
+	projectColumnIdx      = 7
+	projectColumnName     = "project"
+	amountColumnIdx       = 8
+	amountColumnName      = "amount"
 )
 
 var (
@@ -73,41 +100,85 @@ var (
 type Dialog struct {
 	self            *gtk.Dialog
 	parent          *gtk.Window
+	store           storage.Repositories
 	companyLabel    *gtk.Label
 	companyComboBox *gtk.ComboBoxText
 	periodLabel     *gtk.Label
 	periodComboBox  *gtk.ComboBoxText
+	projectLabel    *gtk.Label
+	projectComboBox *gtk.ComboBoxText
+	tagLabel        *gtk.Label
+	tagComboBox     *gtk.ComboBoxText
 	cancelBtn       *gtk.Button
 	exportBtn       *gtk.Button
+	tagsBtn         *gtk.Button
+	invoiceBtn      *gtk.Button
+	notInvoicedBox  *gtk.CheckButton
 	treeView        *gtk.TreeView
 	listStore       *gtk.ListStore
-
-	ids []int
+	totalsLabel     *gtk.Label
+	amountsLabel    *gtk.Label
+
+	ids         []int
+	refilling   bool // the company combo is filled again, its changes are ignored
+	projectIDs  []int
+	tagIDs      []int64
+	amounts     map[string]int64
+	filter      storage.EntryFilter
+	ctx         context.Context
+	cancelQuery context.CancelFunc
+	unsubscribe func()
 }
 
-func New(parent *gtk.Window) *Dialog {
+// New creates the dialog, running reports are stopped when ctx is done.
+func New(ctx context.Context, parent *gtk.Window, store storage.Repositories) *Dialog {
 	if dialog, err := gtk.DialogNew(); tr.IsOK(err) {
 		dialog.SetTransientFor(parent)
 		dialog.SetBorderWidth(6)
 		dialog.SetTitle(dialogTitle)
 		dialog.SetSizeRequest(400, 200)
 
-		instance := &Dialog{self: dialog, parent: parent}
+		instance := &Dialog{self: dialog, parent: parent, store: store, ctx: ctx, cancelQuery: func() {}}
+		instance.filter = storage.EntryFilter{CompanyID: storage.AllCompanies, ProjectID: storage.AllProjects, TagID: storage.AnyTag}
 
 		if contentArea, err := dialog.GetContentArea(); tr.IsOK(err) {
 			if buttonBox := instance.createButtons(); buttonBox != nil {
 				if separatorBottom, err := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL); tr.IsOK(err) {
-					if scroll := instance.createTable(); scroll != nil {
-						if separatorTop, err := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL); tr.IsOK(err) {
-							if toolbarGrid := instance.createToolbar(); toolbarGrid != nil {
-
-								contentArea.PackEnd(buttonBox, false, false, 1)
-								contentArea.PackEnd(separatorBottom, true, false, 1)
-								contentArea.PackEnd(scroll, true, true, 1)
-								contentArea.PackEnd(separatorTop, true, false, 1)
-								contentArea.PackEnd(toolbarGrid, true, true, 1)
-
-								return instance
+					if totalsLabel, err := gtk.LabelNew(""); tr.IsOK(err) {
+						if amountsLabel, err := gtk.LabelNew(""); tr.IsOK(err) {
+							if scroll := instance.createTable(); scroll != nil {
+								if separatorTop, err := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL); tr.IsOK(err) {
+									if toolbarGrid := instance.createToolbar(); toolbarGrid != nil {
+										totalsLabel.SetHAlign(gtk.ALIGN_START)
+										totalsLabel.SetLineWrap(true)
+										amountsLabel.SetHAlign(gtk.ALIGN_START)
+										amountsLabel.SetLineWrap(true)
+										instance.totalsLabel = totalsLabel
+										instance.amountsLabel = amountsLabel
+
+										contentArea.PackEnd(buttonBox, false, false, 1)
+										contentArea.PackEnd(separatorBottom, true, false, 1)
+										contentArea.PackEnd(amountsLabel, false, false, 1)
+										contentArea.PackEnd(totalsLabel, false, false, 1)
+										contentArea.PackEnd(scroll, true, true, 1)
+										contentArea.PackEnd(separatorTop, true, false, 1)
+										contentArea.PackEnd(toolbarGrid, true, true, 1)
+
+										unsubscribeCompanies := store.SubscribeCompanies(instance.companiesChanged)
+										unsubscribeTimers := store.SubscribeTimers(instance.selectedCompanyChanged)
+										unsubscribeProjects := store.SubscribeProjects(instance.projectsChanged)
+										unsubscribeTags := store.SubscribeTags(instance.populateTagComboBox)
+										unsubscribeRates := store.SubscribeRates(instance.updateTable)
+										instance.unsubscribe = func() {
+											unsubscribeCompanies()
+											unsubscribeTimers()
+											unsubscribeProjects()
+											unsubscribeTags()
+											unsubscribeRates()
+										}
+										return instance
+									}
+								}
 							}
 						}
 					}
@@ -122,6 +193,7 @@ func New(parent *gtk.Window) *Dialog {
 func (d *Dialog) ShowAll() {
 	d.populateCompanyComboBox()
 	d.populatePeriodComboBox()
+	d.populateTagComboBox()
 	d.DidSelectAllCompanies()
 
 	d.self.ShowAll()
@@ -133,81 +205,124 @@ func (d *Dialog) Run() gtk.ResponseType {
 }
 
 func (d *Dialog) Destroy() {
+	d.unsubscribe()
+	d.cancelQuery()
 	d.self.Destroy()
 }
 
 func (d *Dialog) DidSelectAllCompanies() {
-	query := "SELECT timer.id, timer.start, timer.finish, company.name FROM timer,company WHERE timer.company_id=company.id ORDER BY timer.id DESC"
-	d.updateTable(query)
+	d.selectCompany(storage.AllCompanies)
 }
 
 func (d *Dialog) DidSelectecCompanyWithID(id int) {
 	tr.Info("id: %d", id)
-	query := fmt.Sprintf("SELECT timer.id, timer.start, timer.finish, company.name FROM timer,company WHERE timer.company_id=%d AND timer.company_id=company.id ORDER BY timer.id DESC", id)
-	d.updateTable(query)
+	d.selectCompany(id)
 }
 
-func (d *Dialog) updateTable(query string) {
-	d.listStore.Clear()
+// selectCompany changes the filter, projects of the previous company
+// are not selected any more.
+func (d *Dialog) selectCompany(id int) {
+	if id != d.filter.CompanyID {
+		d.filter.CompanyID = id
+		d.filter.ProjectID = storage.AllProjects
+	}
+	d.invoiceBtn.SetSensitive(d.filter.CompanyID != storage.AllCompanies)
+	d.populateProjectComboBox()
+	d.updateTable()
+}
 
-	sqlite.SQLite().SelectAndHandle(query, func(r row.Row) {
-		//fmt.Printf("%+v\n", r)
-		if iter := d.listStore.Append(); iter != nil {
-			if id, ok := getID(r); ok {
-				if name, ok := getName(r); ok {
-					if start, ok := getStart(r); ok {
-						if finish, ok := getFinish(r); ok {
-							d.listStore.SetValue(iter, idColumnIdx, id)
-							d.listStore.SetValue(iter, nameColumnIdx, name)
-							d.listStore.SetValue(iter, startColumnIdx, shared.TimeAsString(start))
-							d.listStore.SetValue(iter, finishColumnIdx, shared.TimeAsString(finish))
-							d.listStore.SetValue(iter, periodColumnIdx, getPeriod(start, finish))
-						}
-					}
+// updateTable reads timers selected by the filter in background
+// (a previous reading is cancelled) and appends rows to the table
+// in the GTK main loop. Totals of tags are read after the rows,
+// amounts per currency are summed up from the rows.
+// The report is run again when timers, companies or rates were changed.
+func (d *Dialog) updateTable() {
+	d.cancelQuery()
+	d.listStore.Clear()
+	d.totalsLabel.SetText("")
+	d.amounts = make(map[string]int64)
+	d.amountsLabel.SetText(amountsText(d.amounts))
+
+	ctx, cancel := context.WithCancel(d.ctx)
+	d.cancelQuery = cancel
+	filter := d.filter
+
+	go func() {
+		err := d.store.TimerEntries(ctx, filter, func(entry storage.TimerEntry) {
+			glib.IdleAdd(func() {
+				// rows of a cancelled reading must not get into the new table
+				if ctx.Err() == nil {
+					d.appendEntry(entry)
 				}
+			})
+		})
+		if err == nil {
+			var totals []storage.TagTotal
+			if totals, err = d.store.TagTotals(ctx, filter); err == nil {
+				text := totalsText(totals)
+				glib.IdleAdd(func() {
+					if ctx.Err() == nil {
+						d.totalsLabel.SetText(text)
+					}
+				})
 			}
 		}
-	})
+		if err != ctx.Err() {
+			tr.IsOK(err)
+		}
+	}()
 }
 
-func getID(r row.Row) (int64, bool) {
-	if id, ok := r["id"]; ok {
-		if id, ok := id.Value.(int64); ok {
-			return id, true
-		}
+// totalsText returns working time per tag, e.g. "meeting: 3h 20min, travel: 1h 05min".
+func totalsText(totals []storage.TagTotal) string {
+	if len(totals) == 0 {
+		return noTotalsText
 	}
-	return -1, false
-}
-func getName(r row.Row) (string, bool) {
-	if name, ok := r["name"]; ok {
-		if name, ok := name.Value.(string); ok {
-			return name, true
-		}
+	items := make([]string, len(totals))
+	for i, total := range totals {
+		items[i] = fmt.Sprintf("%s: %s", total.Name, formatDuration(total.Duration()))
 	}
-	return "", false
+	return strings.Join(items, ", ")
 }
-func getStart(r row.Row) (time.Time, bool) {
-	if start, ok := r["start"]; ok {
-		if start, ok := start.Value.(int64); ok {
-			if t := time.Unix(start, 0); !t.IsZero() {
-				return t, true
-			}
-		}
+
+// amountsText returns billable amounts per currency, e.g. "EUR 1234.50, PLN 300.00".
+func amountsText(amounts map[string]int64) string {
+	if len(amounts) == 0 {
+		return noAmountsText
+	}
+	items := make([]string, 0, len(amounts))
+	for currency, cents := range amounts {
+		items = append(items, currency+" "+rate.FormatAmount(cents))
 	}
-	return time.Time{}, false
+	sort.Strings(items)
+	return strings.Join(items, ", ")
 }
-func getFinish(r row.Row) (time.Time, bool) {
-	if start, ok := r["finish"]; ok {
-		if start, ok := start.Value.(int64); ok {
-			if t := time.Unix(start, 0); !t.IsZero() {
-				return t, true
-			}
+
+func (d *Dialog) appendEntry(entry storage.TimerEntry) {
+	if iter := d.listStore.Append(); iter != nil {
+		d.listStore.SetValue(iter, idColumnIdx, entry.ID)
+		d.listStore.SetValue(iter, nameColumnIdx, entry.CompanyName)
+		d.listStore.SetValue(iter, projectColumnIdx, entry.ProjectName)
+		d.listStore.SetValue(iter, startColumnIdx, shared.TimeAsString(entry.Start))
+		d.listStore.SetValue(iter, finishColumnIdx, shared.TimeAsString(entry.Finish))
+		d.listStore.SetValue(iter, periodColumnIdx, getPeriod(entry.Start, entry.Finish))
+		d.listStore.SetValue(iter, descriptionColumnIdx, entry.Description)
+		d.listStore.SetValue(iter, tagsColumnIdx, entry.Tags)
+		d.listStore.SetValue(iter, amountColumnIdx, "")
+		if entry.CentsPerHour > 0 {
+			amount := entry.Amount()
+			d.listStore.SetValue(iter, amountColumnIdx, rate.FormatAmount(amount)+" "+entry.Currency)
+			d.amounts[entry.Currency] += amount
+			d.amountsLabel.SetText(amountsText(d.amounts))
 		}
 	}
-	return time.Time{}, false
 }
+
 func getPeriod(start, finish time.Time) string {
-	duration := finish.Sub(start)
+	return formatDuration(finish.Sub(start))
+}
+
+func formatDuration(duration time.Duration) string {
 	seconds := uint(duration.Seconds())
 	h, m, s := shared.DurationComponents(seconds)
 	if s >= 30 {
@@ -226,21 +341,32 @@ func (d *Dialog) createButtons() *gtk.Box {
 
 	if d.cancelBtn, err = gtk.ButtonNewWithLabel(cancelBtnText); tr.IsOK(err) {
 		if d.exportBtn, err = gtk.ButtonNewWithLabel(exportBtnText); tr.IsOK(err) {
-			if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); tr.IsOK(err) {
-				d.cancelBtn.SetTooltipText(cancelBtnTooltip)
-				d.exportBtn.SetTooltipText(exportBtnTooltip)
-
-				box.PackEnd(d.cancelBtn, false, false, 2)
-				box.PackEnd(d.exportBtn, false, false, 2)
-
-				d.cancelBtn.Connect("clicked", func() {
-					d.self.Response(gtk.RESPONSE_OK)
-				})
-				d.exportBtn.Connect("clicked", func() {
-					fmt.Println("export data")
-				})
-
-				return box
+			if d.tagsBtn, err = gtk.ButtonNewWithLabel(tagsBtnText); tr.IsOK(err) {
+				if d.invoiceBtn, err = gtk.ButtonNewWithLabel(invoiceBtnText); tr.IsOK(err) {
+					if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); tr.IsOK(err) {
+						d.cancelBtn.SetTooltipText(cancelBtnTooltip)
+						d.exportBtn.SetTooltipText(exportBtnTooltip)
+						d.tagsBtn.SetTooltipText(tagsBtnTooltip)
+						d.invoiceBtn.SetTooltipText(invoiceTooltip)
+
+						box.PackEnd(d.cancelBtn, false, false, 2)
+						box.PackEnd(d.exportBtn, false, false, 2)
+						box.PackStart(d.tagsBtn, false, false, 2)
+						box.PackStart(d.invoiceBtn, false, false, 2)
+
+						d.cancelBtn.Connect("clicked", func() {
+							d.cancelQuery()
+							d.self.Response(gtk.RESPONSE_OK)
+						})
+						d.exportBtn.Connect("clicked", func() {
+							fmt.Println("export data")
+						})
+						d.tagsBtn.Connect("clicked", d.editTags)
+						d.invoiceBtn.Connect("clicked", d.issueInvoice)
+
+						return box
+					}
+				}
 			}
 		}
 	}
@@ -248,6 +374,9 @@ func (d *Dialog) createButtons() *gtk.Box {
 }
 
 func (d *Dialog) selectedCompanyChanged() {
+	if d.refilling {
+		return
+	}
 	if row := d.companyComboBox.GetActive(); row > -1 {
 		if row < len(d.ids) {
 			if id := d.ids[row]; id == -1 {
@@ -259,6 +388,83 @@ func (d *Dialog) selectedCompanyChanged() {
 	}
 }
 
+func (d *Dialog) selectedProjectChanged() {
+	if row := d.projectComboBox.GetActive(); row > -1 && row < len(d.projectIDs) {
+		if id := d.projectIDs[row]; id != d.filter.ProjectID {
+			d.filter.ProjectID = id
+			d.updateTable()
+		}
+	}
+}
+
+func (d *Dialog) projectsChanged() {
+	d.populateProjectComboBox()
+	d.updateTable()
+}
+
+func (d *Dialog) selectedTagChanged() {
+	if row := d.tagComboBox.GetActive(); row > -1 && row < len(d.tagIDs) {
+		if id := d.tagIDs[row]; id != d.filter.TagID {
+			d.filter.TagID = id
+			d.updateTable()
+		}
+	}
+}
+
+// editTags opens the tags dialog for the selected working time.
+// The table is updated by the subscription.
+func (d *Dialog) editTags() {
+	if selection, err := d.treeView.GetSelection(); tr.IsOK(err) {
+		if _, iter, ok := selection.GetSelected(); ok {
+			if value, err := d.listStore.GetValue(iter, idColumnIdx); tr.IsOK(err) {
+				if id, err := value.GoValue(); tr.IsOK(err) {
+					if dialog := tags.New(&d.self.Window, d.store, int64(id.(int))); dialog != nil {
+						defer dialog.Destroy()
+
+						dialog.ShowAll()
+						dialog.Run()
+					}
+				}
+			}
+		}
+	}
+}
+
+// issueInvoice opens the invoice dialog for working times of the selected
+// company shown in the table (invoiced ones are skipped), working times to be
+// billed are chosen in the dialog. The table is updated by the subscription.
+func (d *Dialog) issueInvoice() {
+	if d.filter.CompanyID == storage.AllCompanies {
+		return
+	}
+	if buyer, err := d.store.CompanyWithID(d.filter.CompanyID); tr.IsOK(err) {
+		filter := d.filter
+		filter.NotInvoiced = true
+		var entries []storage.TimerEntry
+		err := d.store.TimerEntries(d.ctx, filter, func(entry storage.TimerEntry) {
+			entries = append(entries, entry)
+		})
+		if !tr.IsOK(err) {
+			return
+		}
+		if len(entries) == 0 {
+			ui.ShowInfo(d.self, invoiceBtnText, noInvoiceText)
+			return
+		}
+		if dialog := invoice.New(&d.self.Window, d.store, buyer, entries); dialog != nil {
+			defer dialog.Destroy()
+
+			dialog.ShowAll()
+			dialog.Run()
+		}
+	}
+}
+
+func (d *Dialog) notInvoicedToggled() {
+	d.filter.NotInvoiced = d.notInvoicedBox.GetActive()
+	d.updateTable()
+}
+
 func (d *Dialog) selectedPersonChanged() {
 	fmt.Println("selectedPersonCahnged")
 }
@@ -267,11 +473,22 @@ func (d *Dialog) createToolbar() *gtk.Grid {
 	if grid, err := gtk.GridNew(); tr.IsOK(err) {
 		if companiesBox := d.createCompanyBox(); companiesBox != nil {
 			if periodBox := d.createPeriodBox(); periodBox != nil {
-				grid.SetColumnSpacing(10)
-				grid.Attach(companiesBox, 0, 0, 1, 1)
-				grid.Attach(periodBox, 1, 0, 1, 1)
-
-				return grid
+				if projectBox := d.createProjectBox(); projectBox != nil {
+					if tagBox := d.createTagBox(); tagBox != nil {
+						if d.notInvoicedBox, err = gtk.CheckButtonNewWithLabel(notInvoicedText); tr.IsOK(err) {
+							d.notInvoicedBox.Connect("toggled", d.notInvoicedToggled)
+
+							grid.SetColumnSpacing(10)
+							grid.Attach(companiesBox, 0, 0, 1, 1)
+							grid.Attach(projectBox, 1, 0, 1, 1)
+							grid.Attach(periodBox, 2, 0, 1, 1)
+							grid.Attach(tagBox, 3, 0, 1, 1)
+							grid.Attach(d.notInvoicedBox, 4, 0, 1, 1)
+
+							return grid
+						}
+					}
+				}
 			}
 		}
 	}
@@ -316,6 +533,44 @@ func (d *Dialog) createPeriodBox() *gtk.Box {
 	return nil
 }
 
+func (d *Dialog) createProjectBox() *gtk.Box {
+	var err error
+
+	if d.projectLabel, err = gtk.LabelNew(projectLabelText); tr.IsOK(err) {
+		if d.projectComboBox, err = gtk.ComboBoxTextNew(); tr.IsOK(err) {
+			if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 2); tr.IsOK(err) {
+				d.projectComboBox.SetTooltipText(projectTooltip)
+				d.projectComboBox.Connect("changed", d.selectedProjectChanged)
+
+				box.PackStart(d.projectLabel, false, false, 2)
+				box.PackStart(d.projectComboBox, true, false, 2)
+
+				return box
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) createTagBox() *gtk.Box {
+	var err error
+
+	if d.tagLabel, err = gtk.LabelNew(tagLabelText); tr.IsOK(err) {
+		if d.tagComboBox, err = gtk.ComboBoxTextNew(); tr.IsOK(err) {
+			if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 2); tr.IsOK(err) {
+				d.tagComboBox.SetTooltipText(tagTooltip)
+				d.tagComboBox.Connect("changed", d.selectedTagChanged)
+
+				box.PackStart(d.tagLabel, false, false, 2)
+				box.PackStart(d.tagComboBox, true, false, 2)
+
+				return box
+			}
+		}
+	}
+	return nil
+}
+
 func (d *Dialog) populateCompanyComboBox() {
 	var ids []int
 
@@ -323,15 +578,99 @@ func (d *Dialog) populateCompanyComboBox() {
 	d.companyComboBox.AppendText("All")
 	ids = append(ids, -1)
 
-	companies := company.CompaniesInUse()
-	for _, c := range companies {
-		d.companyComboBox.AppendText(c.Name())
-		ids = append(ids, c.ID())
+	if companies, err := d.store.CompaniesInUse(); tr.IsOK(err) {
+		for _, c := range companies {
+			d.companyComboBox.AppendText(c.Name())
+			ids = append(ids, c.ID())
+		}
 	}
 	d.companyComboBox.SetActive(0)
 	d.ids = ids
 }
 
+// companiesChanged fills the company filter again (companies may be added,
+// renamed or removed), the selected company stays selected if it still exists.
+func (d *Dialog) companiesChanged() {
+	id := d.filter.CompanyID
+
+	d.refilling = true
+	d.populateCompanyComboBox()
+	for row, companyID := range d.ids {
+		if companyID == id {
+			d.companyComboBox.SetActive(row)
+			break
+		}
+	}
+	d.refilling = false
+
+	d.selectedCompanyChanged()
+}
+
+// populateProjectComboBox fills the project filter with projects
+// of the selected company, the selected project stays selected
+// (if it still exists).
+func (d *Dialog) populateProjectComboBox() {
+	ids := []int{storage.AllProjects}
+	names := []string{allProjectsText}
+	if d.filter.CompanyID != storage.AllCompanies {
+		if data, err := d.store.Projects(d.filter.CompanyID); tr.IsOK(err) {
+			for _, p := range data {
+				ids = append(ids, p.ID())
+				names = append(names, p.Name())
+			}
+		}
+	}
+
+	active := 0
+	for i, id := range ids {
+		if id == d.filter.ProjectID {
+			active = i
+		}
+	}
+	d.filter.ProjectID = ids[active]
+
+	d.projectIDs = nil
+	d.projectComboBox.RemoveAll()
+	for _, name := range names {
+		d.projectComboBox.AppendText(name)
+	}
+	d.projectIDs = ids
+	d.projectComboBox.SetActive(active)
+	d.projectComboBox.SetSensitive(d.filter.CompanyID != storage.AllCompanies)
+}
+
+// populateTagComboBox fills the tag filter, the selected tag stays selected
+// (if it still exists).
+func (d *Dialog) populateTagComboBox() {
+	ids := []int64{storage.AnyTag}
+	names := []string{anyTagText}
+	if data, err := d.store.Tags(); tr.IsOK(err) {
+		for _, t := range data {
+			ids = append(ids, t.ID())
+			names = append(names, t.Name())
+		}
+	}
+
+	active := 0
+	for i, id := range ids {
+		if id == d.filter.TagID {
+			active = i
+		}
+	}
+	d.tagIDs = nil
+	d.tagComboBox.RemoveAll()
+	for _, name := range names {
+		d.tagComboBox.AppendText(name)
+	}
+	d.tagIDs = ids
+	d.tagComboBox.SetActive(active)
+	if active == 0 && d.filter.TagID != storage.AnyTag {
+		// the selected tag was removed
+		d.filter.TagID = storage.AnyTag
+		d.updateTable()
+	}
+}
+
 func (d *Dialog) populatePeriodComboBox() {
 	d.periodComboBox.RemoveAll()
 	for _, text := range periods {
@@ -343,8 +682,8 @@ func (d *Dialog) populatePeriodComboBox() {
 func (d *Dialog) createTable() *gtk.ScrolledWindow {
 	if scroll, err := gtk.ScrolledWindowNew(nil, nil); tr.IsOK(err) {
 		if treeView, err := gtk.TreeViewNew(); tr.IsOK(err) {
-			if appendColumns(treeView) {
-				if store, err := gtk.ListStoreNew(glib.TYPE_INT, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING); tr.IsOK(err) {
+			if d.appendColumns(treeView) {
+				if store, err := gtk.ListStoreNew(glib.TYPE_INT, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING, glib.TYPE_STRING); tr.IsOK(err) {
 					treeView.SetModel(store)
 					if selection, err := treeView.GetSelection(); tr.IsOK(err) {
 						selection.SetMode(gtk.SELECTION_SINGLE)
@@ -363,22 +702,34 @@ func (d *Dialog) createTable() *gtk.ScrolledWindow {
 	return nil
 }
 
-func appendColumns(treeView *gtk.TreeView) bool {
-	if idColumn := createTextColumn(idColumnName, idColumnIdx); idColumn != nil {
-		if nameColumn := createTextColumn(nameColumnName, nameColumnIdx); nameColumn != nil {
-			if startColumn := createTextColumn(startColumnName, startColumnIdx); startColumn != nil {
-				if finishColumn := createTextColumn(finishColumnName, finishColumnIdx); finishColumn != nil {
-					if periodColumn := createTextColumn(perionColumnName, periodColumnIdx); periodColumn != nil {
-						idColumn.SetVisible(false)
-
-						treeView.AppendColumn(idColumn)
-						treeView.AppendColumn(nameColumn)
-						treeView.AppendColumn(startColumn)
-						treeView.AppendColumn(finishColumn)
-						treeView.AppendColumn(periodColumn)
-						treeView.ColumnsAutosize()
-
-						return true
+func (d *Dialog) appendColumns(treeView *gtk.TreeView) bool {
+	if idColumn := ui.TextColumn(idColumnName, idColumnIdx); idColumn != nil {
+		if nameColumn := ui.TextColumn(nameColumnName, nameColumnIdx); nameColumn != nil {
+			if projectColumn := ui.TextColumn(projectColumnName, projectColumnIdx); projectColumn != nil {
+				if startColumn := ui.TextColumn(startColumnName, startColumnIdx); startColumn != nil {
+					if finishColumn := ui.TextColumn(finishColumnName, finishColumnIdx); finishColumn != nil {
+						if periodColumn := ui.TextColumn(perionColumnName, periodColumnIdx); periodColumn != nil {
+							if amountColumn := ui.TextColumn(amountColumnName, amountColumnIdx); amountColumn != nil {
+								if descriptionColumn := d.createDescriptionColumn(); descriptionColumn != nil {
+									if tagsColumn := ui.TextColumn(tagsColumnName, tagsColumnIdx); tagsColumn != nil {
+										idColumn.SetVisible(false)
+
+										treeView.AppendColumn(idColumn)
+										treeView.AppendColumn(nameColumn)
+										treeView.AppendColumn(projectColumn)
+										treeView.AppendColumn(startColumn)
+										treeView.AppendColumn(finishColumn)
+										treeView.AppendColumn(periodColumn)
+										treeView.AppendColumn(amountColumn)
+										treeView.AppendColumn(descriptionColumn)
+										treeView.AppendColumn(tagsColumn)
+										treeView.ColumnsAutosize()
+
+										return true
+									}
+								}
+							}
+						}
 					}
 				}
 			}
@@ -387,12 +738,33 @@ func appendColumns(treeView *gtk.TreeView) bool {
 	return false
 }
 
-func createTextColumn(title string, idx int) *gtk.TreeViewColumn {
+// createDescriptionColumn creates editable column with timer description.
+// Edited text is saved to database immediately.
+func (d *Dialog) createDescriptionColumn() *gtk.TreeViewColumn {
 	if renderer, err := gtk.CellRendererTextNew(); tr.IsOK(err) {
-		if column, err := gtk.TreeViewColumnNewWithAttribute(title, renderer, "text", idx); tr.IsOK(err) {
+		if column, err := gtk.TreeViewColumnNewWithAttribute(descriptionColumnName, renderer, "text", descriptionColumnIdx); tr.IsOK(err) {
+			renderer.SetProperty("editable", true)
+			renderer.Connect("edited", d.descriptionEdited)
 			column.SetResizable(true)
+			column.SetExpand(true)
 			return column
 		}
 	}
 	return nil
 }
+
+func (d *Dialog) descriptionEdited(_ *gtk.CellRendererText, path, text string) {
+	if iter, err := d.listStore.GetIterFromString(path); tr.IsOK(err) {
+		if value, err := d.listStore.GetValue(iter, idColumnIdx); tr.IsOK(err) {
+			if id, err := value.GoValue(); tr.IsOK(err) {
+				if tm, err := d.store.TimerWithID(int64(id.(int))); tr.IsOK(err) {
+					text = strings.TrimSpace(text)
+					tm.SetDescription(text)
+					if err := d.store.SaveTimer(tm); tr.IsOK(err) {
+						d.listStore.SetValue(iter, descriptionColumnIdx, text)
+					}
+				}
+			}
+		}
+	}
+}
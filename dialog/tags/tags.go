@@ -0,0 +1,251 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tags
+
+import (
+	"strings"
+
+	"Timelancer/model/tag"
+	"Timelancer/shared/tr"
+	"Timelancer/storage"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	dialogTitle      = "tags"
+	newTagTooltip    = "name of a new tag"
+	newTagHolder     = "new tag"
+	addBtnText       = "add"
+	addBtnTooltip    = "add the tag to the list"
+	saveBtnText      = "save"
+	cancelBtnText    = "cancel"
+	saveBtnTooltip   = "save tags of the working time"
+	cancelBtnTooltip = "do nothing"
+	checksInRow      = 3
+)
+
+// Picker shows all tags as check buttons, a new tag can be added too.
+type Picker struct {
+	self     *gtk.Box
+	grid     *gtk.Grid
+	entry    *gtk.Entry
+	store    storage.TagRepository
+	checks   []*gtk.CheckButton
+	ids      []int64
+	selected map[int64]bool
+}
+
+// NewPicker creates the picker with selected tags checked.
+func NewPicker(store storage.TagRepository, selected []int64) *Picker {
+	if box, err := gtk.BoxNew(gtk.ORIENTATION_VERTICAL, 4); tr.IsOK(err) {
+		if grid, err := gtk.GridNew(); tr.IsOK(err) {
+			if newTagBox := createNewTagBox(); newTagBox != nil {
+				instance := &Picker{self: box, grid: grid, store: store, selected: make(map[int64]bool)}
+				for _, id := range selected {
+					instance.selected[id] = true
+				}
+
+				grid.SetRowSpacing(2)
+				grid.SetColumnSpacing(8)
+				box.PackStart(grid, true, true, 0)
+				box.PackStart(newTagBox.box, false, false, 0)
+
+				instance.entry = newTagBox.entry
+				newTagBox.addBtn.Connect("clicked", instance.addTag)
+				newTagBox.entry.Connect("activate", instance.addTag)
+
+				if data, err := store.Tags(); tr.IsOK(err) {
+					for _, t := range data {
+						instance.appendCheck(t)
+					}
+				}
+				return instance
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Picker) Widget() *gtk.Box {
+	return p.self
+}
+
+// SelectedIDs returns ids of checked tags.
+func (p *Picker) SelectedIDs() []int64 {
+	var data []int64
+	for i, check := range p.checks {
+		if check.GetActive() {
+			data = append(data, p.ids[i])
+		}
+	}
+	return data
+}
+
+// Dialog edits tags of one saved timer.
+type Dialog struct {
+	self    *gtk.Dialog
+	picker  *Picker
+	store   storage.TagRepository
+	timerID int64
+}
+
+func New(parent *gtk.Window, store storage.TagRepository, timerID int64) *Dialog {
+	if dialog, err := gtk.DialogNew(); tr.IsOK(err) {
+		dialog.SetTransientFor(parent)
+		dialog.SetBorderWidth(6)
+		dialog.SetTitle(dialogTitle)
+
+		instance := &Dialog{self: dialog, store: store, timerID: timerID}
+
+		if current, err := store.TimerTags(timerID); tr.IsOK(err) {
+			var selected []int64
+			for _, t := range current {
+				selected = append(selected, t.ID())
+			}
+			if contentArea, err := dialog.GetContentArea(); tr.IsOK(err) {
+				if buttonBox := instance.createButtons(); buttonBox != nil {
+					if separator, err := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL); tr.IsOK(err) {
+						if instance.picker = NewPicker(store, selected); instance.picker != nil {
+							contentArea.SetBorderWidth(4)
+							contentArea.SetSpacing(4)
+
+							contentArea.PackEnd(buttonBox, false, false, 0)
+							contentArea.PackEnd(separator, true, true, 1)
+							contentArea.PackEnd(instance.picker.Widget(), true, true, 0)
+							return instance
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) ShowAll() {
+	d.self.ShowAll()
+	d.self.SetResizable(false)
+}
+
+func (d *Dialog) Run() gtk.ResponseType {
+	return d.self.Run()
+}
+
+func (d *Dialog) Destroy() {
+	d.self.Destroy()
+}
+
+/********************************************************************
+*                                                                   *
+*                         P R I V A T E                             *
+*                                                                   *
+********************************************************************/
+
+type newTagBox struct {
+	box    *gtk.Box
+	entry  *gtk.Entry
+	addBtn *gtk.Button
+}
+
+func createNewTagBox() *newTagBox {
+	if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 2); tr.IsOK(err) {
+		if entry, err := gtk.EntryNew(); tr.IsOK(err) {
+			if addBtn, err := gtk.ButtonNewWithLabel(addBtnText); tr.IsOK(err) {
+				entry.SetPlaceholderText(newTagHolder)
+				entry.SetTooltipText(newTagTooltip)
+				addBtn.SetTooltipText(addBtnTooltip)
+
+				box.PackStart(entry, true, true, 0)
+				box.PackStart(addBtn, false, false, 0)
+				return &newTagBox{box: box, entry: entry, addBtn: addBtn}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Picker) appendCheck(t *tag.Tag) {
+	if check, err := gtk.CheckButtonNewWithLabel(t.Name()); tr.IsOK(err) {
+		n := len(p.checks)
+		check.SetActive(p.selected[t.ID()])
+		p.grid.Attach(check, n%checksInRow, n/checksInRow, 1, 1)
+		check.Show()
+
+		p.checks = append(p.checks, check)
+		p.ids = append(p.ids, t.ID())
+	}
+}
+
+// addTag saves a new tag and checks it.
+// If there is such tag already it is only checked.
+func (p *Picker) addTag() {
+	if text, err := p.entry.GetText(); tr.IsOK(err) {
+		if name := strings.TrimSpace(text); name != "" {
+			for _, check := range p.checks {
+				if label, err := check.GetLabel(); tr.IsOK(err) && strings.EqualFold(label, name) {
+					check.SetActive(true)
+					p.entry.SetText("")
+					return
+				}
+			}
+
+			t := tag.New(name)
+			if err := p.store.SaveTag(t); tr.IsOK(err) {
+				p.selected[t.ID()] = true
+				p.appendCheck(t)
+				p.entry.SetText("")
+			}
+		}
+	}
+}
+
+func (d *Dialog) createButtons() *gtk.Box {
+	if saveBtn, err := gtk.ButtonNewWithLabel(saveBtnText); tr.IsOK(err) {
+		if cancelBtn, err := gtk.ButtonNewWithLabel(cancelBtnText); tr.IsOK(err) {
+			if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); tr.IsOK(err) {
+				saveBtn.SetTooltipText(saveBtnTooltip)
+				cancelBtn.SetTooltipText(cancelBtnTooltip)
+
+				box.PackEnd(saveBtn, false, true, 2)
+				box.PackEnd(cancelBtn, false, true, 2)
+
+				saveBtn.Connect("clicked", func() {
+					if err := d.store.SetTimerTags(d.timerID, d.picker.SelectedIDs()); tr.IsOK(err) {
+						d.self.Response(gtk.RESPONSE_OK)
+					}
+				})
+				cancelBtn.Connect("clicked", func() {
+					d.self.Response(gtk.RESPONSE_CANCEL)
+				})
+				return box
+			}
+		}
+	}
+	return nil
+}
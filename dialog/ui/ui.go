@@ -0,0 +1,74 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ui
+
+import (
+	"Timelancer/shared/tr"
+
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// ShowError tells the user about the failure.
+func ShowError(parent gtk.IWindow, text string) {
+	showMessage(parent, gtk.MESSAGE_ERROR, "error", text)
+}
+
+// ShowInfo tells the user the text in the box with the title.
+func ShowInfo(parent gtk.IWindow, title, text string) {
+	showMessage(parent, gtk.MESSAGE_INFO, title, text)
+}
+
+// AskQuestion returns true if the user answers yes.
+func AskQuestion(parent gtk.IWindow, title, text string) bool {
+	if dialog := gtk.MessageDialogNew(parent, gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_YES_NO, title); dialog != nil {
+		defer dialog.Destroy()
+		dialog.FormatSecondaryText(text)
+		return dialog.Run() == gtk.RESPONSE_YES
+	}
+	return false
+}
+
+// TextColumn creates resizable column showing the text of the model column idx.
+func TextColumn(title string, idx int) *gtk.TreeViewColumn {
+	if renderer, err := gtk.CellRendererTextNew(); tr.IsOK(err) {
+		if column, err := gtk.TreeViewColumnNewWithAttribute(title, renderer, "text", idx); tr.IsOK(err) {
+			column.SetResizable(true)
+			return column
+		}
+	}
+	return nil
+}
+
+func showMessage(parent gtk.IWindow, messageType gtk.MessageType, title, text string) {
+	if dialog := gtk.MessageDialogNew(parent, gtk.DIALOG_MODAL, messageType, gtk.BUTTONS_CLOSE, title); dialog != nil {
+		defer dialog.Destroy()
+		dialog.FormatSecondaryText(text)
+		dialog.Run()
+	}
+}
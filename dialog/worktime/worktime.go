@@ -0,0 +1,238 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package worktime
+
+import (
+	"fmt"
+	"strings"
+
+	"Timelancer/dialog/tags"
+	"Timelancer/shared/tr"
+	"Timelancer/storage"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+const (
+	dialogTitle          = "working time"
+	descriptionLabelText = "what was done:"
+	suggestionsLabelText = "previously:"
+	tagsLabelText        = "tags:"
+	saveBtnText          = "save"
+	discardBtnText       = "don't save"
+	saveBtnTooltip       = "save the working time to database"
+	discardBtnTooltip    = "forget the working time"
+	suggestionsTooltip   = "descriptions used before for this company"
+	maxSuggestions       = 20
+	workedTimeFormat     = "<span font_desc='12' foreground='#BBBBBBBB'>you worked </span>" +
+		"<span font_desc='16' foreground='#CCC777'> %d</span><span font_desc='10' foreground='#BBBBBBBB'>h </span>" +
+		"<span font_desc='16' foreground='#CCC777'>%02d</span><span font_desc='10' foreground='#BBBBBBBB'>min </span>" +
+		"<span font_desc='12' foreground='#BBBBBBBB'>\nwould you like to save this information to database?</span>"
+)
+
+// Dialog asks if the working time should be saved and what was done.
+// Descriptions used before are suggested while typing.
+type Dialog struct {
+	self         *gtk.Dialog
+	buffer       *gtk.TextBuffer
+	suggestions  *gtk.ComboBoxText
+	tagPicker    *tags.Picker
+	descriptions []string
+	matching     []string
+	updating     bool
+}
+
+func New(parent *gtk.Window, hours, minutes uint, descriptions []string, tagStore storage.TagRepository) *Dialog {
+	if dialog, err := gtk.DialogNew(); tr.IsOK(err) {
+		dialog.SetTransientFor(parent)
+		dialog.SetBorderWidth(6)
+		dialog.SetTitle(dialogTitle)
+
+		instance := &Dialog{self: dialog, descriptions: descriptions}
+
+		if contentArea, err := dialog.GetContentArea(); tr.IsOK(err) {
+			if buttonBox := instance.createButtons(); buttonBox != nil {
+				if separator, err := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL); tr.IsOK(err) {
+					if grid := instance.createContent(hours, minutes, tagStore); grid != nil {
+						contentArea.PackEnd(buttonBox, false, false, 1)
+						contentArea.PackEnd(separator, true, false, 1)
+						contentArea.PackEnd(grid, true, true, 1)
+
+						instance.updateSuggestions()
+						return instance
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) ShowAll() {
+	d.self.ShowAll()
+	d.self.SetResizable(false)
+}
+
+// Run returns gtk.RESPONSE_YES if the time should be saved.
+func (d *Dialog) Run() gtk.ResponseType {
+	return d.self.Run()
+}
+
+func (d *Dialog) Destroy() {
+	d.self.Destroy()
+}
+
+func (d *Dialog) Description() string {
+	if text, err := d.buffer.GetText(d.buffer.GetStartIter(), d.buffer.GetEndIter(), false); tr.IsOK(err) {
+		return strings.TrimSpace(text)
+	}
+	return ""
+}
+
+// TagIDs returns ids of the checked tags.
+func (d *Dialog) TagIDs() []int64 {
+	return d.tagPicker.SelectedIDs()
+}
+
+// MatchingDescriptions returns descriptions starting with the text
+// (case insensitive), in the given order.
+func MatchingDescriptions(descriptions []string, text string) []string {
+	prefix := strings.ToLower(strings.TrimSpace(text))
+
+	var data []string
+	for _, description := range descriptions {
+		if strings.HasPrefix(strings.ToLower(description), prefix) {
+			data = append(data, description)
+			if len(data) == maxSuggestions {
+				break
+			}
+		}
+	}
+	return data
+}
+
+func (d *Dialog) createContent(hours, minutes uint, tagStore storage.TagRepository) *gtk.Grid {
+	if grid, err := gtk.GridNew(); tr.IsOK(err) {
+		if infoLabel, err := gtk.LabelNew(""); tr.IsOK(err) {
+			if descriptionLabel, err := gtk.LabelNew(descriptionLabelText); tr.IsOK(err) {
+				if suggestionsLabel, err := gtk.LabelNew(suggestionsLabelText); tr.IsOK(err) {
+					if textView, err := gtk.TextViewNew(); tr.IsOK(err) {
+						if buffer, err := textView.GetBuffer(); tr.IsOK(err) {
+							if suggestions, err := gtk.ComboBoxTextNew(); tr.IsOK(err) {
+								if tagsLabel, err := gtk.LabelNew(tagsLabelText); tr.IsOK(err) {
+									if tagPicker := tags.NewPicker(tagStore, nil); tagPicker != nil {
+										grid.SetBorderWidth(8)
+										grid.SetRowSpacing(8)
+										grid.SetColumnSpacing(8)
+
+										infoLabel.SetMarkup(fmt.Sprintf(workedTimeFormat, hours, minutes))
+										descriptionLabel.SetHAlign(gtk.ALIGN_END)
+										descriptionLabel.SetVAlign(gtk.ALIGN_START)
+										suggestionsLabel.SetHAlign(gtk.ALIGN_END)
+										tagsLabel.SetHAlign(gtk.ALIGN_END)
+										tagsLabel.SetVAlign(gtk.ALIGN_START)
+										textView.SetWrapMode(gtk.WRAP_WORD)
+										textView.SetAcceptsTab(false)
+										textView.SetSizeRequest(300, 80)
+										suggestions.SetTooltipText(suggestionsTooltip)
+
+										grid.Attach(infoLabel, 0, 0, 2, 1)
+										grid.Attach(descriptionLabel, 0, 1, 1, 1)
+										grid.Attach(textView, 1, 1, 1, 1)
+										grid.Attach(suggestionsLabel, 0, 2, 1, 1)
+										grid.Attach(suggestions, 1, 2, 1, 1)
+										grid.Attach(tagsLabel, 0, 3, 1, 1)
+										grid.Attach(tagPicker.Widget(), 1, 3, 1, 1)
+
+										d.buffer = buffer
+										d.suggestions = suggestions
+										d.tagPicker = tagPicker
+										buffer.Connect("changed", d.updateSuggestions)
+										suggestions.Connect("changed", d.suggestionSelected)
+										return grid
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Dialog) createButtons() *gtk.Box {
+	if saveBtn, err := gtk.ButtonNewWithLabel(saveBtnText); tr.IsOK(err) {
+		if discardBtn, err := gtk.ButtonNewWithLabel(discardBtnText); tr.IsOK(err) {
+			if box, err := gtk.BoxNew(gtk.ORIENTATION_HORIZONTAL, 1); tr.IsOK(err) {
+				saveBtn.SetTooltipText(saveBtnTooltip)
+				discardBtn.SetTooltipText(discardBtnTooltip)
+
+				box.PackEnd(saveBtn, false, false, 2)
+				box.PackEnd(discardBtn, false, false, 2)
+
+				saveBtn.Connect("clicked", func() {
+					d.self.Response(gtk.RESPONSE_YES)
+				})
+				discardBtn.Connect("clicked", func() {
+					d.self.Response(gtk.RESPONSE_NO)
+				})
+				return box
+			}
+		}
+	}
+	return nil
+}
+
+// updateSuggestions fills the combo with descriptions matching the typed text.
+func (d *Dialog) updateSuggestions() {
+	if d.updating {
+		return
+	}
+	d.updating = true
+	defer func() { d.updating = false }()
+
+	d.matching = MatchingDescriptions(d.descriptions, d.Description())
+	d.suggestions.RemoveAll()
+	for _, text := range d.matching {
+		d.suggestions.AppendText(text)
+	}
+	d.suggestions.SetSensitive(len(d.matching) > 0)
+}
+
+func (d *Dialog) suggestionSelected() {
+	if d.updating {
+		return
+	}
+	if row := d.suggestions.GetActive(); row > -1 && row < len(d.matching) {
+		d.updating = true
+		d.buffer.SetText(d.matching[row])
+		d.updating = false
+	}
+}
@@ -25,16 +25,22 @@
  * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
  * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
  */
-
 package main
 
 import (
+	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"Timelancer/dbf"
+	"Timelancer/dialog/health"
+	"Timelancer/settings"
 	"Timelancer/shared"
 	"Timelancer/shared/tr"
+	"Timelancer/sqlite"
+	"Timelancer/storage"
 	"Timelancer/window"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
@@ -44,33 +50,123 @@ const (
 	appID = "pl.beesoft.gtk3.Timelancer"
 )
 
+var checkDatabase = flag.Bool("check-database", false, "check health of the database, print the report and exit")
+
 func main() {
-	if openDatabase() {
-		if app, err := gtk.ApplicationNew(appID, glib.APPLICATION_FLAGS_NONE); tr.IsOK(err) {
-			app.Connect("activate", func() {
-				tr.Init()
-				if win := window.New(app); win != nil {
-					quitAction := glib.SimpleActionNew("quit", nil)
-					quitAction.Connect("activate", func() {
-						tr.Cancel()
-						app.Quit()
-					})
-					app.AddAction(quitAction)
-
-					win.ShowAll()
-				}
+	flag.Parse()
+	tr.Init()
+
+	filePath := databasePath()
+	if filePath == "" {
+		os.Exit(1)
+	}
+	if *checkDatabase {
+		report := dbf.CheckHealth(filePath)
+		fmt.Println(report)
+		if !report.Healthy() {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if app, err := gtk.ApplicationNew(appID, glib.APPLICATION_FLAGS_NONE); tr.IsOK(err) {
+		var db *sqlite.Database
+		stopBackups := func() {}
+
+		app.Connect("activate", func() {
+			if db != nil {
+				return
+			}
+			if !repairDatabaseIfNeeded(filePath) {
+				return
+			}
+			if db = openDatabase(filePath); db == nil {
+				return
+			}
+			// subscribers of database changes update widgets
+			db.SetDispatcher(func(f func()) {
+				glib.IdleAdd(f)
 			})
-			retv := app.Run(os.Args)
-			os.Exit(retv)
+			stopBackups = startBackups(db)
+
+			if win := window.New(app, storage.NewSQLite(db)); win != nil {
+				quitAction := glib.SimpleActionNew("quit", nil)
+				quitAction.Connect("activate", func() {
+					tr.Cancel()
+					app.Quit()
+				})
+				app.AddAction(quitAction)
+
+				win.ShowAll()
+			}
+		})
+		// GTK gets the arguments not parsed by flag
+		retv := app.Run(append([]string{os.Args[0]}, flag.Args()...))
+		stopBackups()
+		if db == nil {
+			os.Exit(1)
 		}
+		db.Close()
+		os.Exit(retv)
 	}
 	os.Exit(1)
 }
 
-func openDatabase() bool {
+func databasePath() string {
 	if dataDir := shared.AppDir(); dataDir != "" {
-		filePath := filepath.Join(dataDir, shared.AppName+".sqlite")
-		return dbf.OpenOrCreate(filePath)
+		return filepath.Join(dataDir, shared.AppName+".sqlite")
+	}
+	return ""
+}
+
+func openDatabase(filePath string) *sqlite.Database {
+	if s, err := settings.Load(settings.FilePath()); tr.IsOK(err) {
+		if db, err := dbf.OpenOrCreate(filePath, s.Database.Options()); tr.IsOK(err) {
+			if s.Database.TraceEnabled() {
+				tr.IsOK(dbf.StartTrace(db, s.Database.SlowQueryThreshold()))
+			}
+			return db
+		}
+	}
+	return nil
+}
+
+// repairDatabaseIfNeeded checks health of the database and if there are
+// problems lets the user choose what to do. Returns false if the
+// application should quit.
+func repairDatabaseIfNeeded(filePath string) bool {
+	report := dbf.CheckHealth(filePath)
+	if report.Healthy() {
+		return true
+	}
+	tr.Error("%s", report)
+
+	backupDir := dbf.BackupDir()
+	if dialog := health.New(nil, report, len(dbf.Backups(backupDir)) > 0); dialog != nil {
+		defer dialog.Destroy()
+
+		dialog.ShowAll()
+		switch dialog.Run() {
+		case health.RestoreResponse:
+			if backupPath, err := dbf.RestoreNewestBackup(filePath, backupDir, time.Now()); tr.IsOK(err) {
+				tr.Info("database restored from %s", backupPath)
+				return true
+			}
+		case health.QuarantineResponse:
+			if quarantinePath, err := dbf.Quarantine(filePath, time.Now()); tr.IsOK(err) {
+				tr.Info("damaged database moved to %s", quarantinePath)
+				return true
+			}
+		case health.IgnoreResponse:
+			return true
+		}
 	}
 	return false
 }
+
+func startBackups(db *sqlite.Database) func() {
+	if backupDir := dbf.BackupDir(); backupDir != "" {
+		return dbf.StartBackups(db, backupDir, dbf.BackupGenerations)
+	}
+	return func() {}
+}
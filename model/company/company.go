@@ -28,78 +28,37 @@
 
 package company
 
-import (
-	"fmt"
-
-	"Timelancer/shared/tr"
-	"Timelancer/sqlite"
-	"Timelancer/sqlite/field"
-	"Timelancer/sqlite/row"
-)
-
 /*
 CREATE TABLE company
 (
-id       INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-shortcut TEXT NOT NULL COLLATE NOCASE UNIQUE,
-name     TEXT NOT NULL COLLATE NOCASE,
-used     INTEGER NOT NULL CHECK(used==0 OR used==1) DEFAULT 1
+id          INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+shortcut    TEXT NOT NULL COLLATE NOCASE UNIQUE,
+name        TEXT NOT NULL COLLATE NOCASE UNIQUE,
+used        INTEGER NOT NULL CHECK(used==0 OR used==1) DEFAULT 1,
+currency    TEXT NOT NULL DEFAULT 'EUR',
+address     TEXT NOT NULL DEFAULT '',
+tax_id      TEXT NOT NULL DEFAULT '',
+vat_percent REAL NOT NULL DEFAULT 0
 );
 */
 
+// DefaultCurrency is the currency of new companies.
+const DefaultCurrency = "EUR"
+
 type Company struct {
-	id       int
-	shortcut string
-	name     string
-	used     bool
+	id       int    `db:"id,pk"`
+	shortcut string `db:"shortcut"`
+	name     string `db:"name"`
+	used     bool   `db:"used"`
+	currency string `db:"currency"`
+	// printed on invoices
+	address    string  `db:"address"`
+	taxID      string  `db:"tax_id"`
+	vatPercent float64 `db:"vat_percent"`
 }
 
 func New() *Company {
-	return &Company{used: true}
-}
-
-func NewWithRow(r row.Row) *Company {
-	c := &Company{}
-	ok := false
-
-	if value, exists := r["id"]; exists {
-		if id, err := value.Int64(); tr.IsOK(err) {
-			c.id = int(id)
-			ok = true
-		}
-	}
-	if ok {
-		ok = false
-		if value, exists := r["shortcut"]; exists {
-			if shortcut, err := value.Text(); tr.IsOK(err) {
-				c.shortcut = shortcut
-				ok = true
-			}
-		}
-	}
-	if ok {
-		ok = false
-		if value, exists := r["name"]; exists {
-			if name, err := value.Text(); tr.IsOK(err) {
-				c.name = name
-				ok = true
-			}
-		}
-	}
-	if ok {
-		ok = false
-		if value, exists := r["used"]; exists {
-			if used, err := value.Bool(); tr.IsOK(err) {
-				c.used = used
-				ok = true
-			}
-		}
-	}
-
-	if ok {
-		return c
-	}
-	return nil
+	return &Company{used: true, currency: DefaultCurrency}
 }
 
 func (c *Company) ID() int {
@@ -118,6 +77,29 @@ func (c *Company) Used() bool {
 	return c.used
 }
 
+// Currency is the ISO 4217 code (EUR, PLN, USD...) of rates of the company.
+func (c *Company) Currency() string {
+	return c.currency
+}
+
+func (c *Company) Address() string {
+	return c.address
+}
+
+func (c *Company) TaxID() string {
+	return c.taxID
+}
+
+// VATPercent is the VAT rate of invoices issued to the company.
+func (c *Company) VATPercent() float64 {
+	return c.vatPercent
+}
+
+// SetID is used by storage after the company was saved for the first time.
+func (c *Company) SetID(value int) {
+	c.id = value
+}
+
 func (c *Company) SetShortcut(value string) {
 	c.shortcut = value
 }
@@ -130,91 +112,22 @@ func (c *Company) SetUsed(value bool) {
 	c.used = value
 }
 
+func (c *Company) SetCurrency(value string) {
+	c.currency = value
+}
+
+func (c *Company) SetAddress(value string) {
+	c.address = value
+}
+
+func (c *Company) SetTaxID(value string) {
+	c.taxID = value
+}
+
+func (c *Company) SetVATPercent(value float64) {
+	c.vatPercent = value
+}
+
 func (c *Company) Valid() bool {
-	return c.name != "" && c.shortcut != ""
-}
-
-func (c *Company) Remove() bool {
-	query := fmt.Sprintf("DELETE FROM company WHERE id=%d", c.id)
-	return sqlite.SQLite().ExecQuery(query)
-}
-
-func (c *Company) Save() bool {
-	if c.id == 0 {
-		return c.insert()
-	}
-	return c.update()
-}
-
-func (c *Company) fields() []*field.Field {
-	var data []*field.Field
-
-	if c.id > 0 {
-		data = append(data, field.NewWithValue("id", int64(c.id)))
-	}
-	data = append(data, field.NewWithValue("shortcut", c.shortcut))
-	data = append(data, field.NewWithValue("name", c.name))
-	data = append(data, field.NewWithValue("used", c.used))
-
-	return data
-}
-
-func (c *Company) insert() bool {
-	fields := c.fields()
-	if id, ok := sqlite.SQLite().Insert("company", fields); ok {
-		c.id = int(id)
-		return true
-	}
-	return false
-}
-
-func (c *Company) update() bool {
-	fields := c.fields()
-	return sqlite.SQLite().Update("company", fields)
-}
-
-func CompaniesInUse() []*Company {
-	if n := sqlite.SQLite().CountWhereInt("company", "used", 1); n > 0 {
-		var data []*Company
-		query := "SELECT * FROM company WHERE used=1 ORDER BY shortcut ASC"
-		if result := sqlite.SQLite().Select(query); len(result) > 0 {
-			for _, r := range result {
-				if c := NewWithRow(r); c != nil {
-					data = append(data, c)
-				}
-			}
-			if len(data) > 0 {
-				return data
-			}
-		}
-	}
-	return nil
-}
-
-func Companies() []*Company {
-	if n := sqlite.SQLite().CountWhereInt("company", "used", 1); n > 0 {
-		var data []*Company
-		query := "SELECT * FROM company ORDER BY shortcut ASC"
-		if result := sqlite.SQLite().Select(query); len(result) > 0 {
-			for _, r := range result {
-				if c := NewWithRow(r); c != nil {
-					data = append(data, c)
-				}
-			}
-			if len(data) > 0 {
-				return data
-			}
-		}
-	}
-	return nil
-}
-
-func CompanyWithID(id int) *Company {
-	query := fmt.Sprintf("SELECT * FROM company WHERE id=%d", id)
-	if result := sqlite.SQLite().Select(query); len(result) == 1 {
-		if c := NewWithRow(result[0]); c != nil {
-			return c
-		}
-	}
-	return nil
+	return c.name != "" && c.shortcut != "" && c.currency != "" && c.vatPercent >= 0
 }
@@ -0,0 +1,297 @@
+package invoice
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"Timelancer/model/company"
+	"Timelancer/model/rate"
+)
+
+/*
+CREATE TABLE invoice
+(
+	id             INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	year           INTEGER NOT NULL,
+	sequence       INTEGER NOT NULL,
+	issued         INTEGER NOT NULL,
+	company_id     INTEGER NOT NULL,
+	seller_name    TEXT NOT NULL,
+	seller_address TEXT NOT NULL,
+	seller_tax_id  TEXT NOT NULL,
+	buyer_name     TEXT NOT NULL,
+	buyer_address  TEXT NOT NULL,
+	buyer_tax_id   TEXT NOT NULL,
+	currency       TEXT NOT NULL,
+	vat_percent    REAL NOT NULL CHECK(vat_percent>=0),
+	UNIQUE (year, sequence),
+	FOREIGN KEY (company_id) REFERENCES company(id)
+)
+CREATE TABLE invoice_line
+(
+	invoice_id     INTEGER NOT NULL,
+	position       INTEGER NOT NULL,
+	description    TEXT NOT NULL,
+	seconds        INTEGER NOT NULL,
+	cents_per_hour INTEGER NOT NULL,
+	net_cents      INTEGER NOT NULL,
+	PRIMARY KEY (invoice_id, position),
+	FOREIGN KEY (invoice_id) REFERENCES invoice(id) ON DELETE CASCADE
+)
+*/
+
+// Group tells how working times are put together into lines.
+type Group int
+
+const (
+	ByProject Group = iota
+	ByTag
+)
+
+// NoTagsName is the line of working times without tags (ByTag).
+const NoTagsName = "other work"
+
+// Party is the seller or the buyer as printed on the invoice.
+type Party struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	TaxID   string `json:"tax_id"`
+}
+
+func PartyOf(c *company.Company) Party {
+	return Party{Name: c.Name(), Address: c.Address(), TaxID: c.TaxID()}
+}
+
+// Item is a working time to be billed, Name is the name of its line.
+type Item struct {
+	TimerID      int64
+	Name         string
+	Seconds      int64
+	CentsPerHour int64
+}
+
+// Line is the position of the invoice: working times with the same name and rate.
+type Line struct {
+	Description  string `json:"description" db:"description"`
+	Seconds      int64  `json:"seconds" db:"seconds"`
+	CentsPerHour int64  `json:"cents_per_hour" db:"cents_per_hour"`
+	NetCents     int64  `json:"net_cents" db:"net_cents"`
+}
+
+func (l Line) Duration() time.Duration {
+	return time.Duration(l.Seconds) * time.Second
+}
+
+type Invoice struct {
+	id            int64   `db:"id,pk"`
+	year          int     `db:"year"`
+	sequence      int     `db:"sequence"`
+	issued        int64   `db:"issued"`
+	companyID     int     `db:"company_id"`
+	sellerName    string  `db:"seller_name"`
+	sellerAddress string  `db:"seller_address"`
+	sellerTaxID   string  `db:"seller_tax_id"`
+	buyerName     string  `db:"buyer_name"`
+	buyerAddress  string  `db:"buyer_address"`
+	buyerTaxID    string  `db:"buyer_tax_id"`
+	currency      string  `db:"currency"`
+	vatPercent    float64 `db:"vat_percent"`
+	lines         []Line
+	timerIDs      []int64
+}
+
+// New creates the invoice of the items for the buyer, in its currency and VAT rate.
+// The number is given when the invoice is saved.
+func New(seller, buyer *company.Company, issued time.Time, items []Item) *Invoice {
+	inv := &Invoice{
+		year:       issued.Year(),
+		issued:     issued.Unix(),
+		companyID:  buyer.ID(),
+		currency:   buyer.Currency(),
+		vatPercent: buyer.VATPercent(),
+		lines:      Lines(items),
+	}
+	inv.setSeller(PartyOf(seller))
+	inv.setBuyer(PartyOf(buyer))
+	for _, item := range items {
+		inv.timerIDs = append(inv.timerIDs, item.TimerID)
+	}
+	return inv
+}
+
+// Lines puts together items with the same name and rate, ordered by name and rate.
+// The amount of the line is the sum of amounts of its items.
+func Lines(items []Item) []Line {
+	type key struct {
+		name         string
+		centsPerHour int64
+	}
+	lines := make(map[key]*Line)
+	for _, item := range items {
+		k := key{item.Name, item.CentsPerHour}
+		line, ok := lines[k]
+		if !ok {
+			line = &Line{Description: item.Name, CentsPerHour: item.CentsPerHour}
+			lines[k] = line
+		}
+		line.Seconds += item.Seconds
+		line.NetCents += rate.Amount(item.CentsPerHour, time.Duration(item.Seconds)*time.Second)
+	}
+
+	data := make([]Line, 0, len(lines))
+	for _, line := range lines {
+		data = append(data, *line)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].Description != data[j].Description {
+			return data[i].Description < data[j].Description
+		}
+		return data[i].CentsPerHour < data[j].CentsPerHour
+	})
+	return data
+}
+
+func (inv *Invoice) ID() int64 {
+	return inv.id
+}
+
+func (inv *Invoice) Year() int {
+	return inv.year
+}
+
+// Sequence is the number of the invoice in its year, 0 if not saved yet.
+func (inv *Invoice) Sequence() int {
+	return inv.sequence
+}
+
+// Number returns e.g. "2024/0007", empty text if the invoice was not saved yet.
+func (inv *Invoice) Number() string {
+	if inv.sequence == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%04d", inv.year, inv.sequence)
+}
+
+func (inv *Invoice) Issued() time.Time {
+	return time.Unix(inv.issued, 0)
+}
+
+// CompanyID is the id of the buyer.
+func (inv *Invoice) CompanyID() int {
+	return inv.companyID
+}
+
+func (inv *Invoice) Seller() Party {
+	return Party{Name: inv.sellerName, Address: inv.sellerAddress, TaxID: inv.sellerTaxID}
+}
+
+func (inv *Invoice) Buyer() Party {
+	return Party{Name: inv.buyerName, Address: inv.buyerAddress, TaxID: inv.buyerTaxID}
+}
+
+func (inv *Invoice) Currency() string {
+	return inv.currency
+}
+
+func (inv *Invoice) VATPercent() float64 {
+	return inv.vatPercent
+}
+
+func (inv *Invoice) Lines() []Line {
+	return inv.lines
+}
+
+// TimerIDs are ids of the invoiced timers.
+func (inv *Invoice) TimerIDs() []int64 {
+	return inv.timerIDs
+}
+
+// Net returns the amount without VAT (in cents).
+func (inv *Invoice) Net() int64 {
+	var net int64
+	for _, line := range inv.lines {
+		net += line.NetCents
+	}
+	return net
+}
+
+// VAT returns the tax (in cents) of the net amount, rounded.
+func (inv *Invoice) VAT() int64 {
+	return int64(math.Round(float64(inv.Net()) * inv.vatPercent / 100))
+}
+
+func (inv *Invoice) Gross() int64 {
+	return inv.Net() + inv.VAT()
+}
+
+// SetID is used by storage after the invoice was saved.
+func (inv *Invoice) SetID(value int64) {
+	inv.id = value
+}
+
+// SetSequence is used by storage, sequences are given in order in every year.
+func (inv *Invoice) SetSequence(value int) {
+	inv.sequence = value
+}
+
+// SetLines is used by storage when the invoice is read.
+func (inv *Invoice) SetLines(value []Line) {
+	inv.lines = value
+}
+
+// SetTimerIDs is used by storage when the invoice is read.
+func (inv *Invoice) SetTimerIDs(value []int64) {
+	inv.timerIDs = value
+}
+
+func (inv *Invoice) Valid() bool {
+	return inv.companyID != 0 && inv.currency != "" && inv.vatPercent >= 0 && len(inv.lines) > 0
+}
+
+// MarshalJSON gives the machine-readable form of the invoice (amounts are in cents).
+func (inv *Invoice) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Number     string  `json:"number"`
+		Issued     string  `json:"issued"`
+		Seller     Party   `json:"seller"`
+		Buyer      Party   `json:"buyer"`
+		Currency   string  `json:"currency"`
+		VATPercent float64 `json:"vat_percent"`
+		Lines      []Line  `json:"lines"`
+		NetCents   int64   `json:"net_cents"`
+		VATCents   int64   `json:"vat_cents"`
+		GrossCents int64   `json:"gross_cents"`
+		TimerIDs   []int64 `json:"timer_ids"`
+	}{
+		Number:     inv.Number(),
+		Issued:     inv.Issued().Format(dateFormat),
+		Seller:     inv.Seller(),
+		Buyer:      inv.Buyer(),
+		Currency:   inv.currency,
+		VATPercent: inv.vatPercent,
+		Lines:      inv.lines,
+		NetCents:   inv.Net(),
+		VATCents:   inv.VAT(),
+		GrossCents: inv.Gross(),
+		TimerIDs:   inv.timerIDs,
+	})
+}
+
+/********************************************************************
+*                                                                   *
+*                         P R I V A T E                             *
+*                                                                   *
+********************************************************************/
+
+const dateFormat = "2006-01-02"
+
+func (inv *Invoice) setSeller(p Party) {
+	inv.sellerName, inv.sellerAddress, inv.sellerTaxID = p.Name, p.Address, p.TaxID
+}
+
+func (inv *Invoice) setBuyer(p Party) {
+	inv.buyerName, inv.buyerAddress, inv.buyerTaxID = p.Name, p.Address, p.TaxID
+}
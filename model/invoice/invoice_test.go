@@ -0,0 +1,107 @@
+package invoice
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"Timelancer/model/company"
+)
+
+func Test_Lines(t *testing.T) {
+	lines := Lines([]Item{
+		{TimerID: 1, Name: "web shop", Seconds: 3600, CentsPerHour: 6000},
+		{TimerID: 2, Name: "app", Seconds: 1800, CentsPerHour: 6000},
+		{TimerID: 3, Name: "web shop", Seconds: 1000, CentsPerHour: 6000},
+		{TimerID: 4, Name: "web shop", Seconds: 900, CentsPerHour: 9000},
+	})
+	assert.Equal(t, []Line{
+		{Description: "app", Seconds: 1800, CentsPerHour: 6000, NetCents: 3000},
+		{Description: "web shop", Seconds: 4600, CentsPerHour: 6000, NetCents: 7667},
+		{Description: "web shop", Seconds: 900, CentsPerHour: 9000, NetCents: 2250},
+	}, lines)
+	assert.Empty(t, Lines(nil))
+}
+
+func newInvoice() *Invoice {
+	seller := company.New()
+	seller.SetName("Freelancer")
+	seller.SetAddress("Main Street 1\n00-001 Warsaw")
+	seller.SetTaxID("PL1234567890")
+	buyer := company.New()
+	buyer.SetID(7)
+	buyer.SetName("ACME (Europe)")
+	buyer.SetCurrency("PLN")
+	buyer.SetVATPercent(23)
+
+	issued := time.Date(2024, time.March, 31, 18, 0, 0, 0, time.Local)
+	return New(seller, buyer, issued, []Item{
+		{TimerID: 3, Name: "web shop", Seconds: 3600, CentsPerHour: 10050},
+		{TimerID: 5, Name: "web shop", Seconds: 1800, CentsPerHour: 10050},
+	})
+}
+
+func Test_NewInvoice(t *testing.T) {
+	inv := newInvoice()
+	assert.True(t, inv.Valid())
+	assert.Equal(t, 2024, inv.Year())
+	assert.Equal(t, 7, inv.CompanyID())
+	assert.Equal(t, "PLN", inv.Currency())
+	assert.Equal(t, Party{Name: "ACME (Europe)"}, inv.Buyer())
+	assert.Equal(t, "PL1234567890", inv.Seller().TaxID)
+	assert.Equal(t, []int64{3, 5}, inv.TimerIDs())
+
+	assert.Equal(t, int64(15075), inv.Net())
+	assert.Equal(t, int64(3467), inv.VAT()) // 3467.25
+	assert.Equal(t, int64(18542), inv.Gross())
+
+	assert.Equal(t, "", inv.Number())
+	inv.SetSequence(7)
+	assert.Equal(t, "2024/0007", inv.Number())
+
+	assert.False(t, New(company.New(), company.New(), time.Now(), nil).Valid())
+}
+
+func Test_MarshalJSON(t *testing.T) {
+	inv := newInvoice()
+	inv.SetSequence(1)
+
+	data, err := json.Marshal(inv)
+	if assert.Nil(t, err) {
+		var document map[string]interface{}
+		assert.Nil(t, json.Unmarshal(data, &document))
+		assert.Equal(t, "2024/0001", document["number"])
+		assert.Equal(t, "2024-03-31", document["issued"])
+		assert.Equal(t, 18542.0, document["gross_cents"])
+		assert.Equal(t, "Freelancer", document["seller"].(map[string]interface{})["name"])
+		assert.Len(t, document["lines"], 1)
+	}
+}
+
+func Test_WritePDF(t *testing.T) {
+	inv := newInvoice()
+	inv.SetSequence(1)
+	// enough lines for the second page
+	for i := 0; i < 50; i++ {
+		inv.lines = append(inv.lines, inv.lines[0])
+	}
+
+	var buffer bytes.Buffer
+	assert.Nil(t, inv.WritePDF(&buffer))
+	data := buffer.String()
+	assert.Contains(t, data, "(Invoice 2024/0001) Tj")
+	assert.Contains(t, data, `(ACME \(Europe\)) Tj`)
+	assert.Contains(t, data, "(00-001 Warsaw) Tj")
+	assert.Contains(t, data, "/Count 2")
+}
+
+func Test_AlteredInPDF(t *testing.T) {
+	inv := newInvoice()
+	assert.Empty(t, inv.AlteredInPDF())
+
+	inv.buyerAddress = "ul. Łąkowa 5\n90-001 Łódź"
+	assert.Equal(t, []string{"ul. Łąkowa 5", "90-001 Łódź"}, inv.AlteredInPDF())
+}
@@ -0,0 +1,113 @@
+package invoice
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"Timelancer/model/rate"
+	"Timelancer/shared/pdf"
+)
+
+const (
+	left       = 50
+	right      = pdf.PageWidth - 50
+	middle     = pdf.PageWidth / 2
+	bottom     = 120
+	lineHeight = 15
+	textSize   = 10
+)
+
+// columns of the table, amounts are aligned to the right
+const (
+	positionX    = left
+	descriptionX = left + 25
+	hoursX       = right - 170
+	rateX        = right - 90
+	netX         = right
+)
+
+// WritePDF writes the printable form of the invoice.
+func (inv *Invoice) WritePDF(w io.Writer) error {
+	_, err := inv.document().WriteTo(w)
+	return err
+}
+
+// AlteredInPDF returns texts of the invoice which can't be printed exactly
+// in its PDF (letters out of WinAnsi are replaced).
+func (inv *Invoice) AlteredInPDF() []string {
+	return inv.document().Altered()
+}
+
+func (inv *Invoice) document() *pdf.Document {
+	doc := pdf.New()
+	page := doc.AddPage()
+
+	page.Text(left, 780, pdf.Bold, 18, "Invoice "+inv.Number())
+	page.Text(left, 760, pdf.Regular, textSize, "Issue date: "+inv.Issued().Format(dateFormat))
+	sellerY := writeParty(page, left, 720, "Seller", inv.Seller())
+	buyerY := writeParty(page, middle, 720, "Buyer", inv.Buyer())
+
+	y := math.Min(sellerY, buyerY) - 2*lineHeight
+	y = writeTableHeader(page, y)
+	for i, line := range inv.lines {
+		if y < bottom {
+			page = doc.AddPage()
+			y = writeTableHeader(page, pdf.PageHeight-60)
+		}
+		page.Text(positionX, y, pdf.Regular, textSize, strconv.Itoa(i+1))
+		page.Text(descriptionX, y, pdf.Regular, textSize, line.Description)
+		page.TextRight(hoursX, y, pdf.Regular, textSize, fmt.Sprintf("%.2f", line.Duration().Hours()))
+		page.TextRight(rateX, y, pdf.Regular, textSize, rate.FormatAmount(line.CentsPerHour))
+		page.TextRight(netX, y, pdf.Regular, textSize, rate.FormatAmount(line.NetCents))
+		y -= lineHeight
+	}
+	page.Line(left, y+lineHeight-4, right, y+lineHeight-4)
+
+	y -= lineHeight
+	vat := "VAT " + strconv.FormatFloat(inv.vatPercent, 'f', -1, 64) + "%:"
+	for _, total := range []struct {
+		name  string
+		cents int64
+		font  pdf.Font
+	}{
+		{"Net:", inv.Net(), pdf.Regular},
+		{vat, inv.VAT(), pdf.Regular},
+		{"Total:", inv.Gross(), pdf.Bold},
+	} {
+		page.TextRight(rateX, y, total.font, textSize, total.name)
+		page.TextRight(netX, y, total.font, textSize, rate.FormatAmount(total.cents)+" "+inv.currency)
+		y -= lineHeight
+	}
+	return doc
+}
+
+// writeParty returns the position below the written data.
+func writeParty(page *pdf.Page, x, y float64, title string, p Party) float64 {
+	page.Text(x, y, pdf.Bold, textSize+1, title)
+	y -= lineHeight
+	lines := append([]string{p.Name}, strings.Split(p.Address, "\n")...)
+	if p.TaxID != "" {
+		lines = append(lines, "Tax ID: "+p.TaxID)
+	}
+	for _, text := range lines {
+		if text = strings.TrimSpace(text); text != "" {
+			page.Text(x, y, pdf.Regular, textSize, text)
+			y -= lineHeight
+		}
+	}
+	return y
+}
+
+// writeTableHeader returns the position of the first row.
+func writeTableHeader(page *pdf.Page, y float64) float64 {
+	page.Text(positionX, y, pdf.Bold, textSize, "No.")
+	page.Text(descriptionX, y, pdf.Bold, textSize, "Description")
+	page.TextRight(hoursX, y, pdf.Bold, textSize, "Hours")
+	page.TextRight(rateX, y, pdf.Bold, textSize, "Rate")
+	page.TextRight(netX, y, pdf.Bold, textSize, "Net amount")
+	page.Line(left, y-4, right, y-4)
+	return y - lineHeight - 2
+}
@@ -0,0 +1,95 @@
+package project
+
+/*
+CREATE TABLE project
+(
+	id           INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	company_id   INTEGER NOT NULL,
+	name         TEXT NOT NULL COLLATE NOCASE,
+	code         TEXT NOT NULL COLLATE NOCASE,
+	active       INTEGER NOT NULL CHECK(active==0 OR active==1) DEFAULT 1,
+	budget_hours REAL NOT NULL CHECK(budget_hours>=0) DEFAULT 0,
+	UNIQUE (company_id, name),
+	UNIQUE (company_id, code),
+	FOREIGN KEY (company_id) REFERENCES company(id)
+)
+*/
+
+// Every company has the default project, timers saved without
+// a project belong to it.
+const (
+	DefaultName = "default"
+	DefaultCode = "DEFAULT"
+)
+
+// Project is an engagement for a company.
+type Project struct {
+	id          int     `db:"id,pk"`
+	companyID   int     `db:"company_id"`
+	name        string  `db:"name"`
+	code        string  `db:"code"`
+	active      bool    `db:"active"`
+	budgetHours float64 `db:"budget_hours"`
+}
+
+func New(companyID int) *Project {
+	return &Project{companyID: companyID, active: true}
+}
+
+func NewDefault(companyID int) *Project {
+	return &Project{companyID: companyID, name: DefaultName, code: DefaultCode, active: true}
+}
+
+func (p *Project) ID() int {
+	return p.id
+}
+
+func (p *Project) CompanyID() int {
+	return p.companyID
+}
+
+func (p *Project) Name() string {
+	return p.name
+}
+
+func (p *Project) Code() string {
+	return p.code
+}
+
+func (p *Project) Active() bool {
+	return p.active
+}
+
+// BudgetHours returns 0 if the project has no budget.
+func (p *Project) BudgetHours() float64 {
+	return p.budgetHours
+}
+
+func (p *Project) HasBudget() bool {
+	return p.budgetHours > 0
+}
+
+// SetID is used by storage after the project was saved for the first time.
+func (p *Project) SetID(value int) {
+	p.id = value
+}
+
+func (p *Project) SetName(value string) {
+	p.name = value
+}
+
+func (p *Project) SetCode(value string) {
+	p.code = value
+}
+
+func (p *Project) SetActive(value bool) {
+	p.active = value
+}
+
+func (p *Project) SetBudgetHours(value float64) {
+	p.budgetHours = value
+}
+
+func (p *Project) Valid() bool {
+	return p.companyID != 0 && p.name != "" && p.code != "" && p.budgetHours >= 0
+}
@@ -0,0 +1,158 @@
+package rate
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+CREATE TABLE rate
+(
+	id             INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	company_id     INTEGER NOT NULL,
+	project_id     INTEGER NOT NULL DEFAULT 0,
+	cents_per_hour INTEGER NOT NULL CHECK(cents_per_hour>=0),
+	valid_from     INTEGER NOT NULL DEFAULT 0,
+	valid_until    INTEGER NOT NULL DEFAULT 0,
+	FOREIGN KEY (company_id) REFERENCES company(id)
+)
+*/
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// AllProjects is the project of a rate of the whole company.
+const AllProjects = 0
+
+// Rate is the price of an hour of work for a company (or its project)
+// in the currency of the company. It may be effective in a range of time
+// only, so a raise doesn't change amounts of older timers.
+// The rate of a project wins over the rate of the company, the latest
+// started rate wins if more of them are effective.
+type Rate struct {
+	id           int64 `db:"id,pk"`
+	companyID    int   `db:"company_id"`
+	projectID    int   `db:"project_id"`
+	centsPerHour int64 `db:"cents_per_hour"`
+	validFrom    int64 `db:"valid_from"`
+	validUntil   int64 `db:"valid_until"`
+}
+
+func New(companyID int) *Rate {
+	return &Rate{companyID: companyID}
+}
+
+func (r *Rate) ID() int64 {
+	return r.id
+}
+
+func (r *Rate) CompanyID() int {
+	return r.companyID
+}
+
+// ProjectID returns AllProjects for a rate of the whole company.
+func (r *Rate) ProjectID() int {
+	return r.projectID
+}
+
+func (r *Rate) CentsPerHour() int64 {
+	return r.centsPerHour
+}
+
+// ValidFrom returns zero time if the rate has no start.
+func (r *Rate) ValidFrom() time.Time {
+	return unixOrZero(r.validFrom)
+}
+
+// ValidUntil returns zero time if the rate has no end.
+// The rate is not effective at this moment any more.
+func (r *Rate) ValidUntil() time.Time {
+	return unixOrZero(r.validUntil)
+}
+
+// SetID is used by storage after the rate was saved for the first time.
+func (r *Rate) SetID(value int64) {
+	r.id = value
+}
+
+func (r *Rate) SetProjectID(value int) {
+	r.projectID = value
+}
+
+func (r *Rate) SetCentsPerHour(value int64) {
+	r.centsPerHour = value
+}
+
+// SetValidFrom sets the start, zero time means no start.
+func (r *Rate) SetValidFrom(t time.Time) {
+	r.validFrom = zeroOrUnix(t)
+}
+
+// SetValidUntil sets the end, zero time means no end.
+func (r *Rate) SetValidUntil(t time.Time) {
+	r.validUntil = zeroOrUnix(t)
+}
+
+// EffectiveAt checks if the rate is effective at the time.
+func (r *Rate) EffectiveAt(t time.Time) bool {
+	return r.validFrom <= t.Unix() && (r.validUntil == 0 || t.Unix() < r.validUntil)
+}
+
+func (r *Rate) Valid() bool {
+	return r.companyID != 0 && r.centsPerHour >= 0 && (r.validUntil == 0 || r.validFrom < r.validUntil)
+}
+
+// Amount returns the price (in cents, rounded) of the work.
+func Amount(centsPerHour int64, d time.Duration) int64 {
+	seconds := int64(d / time.Second)
+	return (seconds*centsPerHour + 1800) / 3600
+}
+
+// FormatAmount returns cents as text with two decimal places, e.g. "1234.50".
+func FormatAmount(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// ParseAmount reads amounts like "120", "120.5" or "120,50" (as cents).
+func ParseAmount(text string) (int64, error) {
+	text = strings.ReplaceAll(strings.TrimSpace(text), ",", ".")
+	whole, fraction, hasFraction := strings.Cut(text, ".")
+	if whole == "" || (hasFraction && (fraction == "" || len(fraction) > 2)) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, text)
+	}
+	for len(fraction) < 2 {
+		fraction += "0"
+	}
+	value, err := strconv.ParseUint(whole+fraction, 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, text)
+	}
+	return int64(value), nil
+}
+
+/********************************************************************
+*                                                                   *
+*                         P R I V A T E                             *
+*                                                                   *
+********************************************************************/
+
+func unixOrZero(value int64) time.Time {
+	if value == 0 {
+		return time.Time{}
+	}
+	return time.Unix(value, 0)
+}
+
+func zeroOrUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,53 @@
+package rate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseAmount(t *testing.T) {
+	for text, expected := range map[string]int64{
+		"120":     12000,
+		"120.5":   12050,
+		" 99,99 ": 9999,
+		"0.01":    1,
+	} {
+		value, err := ParseAmount(text)
+		assert.Nil(t, err, text)
+		assert.Equal(t, expected, value, text)
+	}
+	for _, text := range []string{"", "abc", "1.234", "12.", ".5", "-3", "1e3"} {
+		_, err := ParseAmount(text)
+		assert.ErrorIs(t, err, ErrInvalidAmount, text)
+	}
+}
+
+func Test_FormatAmount(t *testing.T) {
+	assert.Equal(t, "1234.50", FormatAmount(123450))
+	assert.Equal(t, "0.07", FormatAmount(7))
+	assert.Equal(t, "-1.00", FormatAmount(-100))
+}
+
+func Test_Amount(t *testing.T) {
+	assert.Equal(t, int64(15000), Amount(10000, 90*time.Minute))
+	// 10 seconds of 100.00/h are 0.2777... rounded to 0.28
+	assert.Equal(t, int64(28), Amount(10000, 10*time.Second))
+	assert.Equal(t, int64(0), Amount(0, time.Hour))
+}
+
+func Test_EffectiveAt(t *testing.T) {
+	r := New(1)
+	assert.True(t, r.EffectiveAt(time.Unix(100, 0)))
+
+	r.SetValidFrom(time.Unix(1000, 0))
+	r.SetValidUntil(time.Unix(2000, 0))
+	assert.False(t, r.EffectiveAt(time.Unix(999, 0)))
+	assert.True(t, r.EffectiveAt(time.Unix(1000, 0)))
+	assert.False(t, r.EffectiveAt(time.Unix(2000, 0)))
+	assert.True(t, r.Valid())
+
+	r.SetValidUntil(time.Unix(1000, 0))
+	assert.False(t, r.Valid())
+}
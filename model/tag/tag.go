@@ -0,0 +1,41 @@
+package tag
+
+/*
+CREATE TABLE tag
+(
+	id   INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL COLLATE NOCASE UNIQUE
+)
+*/
+
+// Tag is a label (meeting, bugfix, travel...) which may be given
+// to any timer, independent of its company.
+type Tag struct {
+	id   int64  `db:"id,pk"`
+	name string `db:"name"`
+}
+
+func New(name string) *Tag {
+	return &Tag{name: name}
+}
+
+func (t *Tag) ID() int64 {
+	return t.id
+}
+
+func (t *Tag) Name() string {
+	return t.name
+}
+
+// SetID is used by storage after the tag was saved for the first time.
+func (t *Tag) SetID(value int64) {
+	t.id = value
+}
+
+func (t *Tag) SetName(value string) {
+	t.name = value
+}
+
+func (t *Tag) Valid() bool {
+	return t.name != ""
+}
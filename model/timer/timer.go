@@ -1,81 +1,38 @@
 package timer
 
 import (
-	"fmt"
 	"time"
 
 	"Timelancer/shared"
-	"Timelancer/shared/tr"
-	"Timelancer/sqlite"
-	"Timelancer/sqlite/field"
-	"Timelancer/sqlite/row"
 )
 
 /*
 CREATE TABLE timer
 (
-	company_id INTEGER NOT NULL,
-	start      INTEGER NOT NULL,
-	finish     INTEGER NOT NULL,
+	id          INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	company_id  INTEGER NOT NULL,
+	start       INTEGER NOT NULL,
+	finish      INTEGER NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	project_id  INTEGER REFERENCES project(id),
+	invoice_id  INTEGER NOT NULL DEFAULT 0,
 	FOREIGN KEY (company_id) REFERENCES company(id)
 )
 */
 
 type Timer struct {
-	id        int64
-	companyID int64
-	start     int64
-	finish    int64
+	id          int64  `db:"id,pk"`
+	companyID   int64  `db:"company_id"`
+	start       int64  `db:"start"`
+	finish      int64  `db:"finish"`
+	description string `db:"description"`
+	projectID   int64  `db:"project_id"`
 }
 
 func NewWithData(companyID, start, finish int64) *Timer {
 	return &Timer{companyID: companyID, start: start, finish: finish}
 }
 
-func NewWithRow(r row.Row) *Timer {
-	tm := &Timer{}
-	ok := false
-
-	if value, exists := r["id"]; exists {
-		if value, err := value.Int64(); tr.IsOK(err) {
-			tm.id = int64(value)
-			ok = true
-		}
-	}
-	if ok {
-		ok = false
-		if value, exists := r["company_id"]; exists {
-			if value, err := value.Int64(); tr.IsOK(err) {
-				tm.companyID = value
-				ok = true
-			}
-		}
-	}
-	if ok {
-		ok = false
-		if value, exists := r["start"]; exists {
-			if value, err := value.Int64(); tr.IsOK(err) {
-				tm.start = value
-				ok = true
-			}
-		}
-	}
-	if ok {
-		ok = false
-		if value, exists := r["finish"]; exists {
-			if value, err := value.Int64(); tr.IsOK(err) {
-				tm.finish = value
-				ok = true
-			}
-		}
-	}
-
-	if ok {
-		return tm
-	}
-	return nil
-}
-
 func (tm *Timer) ID() int64 {
 	return tm.id
 }
@@ -84,6 +41,37 @@ func (tm *Timer) CompanyID() int64 {
 	return tm.companyID
 }
 
+// ProjectID returns 0 if the project wasn't given
+// (the timer is saved in the default project of the company).
+func (tm *Timer) ProjectID() int64 {
+	return tm.projectID
+}
+
+func (tm *Timer) SetProjectID(value int64) {
+	tm.projectID = value
+}
+
+// SetID is used by storage after the timer was saved for the first time.
+func (tm *Timer) SetID(value int64) {
+	tm.id = value
+}
+
+func (tm *Timer) Description() string {
+	return tm.description
+}
+
+func (tm *Timer) SetDescription(value string) {
+	tm.description = value
+}
+
+func (tm *Timer) StartTime() time.Time {
+	return time.Unix(tm.start, 0)
+}
+
+func (tm *Timer) FinishTime() time.Time {
+	return time.Unix(tm.finish, 0)
+}
+
 func (tm *Timer) Start() string {
 	if t := time.Unix(tm.start, 0); !t.IsZero() {
 		return shared.TimeAsString(t)
@@ -109,42 +97,3 @@ func (tm *Timer) FinishAsString() string {
 func (tm *Timer) Valid() bool {
 	return tm.id != 0 && tm.companyID != 0 && tm.start != 0 && tm.finish != 0
 }
-
-func (tm *Timer) Remove() bool {
-	query := fmt.Sprintf("DELETE FROM timer WHERE id=%d", tm.id)
-	return sqlite.SQLite().ExecQuery(query)
-}
-
-func (tm *Timer) Save() bool {
-	if tm.id == 0 {
-		return tm.insert()
-	}
-	return tm.update()
-}
-
-func (tm *Timer) fields() []*field.Field {
-	var data []*field.Field
-
-	if tm.id > 0 {
-		data = append(data, field.NewWithValue("id", int64(tm.id)))
-	}
-	data = append(data, field.NewWithValue("company_id", int64(tm.companyID)))
-	data = append(data, field.NewWithValue("start", int64(tm.start)))
-	data = append(data, field.NewWithValue("finish", int64(tm.finish)))
-
-	return data
-}
-
-func (tm *Timer) insert() bool {
-	fields := tm.fields()
-	if id, ok := sqlite.SQLite().Insert("timer", fields); ok {
-		tm.id = id
-		return true
-	}
-	return false
-}
-
-func (tm *Timer) update() bool {
-	fields := tm.fields()
-	return sqlite.SQLite().Update("timer", fields)
-}
@@ -0,0 +1,150 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"Timelancer/shared"
+	"Timelancer/sqlite"
+)
+
+const fileName = "settings.json"
+
+// TraceEnvVar turns SQL tracing on when set to a non-empty value,
+// whatever the settings say.
+const TraceEnvVar = "TIMELANCER_SQL_TRACE"
+
+// Database is the configuration of the database connection.
+// Foreign keys are always enforced (storage relies on them).
+type Database struct {
+	JournalMode   string `json:"journal_mode"`
+	Synchronous   string `json:"synchronous"`
+	CacheSize     int    `json:"cache_size"`
+	BusyTimeoutMs int    `json:"busy_timeout_ms"`
+	SQLTrace      bool   `json:"sql_trace"`     // log every statement
+	SlowQueryMs   int    `json:"slow_query_ms"` // traced statements running longer are warnings
+}
+
+// Settings of the application, kept as JSON in the application directory.
+type Settings struct {
+	Database Database `json:"database"`
+}
+
+func Default() *Settings {
+	p := sqlite.DefaultPragmas()
+	return &Settings{
+		Database: Database{
+			JournalMode:   p.JournalMode,
+			Synchronous:   p.Synchronous,
+			CacheSize:     p.CacheSize,
+			BusyTimeoutMs: int(sqlite.DefaultBusyTimeout.Milliseconds()),
+			SlowQueryMs:   200,
+		},
+	}
+}
+
+// FilePath returns path of the settings file ("" if there is no
+// application directory).
+func FilePath() string {
+	if dir := shared.AppDir(); dir != "" {
+		return filepath.Join(dir, fileName)
+	}
+	return ""
+}
+
+// Load reads settings from the file. Missing file gives the defaults,
+// missing values keep their defaults.
+func Load(filePath string) (*Settings, error) {
+	s := Default()
+
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("invalid settings file %s: %w", filePath, err)
+	}
+	if err := s.Database.Pragmas().Validate(); err != nil {
+		return nil, fmt.Errorf("invalid settings file %s: %w", filePath, err)
+	}
+	return s, nil
+}
+
+// Save writes settings to the file (replaced at once, never half written).
+func (s *Settings) Save(filePath string) error {
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+func (d Database) Pragmas() *sqlite.Pragmas {
+	return &sqlite.Pragmas{
+		ForeignKeys: true,
+		JournalMode: d.JournalMode,
+		Synchronous: d.Synchronous,
+		CacheSize:   d.CacheSize,
+	}
+}
+
+// TraceEnabled tells if statements should be traced (the setting
+// or TraceEnvVar).
+func (d Database) TraceEnabled() bool {
+	return d.SQLTrace || os.Getenv(TraceEnvVar) != ""
+}
+
+func (d Database) SlowQueryThreshold() time.Duration {
+	return time.Duration(d.SlowQueryMs) * time.Millisecond
+}
+
+// Options returns options of the database connection.
+func (d Database) Options() *sqlite.Options {
+	return &sqlite.Options{
+		BusyTimeout: time.Duration(d.BusyTimeoutMs) * time.Millisecond,
+		Pragmas:     d.Pragmas(),
+	}
+}
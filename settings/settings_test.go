@@ -0,0 +1,97 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"Timelancer/sqlite"
+)
+
+func Test_LoadMissingFile(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), fileName))
+	assert.Nil(t, err)
+	assert.Equal(t, Default(), s)
+
+	opts := s.Database.Options()
+	assert.Equal(t, sqlite.DefaultBusyTimeout, opts.BusyTimeout)
+	assert.Equal(t, sqlite.DefaultPragmas(), opts.Pragmas)
+}
+
+func Test_SaveAndLoad(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), fileName)
+
+	s := Default()
+	s.Database.JournalMode = "DELETE"
+	s.Database.BusyTimeoutMs = 1500
+	assert.Nil(t, s.Save(filePath))
+
+	loaded, err := Load(filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, s, loaded)
+	assert.Equal(t, 1500*time.Millisecond, loaded.Database.Options().BusyTimeout)
+}
+
+func Test_LoadPartialAndInvalidFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), fileName)
+
+	assert.Nil(t, os.WriteFile(filePath, []byte(`{"database": {"synchronous": "FULL"}}`), 0600))
+	s, err := Load(filePath)
+	if assert.Nil(t, err) {
+		expected := Default()
+		expected.Database.Synchronous = "FULL"
+		assert.Equal(t, expected, s)
+	}
+
+	assert.Nil(t, os.WriteFile(filePath, []byte(`{"database": {"journal_mode": "FAST"}}`), 0600))
+	_, err = Load(filePath)
+	assert.ErrorIs(t, err, sqlite.ErrInvalidPragma)
+
+	assert.Nil(t, os.WriteFile(filePath, []byte(`{"database":`), 0600))
+	_, err = Load(filePath)
+	assert.NotNil(t, err)
+}
+
+func Test_TraceEnabled(t *testing.T) {
+	t.Setenv(TraceEnvVar, "")
+	s := Default()
+	assert.False(t, s.Database.TraceEnabled())
+	assert.Equal(t, 200*time.Millisecond, s.Database.SlowQueryThreshold())
+
+	s.Database.SQLTrace = true
+	assert.True(t, s.Database.TraceEnabled())
+
+	t.Setenv(TraceEnvVar, "1")
+	assert.True(t, Default().Database.TraceEnabled())
+}
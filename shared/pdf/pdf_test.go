@@ -0,0 +1,97 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WriteTo(t *testing.T) {
+	d := New()
+	first := d.AddPage()
+	first.Text(50, 800, Bold, 16, "Invoice (1)")
+	first.Line(50, 790, 545, 790)
+	d.AddPage().TextRight(545, 800, Regular, 10, "1234.50 EUR")
+
+	var buffer bytes.Buffer
+	n, err := d.WriteTo(&buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(buffer.Len()), n)
+
+	data := buffer.String()
+	assert.Regexp(t, `^%PDF-1\.4\n`, data)
+	assert.Regexp(t, `%%EOF\n$`, data)
+	assert.Contains(t, data, "/Count 2")
+	assert.Contains(t, data, `(Invoice \(1\)) Tj`)
+
+	// every entry of xref points to its object
+	match := regexp.MustCompile(`xref\n0 (\d+)\n`).FindStringSubmatchIndex(data)
+	if assert.NotNil(t, match) {
+		count, _ := strconv.Atoi(data[match[2]:match[3]])
+		assert.Equal(t, 9, count)
+		entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(data[match[1]:], -1)
+		if assert.Len(t, entries, count-1) {
+			for i, entry := range entries {
+				offset, _ := strconv.Atoi(entry[1])
+				assert.True(t, bytes.HasPrefix(buffer.Bytes()[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))))
+			}
+		}
+	}
+}
+
+func Test_Encode(t *testing.T) {
+	data, exact := encode("Żółć Gaß 5€ ☺")
+	assert.Equal(t, []byte("Z\xf3lc Ga\xdf 5\x80 ?"), data)
+	assert.False(t, exact)
+	data, exact = encode("Gaß 5€")
+	assert.Equal(t, []byte("Ga\xdf 5\x80"), data)
+	assert.True(t, exact)
+	assert.Equal(t, `a\(b\) \\ \363`, escape([]byte("a(b) \\ \xf3")))
+}
+
+func Test_Altered(t *testing.T) {
+	d := New()
+	p := d.AddPage()
+	p.Text(50, 800, Regular, 10, "Müller GmbH")
+	p.Text(50, 785, Regular, 10, "Łódź")
+	d.AddPage().TextRight(545, 800, Regular, 10, "Brno, Česko")
+	assert.Equal(t, []string{"Łódź", "Brno, Česko"}, d.Altered())
+}
+
+func Test_TextWidth(t *testing.T) {
+	assert.InDelta(t, 5.56, TextWidth("1", 10), 1e-9)
+	assert.InDelta(t, 36.14, TextWidth("1234.50", 10), 1e-9)
+	assert.InDelta(t, 36.14, TextWidth("1234,50", 10), 1e-9)
+}
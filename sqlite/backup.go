@@ -0,0 +1,120 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+/*
+#include <stdlib.h>
+#include <sqlite3.h>
+
+#cgo LDFLAGS: -lsqlite3
+*/
+import "C"
+import (
+	"time"
+	"unsafe"
+)
+
+const (
+	backupPagesPerStep = 64
+	backupBusyDelay    = 50 * time.Millisecond
+	backupBusyRetries  = 100
+)
+
+// Backup writes a consistent copy of the database to destPath
+// using the online backup API. The connection is locked for the whole
+// copy, other calls (and transactions) wait until it's written.
+// Existing content of destPath is replaced.
+func (db *Database) Backup(destPath string) error {
+	db.lock()
+	defer db.unlock()
+
+	if db.ptr == nil {
+		return ErrNotOpened
+	}
+
+	dest, err := openConnection(destPath, C.SQLITE_OPEN_READWRITE|C.SQLITE_OPEN_CREATE, db.busyTimeout)
+	if err != nil {
+		return err
+	}
+	defer C.sqlite3_close(dest)
+
+	return copyDatabase(dest, db.ptr)
+}
+
+// Restore replaces content of the database with the content of
+// the database from srcPath (e.g. a file written by Backup).
+func (db *Database) Restore(srcPath string) error {
+	if !databaseExists(srcPath) {
+		return ErrNotExists
+	}
+
+	db.lock()
+	defer db.unlock()
+
+	if db.ptr == nil {
+		return ErrNotOpened
+	}
+
+	src, err := openConnection(srcPath, C.SQLITE_OPEN_READONLY, db.busyTimeout)
+	if err != nil {
+		return err
+	}
+	defer C.sqlite3_close(src)
+
+	return copyDatabase(db.ptr, src)
+}
+
+func copyDatabase(dest, src *C.sqlite3) error {
+	name := C.CString("main")
+	defer C.free(unsafe.Pointer(name))
+
+	backup := C.sqlite3_backup_init(dest, name, src, name)
+	if backup == nil {
+		return connectionError(dest, C.sqlite3_errcode(dest))
+	}
+
+	for retries := 0; ; {
+		retv := C.sqlite3_backup_step(backup, backupPagesPerStep)
+		if retv == C.SQLITE_OK {
+			continue
+		}
+		if (retv == C.SQLITE_BUSY || retv == C.SQLITE_LOCKED) && retries < backupBusyRetries {
+			retries++
+			time.Sleep(backupBusyDelay)
+			continue
+		}
+		break
+	}
+
+	// finish returns the error (if any) of the last step
+	if retv := C.sqlite3_backup_finish(backup); retv != C.SQLITE_OK {
+		return connectionError(dest, retv)
+	}
+	return nil
+}
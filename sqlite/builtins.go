@@ -0,0 +1,114 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"Timelancer/sqlite/field"
+	"Timelancer/sqlite/vtc"
+)
+
+// Functions available in every opened database. Timestamps are Unix
+// seconds (like timer.start/finish), the local ones use the time zone
+// of the application, so they are not deterministic.
+var builtins = []struct {
+	name          string
+	nArgs         int
+	deterministic bool
+	fn            Func
+}{
+	{"tl_local_day", 1, false, localTimeFunc("tl_local_day", "2006-01-02")},
+	{"tl_local_month", 1, false, localTimeFunc("tl_local_month", "2006-01")},
+	{"tl_iso_week", 1, false, isoWeek},
+	{"tl_round_minutes", 2, true, roundMinutes},
+}
+
+func (db *Database) registerBuiltins() error {
+	for _, b := range builtins {
+		if err := db.registerFunc(b.name, b.nArgs, b.deterministic, b.fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localTimeFunc returns function formatting timestamp in the local zone
+// (e.g. tl_local_day(start) -> '2019-10-28').
+func localTimeFunc(name, layout string) Func {
+	return func(args []*field.Field) (interface{}, error) {
+		t, ok, err := timestampArg(name, args[0])
+		if !ok {
+			return nil, err
+		}
+		return t.Format(layout), nil
+	}
+}
+
+// isoWeek returns ISO 8601 week of the timestamp, e.g. tl_iso_week(start) -> '2020-W01'.
+func isoWeek(args []*field.Field) (interface{}, error) {
+	t, ok, err := timestampArg("tl_iso_week", args[0])
+	if !ok {
+		return nil, err
+	}
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week), nil
+}
+
+// roundMinutes returns duration in minutes rounded to the nearest
+// multiple of step minutes, e.g. tl_round_minutes(finish-start, 15).
+func roundMinutes(args []*field.Field) (interface{}, error) {
+	if args[0].ValueType == vtc.Null {
+		return nil, nil
+	}
+	seconds, err := args[0].Int64()
+	if err != nil {
+		return nil, fmt.Errorf("tl_round_minutes: seconds must be an integer")
+	}
+	step, err := args[1].Int64()
+	if err != nil || step < 1 {
+		return nil, fmt.Errorf("tl_round_minutes: step must be a positive integer")
+	}
+	steps := math.Round(float64(seconds) / float64(step*60))
+	return int64(steps) * step, nil
+}
+
+// timestampArg returns false (without error) for NULL.
+func timestampArg(name string, f *field.Field) (time.Time, bool, error) {
+	if f.ValueType == vtc.Null {
+		return time.Time{}, false, nil
+	}
+	seconds, err := f.Int64()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s: timestamp must be an integer", name)
+	}
+	return time.Unix(seconds, 0).Local(), true, nil
+}
@@ -0,0 +1,105 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+import (
+	"runtime/cgo"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// Functions called by SQLite (C code can't call Go closures directly).
+// This file may contain C declarations only, helpers are in function.go.
+
+//export goFuncCallback
+func goFuncCallback(ctx *C.sqlite3_context, argc C.int, argv **C.sqlite3_value) {
+	handle := cgo.Handle(uintptr(C.sqlite3_user_data(ctx)))
+	fn := handle.Value().(Func)
+	args := unsafe.Slice(argv, int(argc))
+	callFunc(ctx, fn, args)
+}
+
+//export goFuncDestroy
+func goFuncDestroy(ptr unsafe.Pointer) {
+	cgo.Handle(uintptr(ptr)).Delete()
+}
+
+//export goUpdateHook
+func goUpdateHook(ptr unsafe.Pointer, op C.int, dbName, table *C.char, rowID C.sqlite3_int64) {
+	var operation Operation
+	switch op {
+	case C.SQLITE_INSERT:
+		operation = OpInsert
+	case C.SQLITE_UPDATE:
+		operation = OpUpdate
+	case C.SQLITE_DELETE:
+		operation = OpDelete
+	}
+	notifierFromHandle(ptr).changed(C.GoString(table), operation, int64(rowID))
+}
+
+//export goCommitHook
+func goCommitHook(ptr unsafe.Pointer) C.int {
+	notifierFromHandle(ptr).committed()
+	// zero lets the commit go on
+	return 0
+}
+
+//export goRollbackHook
+func goRollbackHook(ptr unsafe.Pointer) {
+	notifierFromHandle(ptr).rolledBack()
+}
+
+func notifierFromHandle(ptr unsafe.Pointer) *notifier {
+	return cgo.Handle(uintptr(ptr)).Value().(*notifier)
+}
+
+//export goTraceCallback
+func goTraceCallback(event C.uint, ptr, p, x unsafe.Pointer) C.int {
+	t := cgo.Handle(uintptr(ptr)).Value().(*tracer)
+	switch event {
+	case C.SQLITE_TRACE_STMT:
+		// statements of triggers are reported as comments, they are
+		// a part of the statement anyway
+		if sql := C.GoString((*C.char)(x)); !strings.HasPrefix(sql, "--") {
+			t.started((*C.sqlite3_stmt)(p))
+		}
+	case C.SQLITE_TRACE_ROW:
+		t.row((*C.sqlite3_stmt)(p))
+	case C.SQLITE_TRACE_PROFILE:
+		t.finished((*C.sqlite3_stmt)(p), time.Duration(*(*C.sqlite3_int64)(x)))
+	}
+	// return value is ignored by SQLite
+	return 0
+}
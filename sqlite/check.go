@@ -0,0 +1,123 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"Timelancer/sqlite/vtc"
+)
+
+// ForeignKeyViolation is a row pointing to a missing row of the parent table.
+type ForeignKeyViolation struct {
+	Table  string
+	RowID  int64
+	Parent string
+}
+
+func (v ForeignKeyViolation) String() string {
+	return fmt.Sprintf("%s %d: missing row in %s", v.Table, v.RowID, v.Parent)
+}
+
+// CheckHeader checks if the file starts with the SQLite header. It returns
+// ErrNotExists for a missing file and ErrInvalidHeader for a truncated
+// header or other one. An empty file is valid (SQLite writes nothing
+// until the first table is created).
+func CheckHeader(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotExists
+		}
+		return err
+	}
+	defer f.Close()
+
+	data := make([]byte, len(vtc.DatabaseHeader))
+	if _, err := io.ReadFull(f, data); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return ErrInvalidHeader
+		}
+		return err
+	}
+	if !bytes.Equal(vtc.DatabaseHeader, data) {
+		return ErrInvalidHeader
+	}
+	return nil
+}
+
+// IntegrityCheck runs 'PRAGMA integrity_check' and returns found
+// problems (none for a healthy database).
+func (db *Database) IntegrityCheck() ([]string, error) {
+	result, err := db.Select("PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	for _, r := range result {
+		if f := r.At(0); f != nil {
+			if text, err := f.Text(); err == nil && text != "ok" {
+				problems = append(problems, text)
+			}
+		}
+	}
+	return problems, nil
+}
+
+// ForeignKeyCheck runs 'PRAGMA foreign_key_check' (it works also when
+// foreign keys are not enforced).
+func (db *Database) ForeignKeyCheck() ([]ForeignKeyViolation, error) {
+	result, err := db.Select("PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []ForeignKeyViolation
+	for _, r := range result {
+		var v ForeignKeyViolation
+		if f := r.Field("table"); f != nil {
+			v.Table, _ = f.Text()
+		}
+		if f := r.Field("rowid"); f != nil {
+			v.RowID, _ = f.Int64()
+		}
+		if f := r.Field("parent"); f != nil {
+			v.Parent, _ = f.Text()
+		}
+		violations = append(violations, v)
+	}
+	return violations, nil
+}
@@ -0,0 +1,77 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CheckHeader(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		filePath := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(filePath, []byte(content), 0600))
+		return filePath
+	}
+
+	assert.ErrorIs(t, CheckHeader(filepath.Join(dir, "none.sqlite")), ErrNotExists)
+	assert.Nil(t, CheckHeader(write("empty.sqlite", "")))
+	assert.ErrorIs(t, CheckHeader(write("short.sqlite", "SQLite")), ErrInvalidHeader)
+	assert.ErrorIs(t, CheckHeader(write("text.sqlite", "this is a plain text file, not a database")), ErrInvalidHeader)
+
+	// a truncated file is not opened as a database
+	_, err := Open(filepath.Join(dir, "short.sqlite"), nil)
+	assert.ErrorIs(t, err, ErrNotExists)
+
+	db := newTestDatabase(t)
+	assert.Nil(t, CheckHeader(db.fpath))
+}
+
+func Test_IntegrityAndForeignKeyCheck(t *testing.T) {
+	db, err := Open(Memory, &Options{Pragmas: &Pragmas{}})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer db.Close()
+	assert.Nil(t, db.ExecQuery(testScheme))
+
+	problems, err := db.IntegrityCheck()
+	assert.Nil(t, err)
+	assert.Empty(t, problems)
+
+	assert.Nil(t, db.Exec("INSERT INTO timer (company_id, start, finish) VALUES (?, ?, ?)", 5, 1, 2))
+	violations, err := db.ForeignKeyCheck()
+	assert.Nil(t, err)
+	assert.Equal(t, []ForeignKeyViolation{{Table: "timer", RowID: 1, Parent: "company"}}, violations)
+	assert.Equal(t, "timer 1: missing row in company", violations[0].String())
+}
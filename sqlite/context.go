@@ -0,0 +1,176 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+/*
+#include <stdlib.h>
+#include <sqlite3.h>
+
+#cgo LDFLAGS: -lsqlite3
+
+static int progress(void *flag) {
+	return __atomic_load_n((int *)flag, __ATOMIC_SEQ_CST);
+}
+
+static void set_progress_handler(sqlite3 *db, int *flag) {
+	if (flag) {
+		sqlite3_progress_handler(db, 1000, progress, flag);
+	} else {
+		sqlite3_progress_handler(db, 0, NULL, NULL);
+	}
+}
+
+static void set_flag(int *flag) {
+	__atomic_store_n(flag, 1, __ATOMIC_SEQ_CST);
+}
+*/
+import "C"
+import (
+	"context"
+	"unsafe"
+
+	"Timelancer/sqlite/row"
+	"Timelancer/sqlite/vtc"
+)
+
+// SelectAndHandleContext works like SelectAndHandle, but stops the query
+// (also in the middle of a long step) when ctx is cancelled or its deadline
+// passes. The returned error is then reported by IsInterrupt.
+func (db *Database) SelectAndHandleContext(ctx context.Context, query string, handler func(row.Row), args ...interface{}) error {
+	if ctx.Err() != nil {
+		return interruptError(ctx)
+	}
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	stop := stmt.interruptOn(ctx)
+	defer stop()
+
+	if err := stmt.BindArgs(args...); err != nil {
+		return err
+	}
+	for {
+		if ctx.Err() != nil {
+			return interruptError(ctx)
+		}
+		ok, err := stmt.Step()
+		if err != nil {
+			if IsInterrupt(err) {
+				return interruptError(ctx)
+			}
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		handler(stmt.Row())
+	}
+}
+
+func (db *Database) SelectContext(ctx context.Context, query string, args ...interface{}) (row.Result, error) {
+	var result row.Result
+
+	err := db.SelectAndHandleContext(ctx, query, func(r row.Row) {
+		result = append(result, r)
+	}, args...)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// interruptOn makes steps of the statement fail with SQLITE_INTERRUPT
+// after ctx is done. Returned function releases the watcher.
+func (s *Statement) interruptOn(ctx context.Context) func() {
+	flag, stop := watchContext(ctx)
+	s.interrupt = flag
+	return func() {
+		s.interrupt = nil
+		stop()
+	}
+}
+
+// execScriptContext works like execScript, but stops the script
+// when ctx is cancelled or its deadline passes.
+func (db *Database) execScriptContext(ctx context.Context, query string) (int64, error) {
+	if ctx.Err() != nil {
+		return 0, interruptError(ctx)
+	}
+	var flag *C.int
+	if ctx.Done() != nil {
+		var stop func()
+		flag, stop = watchContext(ctx)
+		defer stop()
+	}
+
+	changes, err := db.execScript(query, flag)
+	if err != nil && IsInterrupt(err) && ctx.Err() != nil {
+		return 0, interruptError(ctx)
+	}
+	return changes, err
+}
+
+// watchContext returns the flag for the progress handler, it is set
+// after ctx is done. Returned function releases the watcher and the flag.
+func watchContext(ctx context.Context) (*C.int, func()) {
+	flag := (*C.int)(C.calloc(1, C.sizeof_int))
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-ctx.Done():
+			C.set_flag(flag)
+		case <-done:
+		}
+	}()
+
+	return flag, func() {
+		close(done)
+		<-finished
+		C.free(unsafe.Pointer(flag))
+	}
+}
+
+func setProgressHandler(ptr *C.sqlite3, flag *C.int) {
+	C.set_progress_handler(ptr, flag)
+}
+
+func interruptError(ctx context.Context) error {
+	message := "interrupted"
+	if err := ctx.Err(); err != nil {
+		message = "interrupted: " + err.Error()
+	}
+	return &Error{Code: vtc.Interrupt, ExtendedCode: vtc.Interrupt, Message: message}
+}
@@ -0,0 +1,77 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"Timelancer/sqlite/row"
+)
+
+// heavyQuery runs for many seconds before it returns the only row.
+const heavyQuery = `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i+1 FROM n WHERE i < 1000000000)
+SELECT count(*) AS count FROM n`
+
+func Test_SelectContextDeadline(t *testing.T) {
+	db := newTestDatabase(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := db.SelectContext(ctx, heavyQuery)
+	assert.True(t, IsInterrupt(err))
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// connection works normally after interruption
+	result, err := db.SelectContext(context.Background(), "SELECT * FROM company WHERE shortcut=?", "BEE")
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+}
+
+func Test_SelectAndHandleContextCancel(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	n := 0
+	err := db.SelectAndHandleContext(ctx, "SELECT * FROM timer", func(row.Row) {
+		n++
+		cancel()
+	})
+	assert.True(t, IsInterrupt(err))
+	assert.Equal(t, 1, n)
+
+	// already cancelled context doesn't run the query at all
+	_, err = db.SelectContext(ctx, "SELECT * FROM no_such_table")
+	assert.True(t, IsInterrupt(err))
+}
@@ -0,0 +1,305 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"Timelancer/sqlite/field"
+	"Timelancer/sqlite/vtc"
+)
+
+// DriverName is the name of the database/sql driver, e.g.
+//
+//	sql.Open(sqlite.DriverName, "/path/timelancer.sqlite?mode=ro")
+//
+// Supported parameters: mode (ro, rw - default, rwc) and busy_timeout
+// (milliseconds). Times are bound as Unix seconds.
+const DriverName = "timelancer-sqlite"
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+type Driver struct{}
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	filePath, opts, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	db, err := Open(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &driverConn{db: db}, nil
+}
+
+/********************************************************************
+*                                                                   *
+*                         P R I V A T E                             *
+*                                                                   *
+********************************************************************/
+
+func parseDSN(dsn string) (string, *Options, error) {
+	opts := &Options{}
+	filePath, query, found := strings.Cut(dsn, "?")
+	if !found {
+		return filePath, opts, nil
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", nil, err
+	}
+	switch mode := values.Get("mode"); mode {
+	case "", "rw":
+	case "ro":
+		opts.ReadOnly = true
+	case "rwc":
+		opts.Create = true
+	default:
+		return "", nil, errors.New("unknown mode " + mode)
+	}
+	if value := values.Get("busy_timeout"); value != "" {
+		ms, err := strconv.Atoi(value)
+		if err != nil {
+			return "", nil, err
+		}
+		opts.BusyTimeout = time.Duration(ms) * time.Millisecond
+	}
+	return filePath, opts, nil
+}
+
+type driverConn struct {
+	db *Database
+}
+
+func (c *driverConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *driverConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &driverStmt{stmt: stmt}, nil
+}
+
+// ExecContext runs queries without arguments directly, so scripts
+// with many statements work (prepared statement runs only the first one).
+// Like statements, the script is stopped when ctx is done.
+func (c *driverConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	changes, err := c.db.execScriptContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(changes), nil
+}
+
+func (c *driverConn) Close() error {
+	return c.db.Close()
+}
+
+func (c *driverConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *driverConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) && opts.Isolation != driver.IsolationLevel(sql.LevelSerializable) {
+		return nil, errors.New("unsupported isolation level")
+	}
+	begin := "BEGIN IMMEDIATE TRANSACTION"
+	if opts.ReadOnly {
+		begin = "BEGIN TRANSACTION"
+	}
+	if err := c.db.ExecQuery(begin); err != nil {
+		return nil, err
+	}
+	return &driverTx{db: c.db}, nil
+}
+
+type driverTx struct {
+	db *Database
+}
+
+func (tx *driverTx) Commit() error {
+	return tx.db.CommitTransaction()
+}
+
+func (tx *driverTx) Rollback() error {
+	return tx.db.RollbackTransaction()
+}
+
+type driverStmt struct {
+	stmt *Statement
+}
+
+func (s *driverStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *driverStmt) NumInput() int {
+	return s.stmt.ParameterCount()
+}
+
+func (s *driverStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *driverStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := s.bind(args); err != nil {
+		return nil, err
+	}
+	if ctx.Done() != nil {
+		stop := s.stmt.interruptOn(ctx)
+		defer stop()
+	}
+
+	rowID, changes, err := s.stmt.execResult()
+	if err != nil {
+		return nil, err
+	}
+	return &driverResult{rowID: rowID, changes: changes}, nil
+}
+
+func (s *driverStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *driverStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := s.bind(args); err != nil {
+		return nil, err
+	}
+	rows := &driverRows{stmt: s.stmt, stop: func() {}}
+	if ctx.Done() != nil {
+		rows.stop = s.stmt.interruptOn(ctx)
+	}
+	return rows, nil
+}
+
+// bind replaces arguments of the previous execution with args.
+func (s *driverStmt) bind(args []driver.NamedValue) error {
+	if err := s.stmt.Reset(); err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		value := arg.Value
+		if t, ok := value.(time.Time); ok {
+			value = t.Unix()
+		}
+		if arg.Name != "" {
+			// BindArgs binds fields by name
+			value = field.NewWithValue(arg.Name, value)
+		}
+		values[i] = value
+	}
+	return s.stmt.BindArgs(values...)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	data := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		data[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return data
+}
+
+type driverResult struct {
+	rowID   int64
+	changes int64
+}
+
+func (r *driverResult) LastInsertId() (int64, error) {
+	return r.rowID, nil
+}
+
+func (r *driverResult) RowsAffected() (int64, error) {
+	return r.changes, nil
+}
+
+type driverRows struct {
+	stmt *Statement
+	stop func()
+}
+
+func (r *driverRows) Columns() []string {
+	names := make([]string, r.stmt.ColumnCount())
+	for i := range names {
+		names[i] = r.stmt.ColumnName(i)
+	}
+	return names
+}
+
+// Close leaves the statement ready for the next execution.
+func (r *driverRows) Close() error {
+	r.stop()
+	r.stop = func() {}
+	return r.stmt.Reset()
+}
+
+func (r *driverRows) Next(dest []driver.Value) error {
+	ok, err := r.stmt.Step()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return io.EOF
+	}
+
+	for i := range dest {
+		switch r.stmt.ColumnType(i) {
+		case vtc.Int:
+			dest[i] = r.stmt.Int(i)
+		case vtc.Float:
+			dest[i] = r.stmt.Float(i)
+		case vtc.Text:
+			dest[i] = r.stmt.Text(i)
+		case vtc.Blob:
+			dest[i] = r.stmt.Blob(i)
+		default:
+			dest[i] = nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Driver(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "driver.sqlite")
+	bad, err := sql.Open(DriverName, filePath+"?mode=xx")
+	assert.Nil(t, err)
+	// connection (and DSN) is checked on the first use
+	assert.NotNil(t, bad.Ping())
+	bad.Close()
+
+	db, err := sql.Open(DriverName, filePath+"?mode=rwc&busy_timeout=1000")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(testScheme)
+	assert.Nil(t, err)
+
+	result, err := db.Exec("INSERT INTO company (shortcut, name) VALUES (?, ?)", "ACME", "Acme")
+	assert.Nil(t, err)
+	id, _ := result.LastInsertId()
+	assert.Equal(t, int64(1), id)
+
+	start := time.Unix(1570000000, 0)
+	stmt, err := db.Prepare("INSERT INTO timer (company_id, start, finish) VALUES (:company, :start, :start + 60)")
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := stmt.Exec(sql.Named("company", id), sql.Named("start", start.Add(time.Duration(i)*time.Hour)))
+		assert.Nil(t, err)
+	}
+	stmt.Close()
+
+	result, err = db.Exec("UPDATE timer SET finish=finish+1 WHERE start>?", start)
+	assert.Nil(t, err)
+	n, _ := result.RowsAffected()
+	assert.Equal(t, int64(2), n)
+
+	var (
+		shortcut string
+		count    int64
+		total    float64
+		missing  sql.NullString
+	)
+	err = db.QueryRow(`SELECT company.shortcut, COUNT(*), SUM(finish-start)/60.0, NULL
+		FROM timer, company WHERE timer.company_id=company.id GROUP BY company.id`).Scan(&shortcut, &count, &total, &missing)
+	assert.Nil(t, err)
+	assert.Equal(t, "ACME", shortcut)
+	assert.Equal(t, int64(3), count)
+	assert.InDelta(t, 3.0+2.0/60, total, 0.0001)
+	assert.False(t, missing.Valid)
+
+	// rolled back transaction
+	tx, err := db.Begin()
+	assert.Nil(t, err)
+	_, err = tx.Exec("DELETE FROM timer")
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Rollback())
+
+	rows, err := db.Query("SELECT id FROM timer ORDER BY id")
+	assert.Nil(t, err)
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		assert.Nil(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = db.QueryRowContext(ctx, heavyQuery).Scan(&count)
+	assert.NotNil(t, err)
+
+	// queries without arguments are stopped too
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = db.ExecContext(ctx, heavyQuery)
+	assert.True(t, IsInterrupt(err))
+	_, err = db.ExecContext(ctx, "DELETE FROM timer")
+	assert.NotNil(t, err)
+	assert.Nil(t, db.QueryRow("SELECT count(*) FROM timer").Scan(&count))
+	assert.Equal(t, int64(3), count)
+}
+
+func Test_DriverReadOnly(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "driver.sqlite")
+	db, err := sql.Open(DriverName, filePath+"?mode=ro")
+	assert.Nil(t, err)
+	defer db.Close()
+	assert.ErrorIs(t, db.Ping(), ErrNotExists)
+
+	create, err := Open(filePath, &Options{Create: true})
+	assert.Nil(t, err)
+	assert.Nil(t, create.ExecQuery(testScheme))
+	create.Close()
+
+	_, err = db.Exec("INSERT INTO company (shortcut, name) VALUES (?, ?)", "ACME", "Acme")
+	assert.True(t, IsReadOnly(err))
+}
@@ -0,0 +1,109 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+
+	"Timelancer/sqlite/vtc"
+)
+
+var (
+	ErrOpened           = errors.New("database is already opened")
+	ErrNotOpened        = errors.New("database is not opened")
+	ErrExists           = errors.New("database already exists")
+	ErrNotExists        = errors.New("database doesn't exist or is not a SQLite database")
+	ErrInvalidHeader    = errors.New("file has no SQLite database header")
+	ErrEmptyQuery       = errors.New("query is empty")
+	ErrNoFields         = errors.New("no fields to write")
+	ErrNoKeys           = errors.New("no key fields")
+	ErrNoRows           = errors.New("no rows in result")
+	ErrNotThreadSafe    = errors.New("sqlite library is compiled without thread safety")
+	ErrForeignKeys      = errors.New("foreign keys can't be enabled")
+	ErrInvalidPragma    = errors.New("invalid pragma value")
+	ErrPragmaNotApplied = errors.New("pragma not applied")
+)
+
+// Error is a failure reported by SQLite.
+type Error struct {
+	Code         int // primary result code (vtc.Constraint, vtc.Busy, ...)
+	ExtendedCode int // extended result code (vtc.ConstraintUnique, ...)
+	Message      string
+}
+
+func (e *Error) Error() string {
+	if e.ExtendedCode != e.Code {
+		return fmt.Sprintf("sqlite error: %s (%d/%d)", e.Message, e.Code, e.ExtendedCode)
+	}
+	return fmt.Sprintf("sqlite error: %s (%d)", e.Message, e.Code)
+}
+
+func IsConstraint(err error) bool {
+	return hasCode(err, vtc.Constraint)
+}
+
+func IsUniqueViolation(err error) bool {
+	return hasExtendedCode(err, vtc.ConstraintUnique) || hasExtendedCode(err, vtc.ConstraintPrimaryKey)
+}
+
+func IsForeignKeyViolation(err error) bool {
+	return hasExtendedCode(err, vtc.ConstraintForeignKey)
+}
+
+func IsNotNullViolation(err error) bool {
+	return hasExtendedCode(err, vtc.ConstraintNotNull)
+}
+
+func IsBusy(err error) bool {
+	return hasCode(err, vtc.Busy) || hasCode(err, vtc.Locked)
+}
+
+func IsReadOnly(err error) bool {
+	return hasCode(err, vtc.ReadOnly)
+}
+
+func IsCorrupt(err error) bool {
+	return hasCode(err, vtc.Corrupt) || hasCode(err, vtc.NotADb)
+}
+
+// IsInterrupt reports a query stopped by cancellation of its context.
+func IsInterrupt(err error) bool {
+	return hasCode(err, vtc.Interrupt)
+}
+
+func hasCode(err error, code int) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
+
+func hasExtendedCode(err error, code int) bool {
+	var e *Error
+	return errors.As(err, &e) && e.ExtendedCode == code
+}
@@ -30,13 +30,21 @@ package field
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"reflect"
 	"strings"
+	"time"
 
 	"Timelancer/sqlite/vtc"
 )
 
+// ErrUnsupportedType is returned by Set for values which can't be stored.
+var ErrUnsupportedType = errors.New("unsupported value type")
+
 type Field struct {
 	Name      string        `json:"name"`
+	Table     string        `json:"table,omitempty"` // table of the column (if known)
 	Value     interface{}   `json:"value"`
 	ValueType vtc.ValueType `json:"type"`
 }
@@ -61,21 +69,43 @@ func (f *Field) Valid() bool {
 		return false
 	}
 	switch f.ValueType {
-	case vtc.Text, vtc.Int, vtc.Float, vtc.Blob, vtc.Null:
+	case vtc.Text, vtc.Int, vtc.Float, vtc.Blob, vtc.Bool, vtc.Null:
 		return true
 	default:
 		return false
 	}
 }
 
+// SetValue sets the value, values of unsupported types are set as NULL
+// (see Set).
 func (f *Field) SetValue(v interface{}) *Field {
+	f.Set(v)
+	return f
+}
+
+// Set sets the value and its type. Integers of every kind (named types
+// too) are stored as int64, time.Time as Unix seconds. Fails with
+// ErrUnsupportedType (the value is set as NULL then) if the value can't
+// be stored, e.g. uint64 greater than math.MaxInt64 or a struct.
+func (f *Field) Set(v interface{}) error {
 	f.Value = v
 
 	switch v.(type) {
+	case nil:
+		f.ValueType = vtc.Null
 	case string:
 		f.ValueType = vtc.Text
 	case int64:
 		f.ValueType = vtc.Int
+	case int:
+		f.Value = int64(v.(int))
+		f.ValueType = vtc.Int
+	case int32:
+		f.Value = int64(v.(int32))
+		f.ValueType = vtc.Int
+	case uint32:
+		f.Value = int64(v.(uint32))
+		f.ValueType = vtc.Int
 	case float32:
 		f.Value = float64(v.(float32))
 		f.ValueType = vtc.Float
@@ -90,11 +120,44 @@ func (f *Field) SetValue(v interface{}) *Field {
 		} else {
 			f.Value = int64(0)
 		}
+		f.ValueType = vtc.Bool
+	case time.Time:
+		f.Value = v.(time.Time).Unix()
 		f.ValueType = vtc.Int
 	default:
-		f.ValueType = vtc.Null
+		return f.setKind(v)
 	}
-	return f
+	return nil
+}
+
+// setKind sets values of named types and integers of other sizes.
+func (f *Field) setKind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.Value = rv.Int()
+		f.ValueType = vtc.Int
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() <= math.MaxInt64 {
+			f.Value = int64(rv.Uint())
+			f.ValueType = vtc.Int
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		f.Value = rv.Float()
+		f.ValueType = vtc.Float
+		return nil
+	case reflect.String:
+		f.Value = rv.String()
+		f.ValueType = vtc.Text
+		return nil
+	case reflect.Bool:
+		return f.Set(rv.Bool())
+	}
+	f.Value = nil
+	f.ValueType = vtc.Null
+	return fmt.Errorf("%w: %T", ErrUnsupportedType, v)
 }
 
 func (f *Field) Int64() (int64, error) {
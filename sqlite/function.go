@@ -0,0 +1,170 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+#include <sqlite3.h>
+
+#cgo LDFLAGS: -lsqlite3
+
+extern void goFuncCallback(sqlite3_context*, int, sqlite3_value**);
+extern void goFuncDestroy(void*);
+
+static int create_function(sqlite3 *db, const char *name, int n, int flags, uintptr_t handle) {
+	return sqlite3_create_function_v2(db, name, n, flags, (void *)handle, goFuncCallback, NULL, NULL, goFuncDestroy);
+}
+
+static void result_text(sqlite3_context *ctx, const char *txt) {
+	sqlite3_result_text(ctx, txt, -1, SQLITE_TRANSIENT);
+}
+
+static void result_blob(sqlite3_context *ctx, const void *data, int n) {
+	sqlite3_result_blob(ctx, data, n, SQLITE_TRANSIENT);
+}
+*/
+import "C"
+import (
+	"fmt"
+	"runtime/cgo"
+	"unsafe"
+
+	"Timelancer/sqlite/field"
+	"Timelancer/sqlite/vtc"
+)
+
+// Func is a SQL function implemented in Go. Arguments are fields
+// without names, the result may be anything accepted by field.SetValue
+// (nil is NULL). Returned error aborts the statement.
+// Func runs inside of the statement, so it must not use the database.
+type Func func(args []*field.Field) (interface{}, error)
+
+// RegisterFunc makes fn callable from SQL as name (nArgs -1 means
+// any number of arguments). Deterministic functions (the same result
+// for the same arguments) can be used in indexes and are optimized.
+func (db *Database) RegisterFunc(name string, nArgs int, deterministic bool, fn Func) error {
+	db.lock()
+	defer db.unlock()
+
+	if db.ptr == nil {
+		return ErrNotOpened
+	}
+	return db.registerFunc(name, nArgs, deterministic, fn)
+}
+
+/********************************************************************
+*                                                                   *
+*                         P R I V A T E                             *
+*                                                                   *
+********************************************************************/
+
+func (db *Database) registerFunc(name string, nArgs int, deterministic bool, fn Func) error {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	flags := C.int(C.SQLITE_UTF8)
+	if deterministic {
+		flags |= C.SQLITE_DETERMINISTIC
+	}
+
+	// handle is released by SQLite (goFuncDestroy), also when create fails
+	handle := cgo.NewHandle(fn)
+	if retv := C.create_function(db.ptr, cname, C.int(nArgs), flags, C.uintptr_t(handle)); retv != C.SQLITE_OK {
+		return db.error(retv)
+	}
+	return nil
+}
+
+func callFunc(ctx *C.sqlite3_context, fn Func, values []*C.sqlite3_value) {
+	args := make([]*field.Field, len(values))
+	for i, value := range values {
+		args[i] = functionArg(value)
+	}
+
+	result, err := fn(args)
+	if err != nil {
+		cstr := C.CString(err.Error())
+		defer C.free(unsafe.Pointer(cstr))
+		C.sqlite3_result_error(ctx, cstr, -1)
+		return
+	}
+	setFunctionResult(ctx, result)
+}
+
+func functionArg(value *C.sqlite3_value) *field.Field {
+	f := &field.Field{}
+
+	switch C.sqlite3_value_type(value) {
+	case C.SQLITE_INTEGER:
+		return f.SetValue(int64(C.sqlite3_value_int64(value)))
+	case C.SQLITE_FLOAT:
+		return f.SetValue(float64(C.sqlite3_value_double(value)))
+	case C.SQLITE_TEXT:
+		n := C.sqlite3_value_bytes(value)
+		return f.SetValue(C.GoStringN((*C.char)(unsafe.Pointer(C.sqlite3_value_text(value))), n))
+	case C.SQLITE_BLOB:
+		n := C.sqlite3_value_bytes(value)
+		return f.SetValue(C.GoBytes(C.sqlite3_value_blob(value), n))
+	}
+	return f.SetValue(nil)
+}
+
+func setFunctionResult(ctx *C.sqlite3_context, result interface{}) {
+	f := (&field.Field{}).SetValue(result)
+	if result != nil && f.ValueType == vtc.Null {
+		cstr := C.CString(fmt.Sprintf("unsupported result type %T", result))
+		defer C.free(unsafe.Pointer(cstr))
+		C.sqlite3_result_error(ctx, cstr, -1)
+		return
+	}
+
+	switch f.ValueType {
+	case vtc.Int, vtc.Bool:
+		v, _ := f.Int64()
+		C.sqlite3_result_int64(ctx, C.sqlite3_int64(v))
+	case vtc.Float:
+		v, _ := f.Float64()
+		C.sqlite3_result_double(ctx, C.double(v))
+	case vtc.Text:
+		v, _ := f.Text()
+		cstr := C.CString(v)
+		defer C.free(unsafe.Pointer(cstr))
+		C.result_text(ctx, cstr)
+	case vtc.Blob:
+		v, _ := f.Blob()
+		if len(v) == 0 {
+			C.sqlite3_result_zeroblob(ctx, 0)
+			return
+		}
+		C.result_blob(ctx, unsafe.Pointer(&v[0]), C.int(len(v)))
+	default:
+		C.sqlite3_result_null(ctx)
+	}
+}
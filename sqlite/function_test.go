@@ -0,0 +1,109 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+
+	"Timelancer/sqlite/field"
+)
+
+func selectOne(t *testing.T, db *Database, query string, args ...interface{}) *field.Field {
+	result, err := db.Select(query, args...)
+	if assert.Nil(t, err) && assert.Len(t, result, 1) {
+		return result[0].Field("v")
+	}
+	return nil
+}
+
+func Test_RegisterFunc(t *testing.T) {
+	db := newTestDatabase(t)
+
+	err := db.RegisterFunc("twice", 1, true, func(args []*field.Field) (interface{}, error) {
+		if n, err := args[0].Int64(); err == nil {
+			return n * 2, nil
+		}
+		return nil, errors.New("twice: integer expected")
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, int64(42), selectOne(t, db, "SELECT twice(?) AS v", 21).Value)
+	_, err = db.Select("SELECT twice('x') AS v")
+	if assert.NotNil(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "twice: integer expected"))
+	}
+
+	// function can be replaced
+	err = db.RegisterFunc("twice", 1, true, func(args []*field.Field) (interface{}, error) {
+		return "replaced", nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "replaced", selectOne(t, db, "SELECT twice(1) AS v").Value)
+}
+
+func Test_BuiltinFunctions(t *testing.T) {
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := time.Local
+	time.Local = warsaw
+	defer func() { time.Local = local }()
+
+	db := newTestDatabase(t)
+
+	// 00:30 of the day the summer time ends is still the previous day in UTC
+	ts := time.Date(2019, 10, 27, 0, 30, 0, 0, warsaw).Unix()
+	assert.Equal(t, "2019-10-27", selectOne(t, db, "SELECT tl_local_day(?) AS v", ts).Value)
+	assert.Equal(t, "2019-10-26", selectOne(t, db, "SELECT date(?, 'unixepoch') AS v", ts).Value)
+	assert.Equal(t, "2019-10", selectOne(t, db, "SELECT tl_local_month(?) AS v", ts).Value)
+
+	ts = time.Date(2021, 1, 3, 12, 0, 0, 0, warsaw).Unix()
+	assert.Equal(t, "2020-W53", selectOne(t, db, "SELECT tl_iso_week(?) AS v", ts).Value)
+
+	assert.Equal(t, int64(60), selectOne(t, db, "SELECT tl_round_minutes(3700, 15) AS v").Value)
+	assert.Equal(t, int64(75), selectOne(t, db, "SELECT tl_round_minutes(4600, 15) AS v").Value)
+	assert.Nil(t, selectOne(t, db, "SELECT tl_local_day(NULL) AS v").Value)
+	_, err = db.Select("SELECT tl_round_minutes(60, 0) AS v")
+	assert.NotNil(t, err)
+
+	// aggregation by local day in SQL
+	result, err := db.Select("SELECT tl_local_day(start) AS day, SUM(finish-start) AS total FROM timer GROUP BY day")
+	assert.Nil(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Equal(t, "1970-01-01", result[0].Field("day").Value)
+		assert.Equal(t, int64(6*50), result[0].Field("total").Value)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"iter"
+
+	"Timelancer/sqlite/mapper"
+)
+
+// Query runs the query and returns iterator of its rows mapped to T
+// (a struct with db tags, see package mapper). Rows are read one by one,
+// the result is never kept in memory as a whole. Iteration stops after
+// the first error:
+//
+//	for tm, err := range sqlite.Query[Timer](db, "SELECT * FROM timer") {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Query[T any](db *Database, query string, args ...interface{}) iter.Seq2[T, error] {
+	return QueryContext[T](context.Background(), db, query, args...)
+}
+
+// QueryContext works like Query, but stops the query when ctx is done
+// (the error is then reported by IsInterrupt).
+func QueryContext[T any](ctx context.Context, db *Database, query string, args ...interface{}) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if ctx.Err() != nil {
+			yield(zero, interruptError(ctx))
+			return
+		}
+
+		stmt, err := db.Prepare(query)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer stmt.Close()
+
+		if ctx.Done() != nil {
+			stop := stmt.interruptOn(ctx)
+			defer stop()
+		}
+
+		if err := stmt.BindArgs(args...); err != nil {
+			yield(zero, err)
+			return
+		}
+		for {
+			if ctx.Err() != nil {
+				yield(zero, interruptError(ctx))
+				return
+			}
+			ok, err := stmt.Step()
+			if err != nil {
+				if IsInterrupt(err) && ctx.Err() != nil {
+					err = interruptError(ctx)
+				}
+				yield(zero, err)
+				return
+			}
+			if !ok {
+				return
+			}
+
+			var v T
+			if err := mapper.Scan(stmt.Row(), &v); err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
+
+// QueryAll returns all rows of the query mapped to T.
+func QueryAll[T any](db *Database, query string, args ...interface{}) ([]T, error) {
+	var data []T
+	for v, err := range Query[T](db, query, args...) {
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, v)
+	}
+	return data, nil
+}
+
+// QueryOne returns the first row of the query mapped to T,
+// ErrNoRows if there are no rows.
+func QueryOne[T any](db *Database, query string, args ...interface{}) (T, error) {
+	for v, err := range Query[T](db, query, args...) {
+		return v, err
+	}
+	var zero T
+	return zero, ErrNoRows
+}
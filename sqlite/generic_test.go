@@ -0,0 +1,151 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"Timelancer/sqlite/mapper"
+)
+
+type record struct {
+	id      int64     `db:"id,pk"`
+	Name    string    `db:"name"`
+	used    bool      `db:"used"`
+	rate    float64   `db:"rate"`
+	created time.Time `db:"created"`
+	comment *string   `db:"comment"`
+	data    []byte    `db:"data"`
+}
+
+const recordScheme = `CREATE TABLE record
+(
+id      INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+name    TEXT NOT NULL,
+used    INTEGER NOT NULL,
+rate    REAL,
+created INTEGER,
+comment TEXT,
+data    BLOB
+);`
+
+type timerRow struct {
+	ID     int64 `db:"id"`
+	Start  int64 `db:"start"`
+	Finish int64 `db:"finish"`
+}
+
+func Test_MapperRoundTrip(t *testing.T) {
+	db := New()
+	assert.Nil(t, db.Create(filepath.Join(t.TempDir(), "mapper.sqlite"), recordScheme))
+	defer db.Close()
+
+	created := time.Unix(1570000000, 0)
+	in := record{Name: "CTX", used: true, rate: 0.25, created: created, data: []byte("blob")}
+	fields, err := mapper.Fields(&in)
+	assert.Nil(t, err)
+	id, err := db.Insert("record", fields)
+	assert.Nil(t, err)
+
+	out, err := QueryOne[record](db, "SELECT * FROM record WHERE id=?", id)
+	assert.Nil(t, err)
+	in.id = id
+	assert.Equal(t, in, out)
+
+	out.used = false
+	fields, err = mapper.Fields(&out)
+	assert.Nil(t, err)
+	n, err := db.Update("record", mapper.Keys(&out), fields)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = db.CountWhere("record", "used=?", false)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func Test_QueryAllAndOne(t *testing.T) {
+	db := newTestDatabase(t)
+
+	data, err := QueryAll[timerRow](db, "SELECT id, start, finish FROM timer WHERE company_id=? ORDER BY id", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []timerRow{{3, 100, 150}, {4, 200, 250}}, data)
+
+	data, err = QueryAll[timerRow](db, "SELECT id, start, finish FROM timer WHERE company_id=?", 100)
+	assert.Nil(t, err)
+	assert.Empty(t, data)
+
+	// every tagged field needs its column
+	_, err = QueryAll[timerRow](db, "SELECT id, start FROM timer")
+	assert.NotNil(t, err)
+
+	one, err := QueryOne[timerRow](db, "SELECT id, start, finish FROM timer ORDER BY id DESC")
+	assert.Nil(t, err)
+	assert.Equal(t, timerRow{6, 200, 250}, one)
+
+	_, err = QueryOne[timerRow](db, "SELECT id, start, finish FROM timer WHERE id=?", 100)
+	assert.ErrorIs(t, err, ErrNoRows)
+
+	_, err = QueryOne[int](db, "SELECT id FROM timer")
+	assert.ErrorIs(t, err, mapper.ErrNotStructPointer)
+}
+
+func Test_QueryIterator(t *testing.T) {
+	db := newTestDatabase(t)
+
+	// breaking the loop closes the statement
+	var ids []int64
+	for tm, err := range Query[timerRow](db, "SELECT id, start, finish FROM timer ORDER BY id") {
+		assert.Nil(t, err)
+		ids = append(ids, tm.ID)
+		if len(ids) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []int64{1, 2}, ids)
+	assert.Nil(t, db.Exec("DELETE FROM timer"))
+
+	for _, err := range Query[timerRow](db, "SELECT * FROM none") {
+		assert.NotNil(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	count := 0
+	for _, err := range QueryContext[timerRow](ctx, db, heavyQuery) {
+		assert.True(t, IsInterrupt(err))
+		count++
+	}
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,318 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"Timelancer/sqlite/field"
+	"Timelancer/sqlite/row"
+	"Timelancer/sqlite/vtc"
+)
+
+// Struct fields are mapped to columns with tags:
+//
+//	id       int       `db:"id,pk"`
+//	name     string    `db:"name"`
+//	used     bool      `db:"used"`
+//	created  time.Time `db:"created"`
+//	comment  *string   `db:"comment"`
+//
+// Fields without a tag (or tagged "-") are ignored, unexported fields
+// are mapped too. Primary key fields (pk option) are returned by Keys
+// (for sqlite.Update and sqlite.Upsert), Fields skips them while they
+// have zero value (a new row, the key is assigned by database).
+// NULL is scanned as zero value (nil for pointers) and zero time.Time,
+// nil pointer or nil []byte is written as NULL. bool is stored as 0/1 and
+// time.Time as Unix seconds.
+
+var (
+	ErrNotStructPointer = errors.New("destination is not a pointer to struct")
+	ErrNotStruct        = errors.New("source is not a struct")
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type column struct {
+	name  string
+	index []int
+	pk    bool
+}
+
+var cache sync.Map // reflect.Type -> []column
+
+// Scan assigns values of the row to the tagged fields of the struct
+// pointed by dest. Every tagged column must be present in the row.
+func Scan(r row.Row, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrNotStructPointer
+	}
+	v = v.Elem()
+
+	for _, c := range columns(v.Type()) {
+		f := r.Field(c.name)
+		if f == nil {
+			return fmt.Errorf("column %s is missing", c.name)
+		}
+		if err := assign(fieldValue(v, c.index), f); err != nil {
+			return fmt.Errorf("column %s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+// Fields returns values of the tagged fields of src (struct or pointer
+// to struct) ready for sqlite.Insert and sqlite.Update.
+func Fields(src interface{}) ([]*field.Field, error) {
+	v := reflect.ValueOf(src)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, ErrNotStruct
+	}
+	if !v.CanAddr() {
+		// unexported fields are read through their address
+		tmp := reflect.New(v.Type()).Elem()
+		tmp.Set(v)
+		v = tmp
+	}
+
+	var data []*field.Field
+	for _, c := range columns(v.Type()) {
+		fv := fieldValue(v, c.index)
+		if c.pk && fv.IsZero() {
+			continue
+		}
+		value, err := value(fv)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", c.name, err)
+		}
+		data = append(data, field.NewWithValue(c.name, value))
+	}
+	return data, nil
+}
+
+// Keys returns names of the primary key columns of src (struct or pointer to struct).
+func Keys(src interface{}) []string {
+	t := reflect.TypeOf(src)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var keys []string
+	for _, c := range columns(t) {
+		if c.pk {
+			keys = append(keys, c.name)
+		}
+	}
+	return keys
+}
+
+/********************************************************************
+*                                                                   *
+*                         P R I V A T E                             *
+*                                                                   *
+********************************************************************/
+
+func columns(t reflect.Type) []column {
+	if cached, ok := cache.Load(t); ok {
+		return cached.([]column)
+	}
+
+	var pk, others []column
+	collectColumns(t, nil, &pk, &others)
+	data := append(pk, others...)
+
+	cache.Store(t, data)
+	return data
+}
+
+func collectColumns(t reflect.Type, parent []int, pk, others *[]column) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		index := append(append([]int{}, parent...), i)
+
+		tag, tagged := sf.Tag.Lookup("db")
+		if !tagged && sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			collectColumns(sf.Type, index, pk, others)
+			continue
+		}
+		if !tagged || tag == "-" {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		c := column{name: parts[0], index: index}
+		for _, option := range parts[1:] {
+			if option == "pk" {
+				c.pk = true
+			}
+		}
+		if c.pk {
+			*pk = append(*pk, c)
+		} else {
+			*others = append(*others, c)
+		}
+	}
+}
+
+// fieldValue returns settable value of the (possibly unexported) field.
+func fieldValue(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		v = v.Field(i)
+	}
+	if !v.CanSet() {
+		v = reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+	}
+	return v
+}
+
+func assign(dest reflect.Value, f *field.Field) error {
+	if f.ValueType == vtc.Null {
+		dest.Set(reflect.Zero(dest.Type()))
+		return nil
+	}
+	if dest.Kind() == reflect.Ptr {
+		ptr := reflect.New(dest.Type().Elem())
+		if err := assign(ptr.Elem(), f); err != nil {
+			return err
+		}
+		dest.Set(ptr)
+		return nil
+	}
+
+	if dest.Type() == timeType {
+		n, err := f.Int64()
+		if err != nil {
+			return err
+		}
+		dest.Set(reflect.ValueOf(time.Unix(n, 0)))
+		return nil
+	}
+
+	switch dest.Kind() {
+	case reflect.Bool:
+		b, err := f.Bool()
+		if err != nil {
+			return err
+		}
+		dest.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := f.Int64()
+		if err != nil {
+			return err
+		}
+		dest.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := f.UInt64()
+		if err != nil {
+			return err
+		}
+		dest.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		x, err := f.Float64()
+		if err != nil {
+			// column with REAL affinity may keep integer values
+			n, err := f.Int64()
+			if err != nil {
+				return err
+			}
+			x = float64(n)
+		}
+		dest.SetFloat(x)
+	case reflect.String:
+		text, err := f.Text()
+		if err != nil {
+			return err
+		}
+		dest.SetString(text)
+	case reflect.Slice:
+		if dest.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", dest.Type())
+		}
+		data, err := f.Blob()
+		if err != nil {
+			return err
+		}
+		dest.SetBytes(data)
+	default:
+		return fmt.Errorf("unsupported type %s", dest.Type())
+	}
+	return nil
+}
+
+// value converts the field to a value accepted by field.SetValue.
+func value(v reflect.Value) (interface{}, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return nil, nil
+		}
+		return t.Unix(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.IsNil() {
+				return nil, nil
+			}
+			return v.Bytes(), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
@@ -0,0 +1,117 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package mapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"Timelancer/sqlite/field"
+	"Timelancer/sqlite/row"
+	"Timelancer/sqlite/vtc"
+)
+
+type base struct {
+	id int64 `db:"id,pk"`
+}
+
+type record struct {
+	base
+	Name    string    `db:"name"`
+	used    bool      `db:"used"`
+	rate    float64   `db:"rate"`
+	created time.Time `db:"created"`
+	comment *string   `db:"comment"`
+	data    []byte    `db:"data"`
+	cache   string
+}
+
+func Test_Fields(t *testing.T) {
+	comment := "note"
+	created := time.Unix(1570000000, 0)
+	r := record{Name: "ACME", used: true, rate: 1.5, created: created, comment: &comment, cache: "x"}
+
+	fields, err := Fields(&r)
+	assert.Nil(t, err)
+
+	// zero primary key is skipped
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	assert.Equal(t, []string{"name", "used", "rate", "created", "comment", "data"}, names)
+	assert.Equal(t, vtc.Bool, fields[1].ValueType)
+	assert.Equal(t, int64(1570000000), fields[3].Value)
+	assert.Equal(t, vtc.Null, fields[5].ValueType)
+
+	// primary key goes first, a struct value works as well as a pointer
+	r.id = 7
+	r.created = time.Time{}
+	fields, err = Fields(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "id", fields[0].Name)
+	assert.Equal(t, vtc.Null, fields[4].ValueType)
+
+	_, err = Fields(42)
+	assert.ErrorIs(t, err, ErrNotStruct)
+
+	assert.Equal(t, []string{"id"}, Keys(r))
+}
+
+func Test_Scan(t *testing.T) {
+	r := row.New()
+	r.Append(field.NewWithValue("id", 3))
+	r.Append(field.NewWithValue("name", "BEE"))
+	r.Append(field.NewWithValue("used", int64(0)))
+	r.Append(field.NewWithValue("rate", int64(2)))
+	r.Append(field.NewWithValue("created", nil))
+	r.Append(field.NewWithValue("comment", "text"))
+	r.Append(field.NewWithValue("data", []byte{1, 2}))
+
+	var rec record
+	assert.Nil(t, Scan(r, &rec))
+	assert.Equal(t, int64(3), rec.id)
+	assert.Equal(t, "BEE", rec.Name)
+	assert.False(t, rec.used)
+	assert.Equal(t, 2.0, rec.rate)
+	assert.True(t, rec.created.IsZero())
+	if assert.NotNil(t, rec.comment) {
+		assert.Equal(t, "text", *rec.comment)
+	}
+	assert.Equal(t, []byte{1, 2}, rec.data)
+
+	assert.ErrorIs(t, Scan(r, rec), ErrNotStructPointer)
+
+	assert.NotNil(t, Scan(r[:len(r)-1], &rec))
+
+	r[len(r)-1] = field.NewWithValue("data", "not a number")
+	assert.NotNil(t, Scan(r, &rec))
+}
@@ -0,0 +1,263 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+/*
+#include <stdint.h>
+#include <sqlite3.h>
+
+#cgo LDFLAGS: -lsqlite3
+
+extern void goUpdateHook(void*, int, char*, char*, sqlite3_int64);
+extern int goCommitHook(void*);
+extern void goRollbackHook(void*);
+
+static void set_hooks(sqlite3 *db, uintptr_t handle) {
+	sqlite3_update_hook(db, (void (*)(void*, int, const char*, const char*, sqlite3_int64))goUpdateHook, (void *)handle);
+	sqlite3_commit_hook(db, goCommitHook, (void *)handle);
+	sqlite3_rollback_hook(db, goRollbackHook, (void *)handle);
+}
+*/
+import "C"
+import (
+	"runtime/cgo"
+	"sort"
+	"sync"
+)
+
+type Operation int
+
+const (
+	OpInsert Operation = iota + 1
+	OpUpdate
+	OpDelete
+)
+
+func (op Operation) String() string {
+	switch op {
+	case OpInsert:
+		return "insert"
+	case OpUpdate:
+		return "update"
+	case OpDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// Change is a row modified by a committed transaction.
+type Change struct {
+	Table string
+	Op    Operation
+	RowID int64
+}
+
+// Changes are all changes of one transaction, in order.
+type Changes []Change
+
+// Touches reports if any of the tables was changed.
+func (changes Changes) Touches(tables ...string) bool {
+	for _, c := range changes {
+		for _, table := range tables {
+			if c.Table == table {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// notifier collects changes of the running transaction and delivers
+// them to subscribers after commit (rolled back changes are dropped,
+// also the ones undone by a nested WithTx rolled back to its savepoint).
+// The commit hook runs before the commit is durable, so the changes are
+// only put aside there and delivered when the committing statement
+// succeeds (they are dropped if it fails).
+// Content replaced by Restore is not reported.
+type notifier struct {
+	handle     cgo.Handle
+	pending    Changes // used only under the connection lock (hooks run under it too)
+	committing Changes // changes of the commit in progress, as above
+	marks      []int   // length of pending at the start of every savepoint, as above
+
+	mu          sync.Mutex
+	subscribers map[int]func(Changes)
+	nextID      int
+	dispatch    func(func())
+}
+
+// Subscribe registers fn called with the changes of every committed
+// transaction. fn is run by the dispatcher (see SetDispatcher).
+// Returned function cancels the subscription, fn is not called after it.
+func (db *Database) Subscribe(fn func(Changes)) func() {
+	n := &db.notifier
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.subscribers == nil {
+		n.subscribers = make(map[int]func(Changes))
+	}
+	n.nextID++
+	id := n.nextID
+	n.subscribers[id] = fn
+
+	return func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.subscribers, id)
+	}
+}
+
+// SetDispatcher sets function running notifications of subscribers,
+// e.g. glib.IdleAdd to get them in the GTK main loop.
+// By default every notification runs in a new goroutine. Dispatcher is
+// called under the connection lock (just after the committing statement),
+// so it must not run subscribers using the database synchronously.
+func (db *Database) SetDispatcher(dispatch func(func())) {
+	n := &db.notifier
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.dispatch = dispatch
+}
+
+/********************************************************************
+*                                                                   *
+*                         P R I V A T E                             *
+*                                                                   *
+********************************************************************/
+
+func (n *notifier) install(ptr *C.sqlite3) {
+	n.handle = cgo.NewHandle(n)
+	C.set_hooks(ptr, C.uintptr_t(n.handle))
+}
+
+func (n *notifier) uninstall() {
+	if n.handle != 0 {
+		n.handle.Delete()
+		n.handle = 0
+	}
+	n.pending = nil
+	n.committing = nil
+	n.marks = nil
+}
+
+func (n *notifier) changed(table string, op Operation, rowID int64) {
+	n.pending = append(n.pending, Change{Table: table, Op: op, RowID: rowID})
+}
+
+// savepointStarted remembers where changes of the savepoint (of nested
+// WithTx) begin.
+func (db *Database) savepointStarted() {
+	db.lock()
+	defer db.unlock()
+	n := &db.notifier
+	n.marks = append(n.marks, len(n.pending))
+}
+
+// savepointEnded forgets the last savepoint, its changes are dropped
+// if it was rolled back.
+func (db *Database) savepointEnded(rolledBack bool) {
+	db.lock()
+	defer db.unlock()
+	n := &db.notifier
+	if len(n.marks) == 0 {
+		// the whole transaction was already rolled back
+		return
+	}
+	mark := n.marks[len(n.marks)-1]
+	n.marks = n.marks[:len(n.marks)-1]
+	if rolledBack && mark < len(n.pending) {
+		n.pending = n.pending[:mark]
+	}
+}
+
+// committed is called by the commit hook, the commit may still fail.
+func (n *notifier) committed() {
+	n.committing = append(n.committing, n.pending...)
+	n.pending = nil
+	n.marks = nil
+}
+
+// statementDone is called after every statement (or script) with its
+// result. Changes of the commit are delivered if it succeeded.
+// A script failing after a commit of its former statement drops
+// changes of that commit too (scripts with many transactions are
+// used only for the schema).
+func (n *notifier) statementDone(success bool) {
+	changes := n.committing
+	n.committing = nil
+	if !success || len(changes) == 0 {
+		return
+	}
+
+	n.mu.Lock()
+	ids := n.subscriberIDs()
+	dispatch := n.dispatch
+	n.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+	if dispatch == nil {
+		dispatch = func(f func()) { go f() }
+	}
+
+	// the ones unsubscribed before the notification runs are not called
+	dispatch(func() {
+		for _, fn := range n.subscribersWithIDs(ids) {
+			fn(changes)
+		}
+	})
+}
+
+func (n *notifier) rolledBack() {
+	n.pending = nil
+	n.marks = nil
+}
+
+func (n *notifier) subscriberIDs() []int {
+	ids := make([]int, 0, len(n.subscribers))
+	for id := range n.subscribers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (n *notifier) subscribersWithIDs(ids []int) []func(Changes) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var data []func(Changes)
+	for _, id := range ids {
+		if fn, ok := n.subscribers[id]; ok {
+			data = append(data, fn)
+		}
+	}
+	return data
+}
@@ -0,0 +1,179 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nextChanges(t *testing.T, ch chan Changes) Changes {
+	select {
+	case changes := <-ch:
+		return changes
+	case <-time.After(time.Second):
+		t.Fatal("no notification")
+	}
+	return nil
+}
+
+func Test_Subscribe(t *testing.T) {
+	db := newTestDatabase(t)
+	ch := make(chan Changes, 10)
+	db.SetDispatcher(func(f func()) { f() })
+	unsubscribe := db.Subscribe(func(changes Changes) { ch <- changes })
+
+	assert.Nil(t, db.Exec("UPDATE company SET name=? WHERE id=?", "Acme", 1))
+	assert.Equal(t, Changes{{Table: "company", Op: OpUpdate, RowID: 1}}, nextChanges(t, ch))
+
+	// one notification per transaction, rolled back changes are dropped
+	assert.Nil(t, db.WithTx(func(tx *Tx) error {
+		if err := tx.Exec("DELETE FROM timer WHERE company_id=?", 1); err != nil {
+			return err
+		}
+		return tx.Exec("INSERT INTO company (shortcut, name) VALUES (?, ?)", "DOT", "Dot")
+	}))
+	changes := nextChanges(t, ch)
+	assert.Len(t, changes, 3)
+	assert.Equal(t, Change{Table: "company", Op: OpInsert, RowID: 4}, changes[2])
+	assert.True(t, changes.Touches("timer"))
+	assert.False(t, changes.Touches("project"))
+
+	db.WithTx(func(tx *Tx) error {
+		tx.Exec("DELETE FROM timer")
+		return errors.New("failure")
+	})
+	unsubscribe()
+	assert.Nil(t, db.Exec("DELETE FROM timer WHERE company_id=?", 2))
+	assert.Nil(t, db.Exec("DELETE FROM timer WHERE company_id=?", 3))
+
+	assert.Len(t, ch, 0)
+}
+
+func Test_SubscribeDefaultDispatcher(t *testing.T) {
+	db := newTestDatabase(t)
+	ch := make(chan Changes, 10)
+	db.Subscribe(func(changes Changes) {
+		// notification runs outside of the statement, so the database can be used
+		n, err := db.Count("timer")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(5), n)
+		ch <- changes
+	})
+
+	assert.Nil(t, db.Exec("DELETE FROM timer WHERE id=?", 6))
+	assert.Equal(t, Changes{{Table: "timer", Op: OpDelete, RowID: 6}}, nextChanges(t, ch))
+}
+
+func Test_SubscribeFailedCommit(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.sqlite")
+	profile := &Pragmas{ForeignKeys: true, JournalMode: "DELETE"}
+	db, err := Open(filePath, &Options{Create: true, BusyTimeout: -1, Pragmas: profile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	assert.Nil(t, db.ExecQuery(testScheme))
+
+	ch := make(chan Changes, 10)
+	db.SetDispatcher(func(f func()) { f() })
+	db.Subscribe(func(changes Changes) { ch <- changes })
+
+	// the reader keeps the shared lock, so the commit can't get the exclusive one
+	reader, err := Open(filePath, &Options{Pragmas: profile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	assert.Nil(t, reader.Exec("INSERT INTO company (shortcut, name) VALUES (?, ?)", "ACME", "Acme"))
+	stmt, err := reader.Prepare("SELECT id FROM company")
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := stmt.Step()
+	assert.True(t, row)
+	assert.Nil(t, err)
+
+	// the commit hook has run, but the failed commit is not reported
+	err = db.Exec("INSERT INTO company (shortcut, name) VALUES (?, ?)", "BEE", "Bee")
+	assert.True(t, IsBusy(err), err)
+	assert.Len(t, ch, 0)
+
+	assert.Nil(t, stmt.Close())
+	assert.Nil(t, db.Exec("UPDATE company SET name=? WHERE id=?", "Acme Inc.", 1))
+	assert.Equal(t, Changes{{Table: "company", Op: OpUpdate, RowID: 1}}, nextChanges(t, ch))
+	n, err := db.Count("company")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func Test_SubscribeNestedRollback(t *testing.T) {
+	db := newTestDatabase(t)
+	ch := make(chan Changes, 10)
+	db.SetDispatcher(func(f func()) { f() })
+	db.Subscribe(func(changes Changes) { ch <- changes })
+
+	// changes of the failed nested transaction are not reported
+	assert.Nil(t, db.WithTx(func(tx *Tx) error {
+		tx.WithTx(func(tx *Tx) error {
+			if err := tx.Exec("INSERT INTO company (shortcut, name) VALUES (?, ?)", "DOT", "Dot"); err != nil {
+				return err
+			}
+			return errors.New("failure")
+		})
+		return nil
+	}))
+	assert.Len(t, ch, 0)
+
+	assert.Nil(t, db.WithTx(func(tx *Tx) error {
+		if err := tx.Exec("UPDATE company SET name=? WHERE id=?", "Acme", 1); err != nil {
+			return err
+		}
+		tx.WithTx(func(tx *Tx) error {
+			if err := tx.Exec("DELETE FROM timer WHERE company_id=?", 1); err != nil {
+				return err
+			}
+			return errors.New("failure")
+		})
+		return tx.WithTx(func(tx *Tx) error {
+			return tx.Exec("UPDATE company SET name=? WHERE id=?", "Bee", 2)
+		})
+	}))
+	assert.Equal(t, Changes{
+		{Table: "company", Op: OpUpdate, RowID: 1},
+		{Table: "company", Op: OpUpdate, RowID: 2},
+	}, nextChanges(t, ch))
+	n, err := db.Count("timer")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), n)
+}
@@ -0,0 +1,185 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pragmas is the profile applied on every connection opened
+// by the Database (DefaultPragmas if Options.Pragmas is nil).
+type Pragmas struct {
+	ForeignKeys bool   // enforce FOREIGN KEY constraints
+	JournalMode string // DELETE, TRUNCATE, PERSIST, MEMORY, WAL or OFF, empty keeps the mode of the database
+	Synchronous string // OFF, NORMAL, FULL or EXTRA, empty keeps the default
+	CacheSize   int    // number of pages if positive, KiB if negative, zero keeps the default
+}
+
+var (
+	journalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	syncModes    = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+// DefaultPragmas returns the profile of the application database:
+// enforced foreign keys, WAL journal (readers don't wait for the writer)
+// and NORMAL synchronization (safe with WAL) with 8MB of cache.
+func DefaultPragmas() *Pragmas {
+	return &Pragmas{
+		ForeignKeys: true,
+		JournalMode: "WAL",
+		Synchronous: "NORMAL",
+		CacheSize:   -8000,
+	}
+}
+
+// Validate checks names of the modes, they are put into queries as they are.
+func (p *Pragmas) Validate() error {
+	if p.JournalMode != "" && !containsString(journalModes, strings.ToUpper(p.JournalMode)) {
+		return fmt.Errorf("%w: journal_mode %q", ErrInvalidPragma, p.JournalMode)
+	}
+	if p.Synchronous != "" && !containsString(syncModes, strings.ToUpper(p.Synchronous)) {
+		return fmt.Errorf("%w: synchronous %q", ErrInvalidPragma, p.Synchronous)
+	}
+	return nil
+}
+
+// Pragmas returns the profile the connection really uses.
+func (db *Database) Pragmas() (*Pragmas, error) {
+	foreignKeys, err := db.pragmaInt("foreign_keys")
+	if err != nil {
+		return nil, err
+	}
+	journalMode, err := db.pragmaText("journal_mode")
+	if err != nil {
+		return nil, err
+	}
+	synchronous, err := db.pragmaInt("synchronous")
+	if err != nil {
+		return nil, err
+	}
+	cacheSize, err := db.pragmaInt("cache_size")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pragmas{
+		ForeignKeys: foreignKeys == 1,
+		JournalMode: strings.ToUpper(journalMode),
+		CacheSize:   int(cacheSize),
+	}
+	if synchronous >= 0 && int(synchronous) < len(syncModes) {
+		p.Synchronous = syncModes[synchronous]
+	}
+	return p, nil
+}
+
+/********************************************************************
+*                                                                   *
+*                         P R I V A T E                             *
+*                                                                   *
+********************************************************************/
+
+// applyPragmas sets the profile and verifies it was applied. The journal
+// mode is not changed for in-memory and read-only databases (SQLite
+// doesn't allow it).
+func (db *Database) applyPragmas(p *Pragmas, readOnly bool) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	journalMode := strings.ToUpper(p.JournalMode)
+	if db.fpath == Memory || readOnly {
+		journalMode = ""
+	}
+
+	var query strings.Builder
+	if p.ForeignKeys {
+		query.WriteString("PRAGMA foreign_keys=ON;")
+	} else {
+		query.WriteString("PRAGMA foreign_keys=OFF;")
+	}
+	if journalMode != "" {
+		fmt.Fprintf(&query, "PRAGMA journal_mode=%s;", journalMode)
+	}
+	if p.Synchronous != "" {
+		fmt.Fprintf(&query, "PRAGMA synchronous=%s;", strings.ToUpper(p.Synchronous))
+	}
+	if p.CacheSize != 0 {
+		fmt.Fprintf(&query, "PRAGMA cache_size=%d;", p.CacheSize)
+	}
+	if err := db.ExecQuery(query.String()); err != nil {
+		return err
+	}
+
+	current, err := db.Pragmas()
+	if err != nil {
+		return err
+	}
+	// SQLite silently ignores foreign_keys if it's compiled without them
+	if current.ForeignKeys != p.ForeignKeys {
+		return ErrForeignKeys
+	}
+	if journalMode != "" && current.JournalMode != journalMode {
+		return fmt.Errorf("%w: journal_mode is %s instead of %s", ErrPragmaNotApplied, current.JournalMode, journalMode)
+	}
+	if p.Synchronous != "" && current.Synchronous != strings.ToUpper(p.Synchronous) {
+		return fmt.Errorf("%w: synchronous is %s instead of %s", ErrPragmaNotApplied, current.Synchronous, strings.ToUpper(p.Synchronous))
+	}
+	if p.CacheSize != 0 && current.CacheSize != p.CacheSize {
+		return fmt.Errorf("%w: cache_size is %d instead of %d", ErrPragmaNotApplied, current.CacheSize, p.CacheSize)
+	}
+	return nil
+}
+
+func (db *Database) pragmaInt(name string) (int64, error) {
+	result, err := db.Select("PRAGMA " + name)
+	if err != nil {
+		return 0, err
+	}
+	if len(result) == 1 {
+		if f := result[0].At(0); f != nil {
+			return f.Int64()
+		}
+	}
+	return 0, fmt.Errorf("can't read %s", name)
+}
+
+func (db *Database) pragmaText(name string) (string, error) {
+	result, err := db.Select("PRAGMA " + name)
+	if err != nil {
+		return "", err
+	}
+	if len(result) == 1 {
+		if f := result[0].At(0); f != nil {
+			return f.Text()
+		}
+	}
+	return "", fmt.Errorf("can't read %s", name)
+}
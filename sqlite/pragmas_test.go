@@ -0,0 +1,82 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DefaultPragmas(t *testing.T) {
+	db := newTestDatabase(t)
+
+	p, err := db.Pragmas()
+	if assert.Nil(t, err) {
+		assert.Equal(t, DefaultPragmas(), p)
+	}
+
+	// the foreign key of the timer is enforced
+	err = db.Exec("INSERT INTO timer (company_id, start, finish) VALUES (?, ?, ?)", 100, 1, 2)
+	assert.True(t, IsForeignKeyViolation(err))
+	err = db.Delete("company", "id", 1)
+	assert.True(t, IsForeignKeyViolation(err))
+}
+
+func Test_CustomPragmas(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "test.sqlite")
+	profile := &Pragmas{ForeignKeys: false, JournalMode: "delete", Synchronous: "full", CacheSize: 500}
+
+	db, err := Open(filePath, &Options{Create: true, Pragmas: profile})
+	if !assert.Nil(t, err) {
+		return
+	}
+	p, err := db.Pragmas()
+	assert.Nil(t, err)
+	assert.Equal(t, &Pragmas{ForeignKeys: false, JournalMode: "DELETE", Synchronous: "FULL", CacheSize: 500}, p)
+	assert.Nil(t, db.Close())
+
+	// the journal mode of a read-only database is not changed
+	db, err = Open(filePath, &Options{ReadOnly: true})
+	if assert.Nil(t, err) {
+		p, err := db.Pragmas()
+		assert.Nil(t, err)
+		assert.Equal(t, "DELETE", p.JournalMode)
+		assert.True(t, p.ForeignKeys)
+		assert.Nil(t, db.Close())
+	}
+}
+
+func Test_InvalidPragmas(t *testing.T) {
+	_, err := Open(Memory, &Options{Pragmas: &Pragmas{JournalMode: "WAL; DROP TABLE company"}})
+	assert.ErrorIs(t, err, ErrInvalidPragma)
+	_, err = Open(Memory, &Options{Pragmas: &Pragmas{Synchronous: "SOMETIMES"}})
+	assert.ErrorIs(t, err, ErrInvalidPragma)
+}
@@ -32,47 +32,61 @@ import (
 	"fmt"
 	"strings"
 
-	"Timelancer/shared/tr"
 	"Timelancer/sqlite/field"
 	"Timelancer/sqlite/row"
-	"Timelancer/sqlite/vtc"
 )
 
-func (db *Database) SelectAndHandle(query string, handler func(row.Row)) {
-	if db.prepare(query) {
-		defer db.finalize()
+func (db *Database) SelectAndHandle(query string, handler func(row.Row), args ...interface{}) error {
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
 
-		for {
-			if db.fetchAndHandle(handler) == vtc.StatusRow {
-				continue
-			}
-			break
+	if err := stmt.BindArgs(args...); err != nil {
+		return err
+	}
+	for {
+		ok, err := stmt.Step()
+		if err != nil {
+			return err
 		}
+		if !ok {
+			return nil
+		}
+		handler(stmt.Row())
 	}
+}
+
+func (db *Database) Select(query string, args ...interface{}) (row.Result, error) {
+	var result row.Result
 
-	if err := db.ErrorCode(); err != vtc.StatusDone && err != vtc.Ok {
-		db.checkError()
+	err := db.SelectAndHandle(query, func(r row.Row) {
+		result = append(result, r)
+	}, args...)
+	if err != nil {
+		return nil, err
 	}
+	return result, nil
 }
 
-func (db *Database) Select(query string) row.Result {
-	if db.prepare(query) {
-		defer db.finalize()
-
-		if retv := db.fetchResult(); retv != nil {
-			return retv
-		}
+func (db *Database) Exec(query string, args ...interface{}) error {
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return err
 	}
+	defer stmt.Close()
 
-	if err := db.ErrorCode(); err != vtc.StatusDone && err != vtc.Ok {
-		db.checkError()
+	if err := stmt.BindArgs(args...); err != nil {
+		return err
 	}
-	return nil
+	_, err = stmt.Step()
+	return err
 }
 
-func (db *Database) Insert(table string, fields []*field.Field) (int64, bool) {
+func (db *Database) Insert(table string, fields []*field.Field) (int64, error) {
 	if len(fields) == 0 {
-		return -1, false
+		return -1, ErrNoFields
 	}
 
 	var b0, b1 strings.Builder
@@ -88,74 +102,155 @@ func (db *Database) Insert(table string, fields []*field.Field) (int64, bool) {
 	binds := b1.String()
 
 	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, names, binds)
-	if db.prepare(query) {
-		defer db.finalize()
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return -1, err
+	}
+	defer stmt.Close()
 
-		db.bindFields(fields)
-		if retv := db.step(); retv == vtc.Ok || retv == vtc.StatusDone {
-			return db.LastInsertedRowID(), true
-		}
+	if err := stmt.BindFields(fields); err != nil {
+		return -1, err
 	}
-	db.checkError()
-	return -1, false
+	return stmt.insert()
 }
 
-func (db *Database) Update(table string, fields []*field.Field) bool {
-	if len(fields) == 0 {
-		return false
+// Update sets fields of the rows identified by the key columns (names
+// of fields used in WHERE, e.g. "id", or more for a composite key).
+// Returns number of changed rows.
+func (db *Database) Update(table string, keys []string, fields []*field.Field) (int64, error) {
+	keyFields, valueFields, err := splitKeys(keys, fields)
+	if err != nil {
+		return 0, err
+	}
+	if len(valueFields) == 0 {
+		return 0, ErrNoFields
 	}
 
-	var b strings.Builder
-	endIdx := len(fields) - 1
-	for _, f := range fields[:endIdx] {
-		fmt.Fprintf(&b, "%s=:%s,", f.Name, f.Name)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, assignments(valueFields, ":%s"), conditions(keyFields))
+	return db.execWithFields(query, fields)
+}
+
+// Upsert inserts the row or, if a row with the same keys exists (keys
+// must be a primary key or have an unique index), updates its other fields.
+// Returns number of inserted or changed rows.
+func (db *Database) Upsert(table string, keys []string, fields []*field.Field) (int64, error) {
+	_, valueFields, err := splitKeys(keys, fields)
+	if err != nil {
+		return 0, err
 	}
-	fmt.Fprintf(&b, "%s=:%s", fields[endIdx].Name, fields[endIdx].Name)
-	assigns := b.String()
 
-	// zakładam, że pierwsze pole to primary key (odpowiednik rowid)
-	if idValue, err := fields[0].Int64(); tr.IsOK(err) {
-		query := fmt.Sprintf("UPDATE %s SET %s WHERE %s=%d", table, assigns, fields[0].Name, idValue)
-		if db.prepare(query) {
-			defer db.finalize()
+	names := make([]string, len(fields))
+	binds := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+		binds[i] = ":" + f.Name
+	}
 
-			db.bindFields(fields)
-			if retv := db.step(); retv == vtc.Ok || retv == vtc.StatusDone {
-				return true
-			}
-		}
+	action := "NOTHING"
+	if len(valueFields) > 0 {
+		action = "UPDATE SET " + assignments(valueFields, "excluded.%s")
 	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO %s",
+		table, strings.Join(names, ","), strings.Join(binds, ","), strings.Join(keys, ","), action)
+	return db.execWithFields(query, fields)
+}
 
-	db.checkError()
-	return false
+func (db *Database) Delete(table, idColumnName string, idValue int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s=?", table, idColumnName)
+	return db.Exec(query, idValue)
 }
 
-func (db *Database) Delete(table, idColumnName string, idValue int) bool {
-	query := fmt.Sprintf("DELETE FROM %s WHERE %s=%d", table, idColumnName, idValue)
-	return db.ExecQuery(query)
+func (db *Database) Count(table string) (int64, error) {
+	return db.CountWhere(table, "")
 }
 
-func (db *Database) Count(table string) int64 {
+func (db *Database) CountWhereInt(table string, field string, value int) (int64, error) {
+	return db.CountWhere(table, field+"=?", value)
+}
+
+// CountWhere returns number of rows in table matching the condition
+// (condition placeholders are bound with args).
+func (db *Database) CountWhere(table, condition string, args ...interface{}) (int64, error) {
 	query := fmt.Sprintf("SELECT COUNT(*) as count FROM %s", table)
+	if condition != "" {
+		query += " WHERE " + condition
+	}
 
-	if result := db.Select(query); len(result) == 1 {
+	result, err := db.Select(query, args...)
+	if err != nil {
+		return -1, err
+	}
+	if len(result) == 1 {
 		if f := result[0].Field("count"); f != nil {
-			if n, err := f.Int64(); tr.IsOK(err) {
-				return n
-			}
+			return f.Int64()
 		}
 	}
-	return -1
+	return -1, fmt.Errorf("can't count rows of %s", table)
 }
 
-func (db *Database) CountWhereInt(table string, field string, value int) int64 {
-	query := fmt.Sprintf("SELECT COUNT(*) as count FROM %s WHERE %s=%d", table, field, value)
-	if result := db.Select(query); len(result) == 1 {
-		if f := result[0].Field("count"); f != nil {
-			if n, err := f.Int64(); tr.IsOK(err) {
-				return n
+func (db *Database) execWithFields(query string, fields []*field.Field) (int64, error) {
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	if err := stmt.BindFields(fields); err != nil {
+		return 0, err
+	}
+	return stmt.exec()
+}
+
+// splitKeys divides fields into the key fields (in order of keys) and the others.
+func splitKeys(keys []string, fields []*field.Field) ([]*field.Field, []*field.Field, error) {
+	if len(keys) == 0 {
+		return nil, nil, ErrNoKeys
+	}
+
+	var keyFields, valueFields []*field.Field
+	for _, key := range keys {
+		found := false
+		for _, f := range fields {
+			if f.Name == key {
+				keyFields = append(keyFields, f)
+				found = true
+				break
 			}
 		}
+		if !found {
+			return nil, nil, fmt.Errorf("key %s is not among the fields", key)
+		}
+	}
+	for _, f := range fields {
+		if !containsString(keys, f.Name) {
+			valueFields = append(valueFields, f)
+		}
+	}
+	return keyFields, valueFields, nil
+}
+
+// assignments returns "name=value,..." where value is format applied to the name.
+func assignments(fields []*field.Field, format string) string {
+	items := make([]string, len(fields))
+	for i, f := range fields {
+		items[i] = f.Name + "=" + fmt.Sprintf(format, f.Name)
+	}
+	return strings.Join(items, ",")
+}
+
+func conditions(fields []*field.Field) string {
+	items := make([]string, len(fields))
+	for i, f := range fields {
+		items[i] = fmt.Sprintf("%s=:%s", f.Name, f.Name)
+	}
+	return strings.Join(items, " AND ")
+}
+
+func containsString(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
 	}
-	return 0
+	return false
 }
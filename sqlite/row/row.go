@@ -30,44 +30,65 @@ package row
 
 import (
 	"log"
+	"strings"
 
 	"Timelancer/sqlite/field"
 )
 
+// Row keeps columns in the order of the select list, columns
+// with the same name (e.g. timer.id and company.id) are all kept.
 type (
-	Row map[string]*field.Field
+	Row    []*field.Field
 	Result []Row
 )
 
 func New() Row {
-	return make(Row)
+	return Row{}
 }
 
-func (r Row) Append(f *field.Field) bool {
-	if !f.Valid() {
-		log.Printf("Field %s is not valid", f.Name)
+func (r *Row) Append(f *field.Field) bool {
+	if f == nil || !f.Valid() {
+		log.Printf("Field %v is not valid", f)
 		return false
 	}
-	if r.containsField(f.Name) {
-		log.Printf("field %s already exists", f.Name)
-		return false
-	}
-	r[f.Name] = f
+	*r = append(*r, f)
 	return true
 }
 
+// Field returns the first column with the name. The name may be
+// qualified with the table name ("company.id") if the table is known.
 func (r Row) Field(name string) *field.Field {
-	if f, ok := r[name]; ok {
-		return f
+	for _, f := range r {
+		if f.Name == name {
+			return f
+		}
+	}
+	if idx := strings.LastIndex(name, "."); idx > 0 {
+		table, column := name[:idx], name[idx+1:]
+		for _, f := range r {
+			if f.Table == table && f.Name == column {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// At returns the column at index or nil if there is no such column.
+func (r Row) At(index int) *field.Field {
+	if index >= 0 && index < len(r) {
+		return r[index]
 	}
 	return nil
 }
 
-func (r Row) containsField(name string) bool {
-	if _, ok := r[name]; ok {
-		return true
+// Names returns names of the columns in order.
+func (r Row) Names() []string {
+	names := make([]string, len(r))
+	for i, f := range r {
+		names[i] = f.Name
 	}
-	return false
+	return names
 }
 
 func (r Row) Count() int {
@@ -36,32 +36,87 @@ package sqlite
 */
 import "C"
 import (
-	"crypto/subtle"
 	"fmt"
-	"log"
 	"os"
-	"runtime"
 	"sync"
+	"time"
 	"unsafe"
-
-	"Timelancer/shared/tr"
-	"Timelancer/sqlite/vtc"
 )
 
+// DefaultBusyTimeout is how long a statement waits for a database
+// locked by another connection (e.g. a running backup).
+const DefaultBusyTimeout = 5 * time.Second
+
+// Memory is the path of a private, in-memory database.
+const Memory = ":memory:"
+
+// Options of the database opened with Open (nil means defaults:
+// read-write access to the existing database).
+type Options struct {
+	ReadOnly    bool          // no writes, the database must exist
+	Create      bool          // create the empty database if it doesn't exist
+	BusyTimeout time.Duration // DefaultBusyTimeout if zero
+	Pragmas     *Pragmas      // DefaultPragmas if nil
+}
+
+// Database is safe for concurrent use by multiple goroutines.
+// Every call on the connection is serialized, and while a goroutine
+// runs a transaction (WithTx) the others wait until it ends.
+// Inside of a transaction only the given Tx may be used,
+// calls on the Database from the same goroutine would deadlock.
 type Database struct {
-	ptr   *C.sqlite3
-	stmt  *C.sqlite3_stmt
-	fpath string
+	*connection
+	inTx bool
 }
 
-var instance *Database
-var once sync.Once
+type connection struct {
+	ptr         *C.sqlite3
+	fpath       string
+	busyTimeout time.Duration
+	pragmas     *Pragmas
+	mu          sync.Mutex // serializes calls on ptr
+	txMu        sync.Mutex // held by the goroutine running a transaction
+	txDepth     int
+	notifier    notifier
+	tracer      tracer
+}
 
-func SQLite() *Database {
-	once.Do(func() {
-		instance = &Database{}
-	})
-	return instance
+func New() *Database {
+	return &Database{connection: &connection{busyTimeout: DefaultBusyTimeout, pragmas: DefaultPragmas()}}
+}
+
+// Open returns a new, independent handle of the database at filePath
+// (or Memory). Every handle has its own connection.
+func Open(filePath string, opts *Options) (*Database, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	db := New()
+	if opts.BusyTimeout != 0 {
+		db.busyTimeout = opts.BusyTimeout
+	}
+	if opts.Pragmas != nil {
+		db.pragmas = opts.Pragmas
+	}
+
+	var flags C.int
+	switch {
+	case opts.ReadOnly:
+		flags = C.SQLITE_OPEN_READONLY
+	case opts.Create || filePath == Memory:
+		flags = C.SQLITE_OPEN_READWRITE | C.SQLITE_OPEN_CREATE
+	default:
+		flags = C.SQLITE_OPEN_READWRITE
+	}
+	if filePath != Memory && flags&C.SQLITE_OPEN_CREATE == 0 && !databaseExists(filePath) {
+		return nil, ErrNotExists
+	}
+
+	if err := db.open(filePath, flags); err != nil {
+		return nil, err
+	}
+	return db, nil
 }
 
 func (db *Database) Version() string {
@@ -69,113 +124,142 @@ func (db *Database) Version() string {
 }
 
 func (db *Database) ErrorCode() int {
+	db.lock()
+	defer db.unlock()
 	return int(C.sqlite3_errcode(db.ptr))
 }
 
 func (db *Database) ErrorString() string {
+	db.lock()
+	defer db.unlock()
 	return C.GoString(C.sqlite3_errmsg(db.ptr))
 }
 
-func (db *Database) Open(filePath string) bool {
-	if db.ptr != nil {
-		log.Println("database is already opened")
-		return false
-	}
+// SetBusyTimeout sets how long statements wait for a locked database
+// before they fail with SQLITE_BUSY (zero or negative turns waiting off).
+// The timeout is applied to the open connection and to the connections
+// opened later.
+func (db *Database) SetBusyTimeout(timeout time.Duration) error {
+	db.lock()
+	defer db.unlock()
 
-	if !databaseExists(filePath) {
-		return false
+	db.busyTimeout = timeout
+	if db.ptr == nil {
+		return nil
 	}
-
-	cstr := C.CString(filePath)
-	defer C.free(unsafe.Pointer(cstr))
-
-	C.sqlite3_initialize()
-	if C.sqlite3_open_v2(cstr, &db.ptr, C.SQLITE_OPEN_READWRITE, nil) == C.SQLITE_OK {
-		db.fpath = filePath
-		return true
+	if retv := C.sqlite3_busy_timeout(db.ptr, C.int(timeout.Milliseconds())); retv != C.SQLITE_OK {
+		return db.error(retv)
 	}
-	db.checkError()
-	return false
+	return nil
 }
 
-func (db *Database) Create(filePath, scheme string) bool {
+func (db *Database) Open(filePath string) error {
 	if db.ptr != nil {
-		log.Println("database is already opened")
-		return false
+		return ErrOpened
 	}
+	if !databaseExists(filePath) {
+		return ErrNotExists
+	}
+	return db.open(filePath, C.SQLITE_OPEN_READWRITE)
+}
 
+func (db *Database) Create(filePath, scheme string) error {
+	if db.ptr != nil {
+		return ErrOpened
+	}
 	if databaseExists(filePath) {
-		log.Println("database already exists")
-		return false
+		return ErrExists
 	}
 
-	cstr := C.CString(filePath)
-	defer C.free(unsafe.Pointer(cstr))
-
-	C.sqlite3_initialize()
-	if C.sqlite3_open_v2(cstr, &db.ptr, C.SQLITE_OPEN_READWRITE|C.SQLITE_OPEN_CREATE, nil) == C.SQLITE_OK {
-		if db.ExecQuery(scheme) {
-			db.fpath = filePath
-			return true
-		}
+	if err := db.open(filePath, C.SQLITE_OPEN_READWRITE|C.SQLITE_OPEN_CREATE); err != nil {
+		return err
+	}
+	if err := db.ExecQuery(scheme); err != nil {
+		db.Close()
+		os.Remove(filePath)
+		return err
 	}
-	db.checkError()
-	return false
+	return nil
 }
 
-func (db *Database) Close() {
+func (db *Database) Close() error {
+	db.lock()
+	defer db.unlock()
+
 	if db.ptr == nil {
-		return
+		return nil
 	}
-	if retv := C.sqlite3_close(db.ptr); retv == C.SQLITE_OK {
-		C.sqlite3_shutdown()
-		db.ptr = nil
+	// no sqlite3_shutdown() here, other connections (e.g. backups) may be open
+	if retv := C.sqlite3_close(db.ptr); retv != C.SQLITE_OK {
+		return db.error(retv)
 	}
+	db.ptr = nil
+	db.notifier.uninstall()
+	db.tracer.uninstall()
+	return nil
 }
 
-func (db *Database) Remove() bool {
-	if err := os.Remove(db.fpath); tr.IsOK(err) {
-		return true
+// Remove removes the database file (with WAL files, if any).
+func (db *Database) Remove() error {
+	if db.fpath == Memory {
+		return nil
 	}
-	return false
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(db.fpath + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Remove(db.fpath)
 }
 
-func (db *Database) ExecQuery(query string) bool {
-	cstr := C.CString(query)
-	defer C.free(unsafe.Pointer(cstr))
-
-	if C.sqlite3_exec(db.ptr, cstr, nil, nil, nil) == C.SQLITE_OK {
-		return true
-	}
-	db.checkError()
-	return false
+func (db *Database) ExecQuery(query string) error {
+	_, err := db.execScript(query, nil)
+	return err
 }
 
 func (db *Database) LastInsertedRowID() int64 {
+	db.lock()
+	defer db.unlock()
 	return int64(int(C.sqlite3_last_insert_rowid(db.ptr)))
 }
 
-func (db *Database) BeginTransaction() bool {
+func (db *Database) BeginTransaction() error {
 	return db.ExecQuery("BEGIN IMMEDIATE TRANSACTION")
 }
 
-func (db *Database) CommitTransaction() bool {
+func (db *Database) CommitTransaction() error {
 	return db.ExecQuery("COMMIT TRANSACTION")
 }
 
-func (db *Database) RollbackTransaction() bool {
+func (db *Database) RollbackTransaction() error {
 	return db.ExecQuery("ROLLBACK TRANSACTION")
 }
 
-func (db *Database) FinishTransaction(success bool) bool {
+func (db *Database) FinishTransaction(success bool) error {
 	if success {
 		return db.CommitTransaction()
 	}
 	return db.RollbackTransaction()
 }
 
-func (db *Database) DefaultPragmas() bool {
-	return db.ExecQuery("PRAGMA foreign_keys=ON")
+// UserVersion returns value of 'PRAGMA user_version'.
+func (db *Database) UserVersion() (int, error) {
+	result, err := db.Select("PRAGMA user_version")
+	if err != nil {
+		return -1, err
+	}
+	if len(result) == 1 {
+		if f := result[0].Field("user_version"); f != nil {
+			if n, err := f.Int64(); err == nil {
+				return int(n), nil
+			}
+		}
+	}
+	return -1, fmt.Errorf("can't read user_version")
+}
+
+func (db *Database) SetUserVersion(version int) error {
+	return db.ExecQuery(fmt.Sprintf("PRAGMA user_version=%d", version))
 }
 
 /********************************************************************
@@ -185,22 +269,112 @@ func (db *Database) DefaultPragmas() bool {
 ********************************************************************/
 
 func databaseExists(filePath string) bool {
-	f, err := os.Open(filePath)
+	return CheckHeader(filePath) == nil
+}
+
+// execScript runs all statements of the query and returns number
+// of rows changed by the last one. Not nil interrupt stops the script
+// when it is set (see execScriptContext).
+func (db *Database) execScript(query string, interrupt *C.int) (int64, error) {
+	cstr := C.CString(query)
+	defer C.free(unsafe.Pointer(cstr))
+
+	db.lock()
+	defer db.unlock()
+	if interrupt != nil {
+		setProgressHandler(db.ptr, interrupt)
+		defer setProgressHandler(db.ptr, nil)
+	}
+	retv := C.sqlite3_exec(db.ptr, cstr, nil, nil, nil)
+	db.notifier.statementDone(retv == C.SQLITE_OK)
+	if retv != C.SQLITE_OK {
+		return 0, db.error(retv)
+	}
+	return int64(C.sqlite3_changes(db.ptr)), nil
+}
+
+// lock serializes access to the connection. Outside of a transaction
+// it also waits until a transaction run by other goroutine ends.
+func (db *Database) lock() {
+	if !db.inTx {
+		db.txMu.Lock()
+	}
+	db.mu.Lock()
+}
+
+func (db *Database) unlock() {
+	db.mu.Unlock()
+	if !db.inTx {
+		db.txMu.Unlock()
+	}
+}
+
+// open connects to the database and applies the pragma profile.
+func (db *Database) open(filePath string, flags C.int) error {
+	if err := db.connect(filePath, flags); err != nil {
+		return err
+	}
+	if err := db.applyPragmas(db.pragmas, flags&C.SQLITE_OPEN_READONLY != 0); err != nil {
+		db.Close()
+		return err
+	}
+	return nil
+}
+
+func (db *Database) connect(filePath string, flags C.int) error {
+	C.sqlite3_initialize()
+	if C.sqlite3_threadsafe() == 0 {
+		return ErrNotThreadSafe
+	}
+
+	db.lock()
+	defer db.unlock()
+
+	ptr, err := openConnection(filePath, flags, db.busyTimeout)
 	if err != nil {
-		return false
+		return err
 	}
-	defer f.Close()
+	db.ptr = ptr
+	db.fpath = filePath
+	db.notifier.install(ptr)
 
-	nbytes := len(vtc.DatabaseHeader)
-	data := make([]byte, nbytes)
-	if count, err := f.Read(data); tr.IsOK(err) && count == nbytes {
-		return subtle.ConstantTimeCompare(vtc.DatabaseHeader, data) == 1
+	if err := db.registerBuiltins(); err != nil {
+		C.sqlite3_close(ptr)
+		db.ptr = nil
+		db.notifier.uninstall()
+		return err
+	}
+	return nil
+}
+
+func openConnection(filePath string, flags C.int, busyTimeout time.Duration) (*C.sqlite3, error) {
+	cstr := C.CString(filePath)
+	defer C.free(unsafe.Pointer(cstr))
+
+	var ptr *C.sqlite3
+	if retv := C.sqlite3_open_v2(cstr, &ptr, flags|C.SQLITE_OPEN_FULLMUTEX, nil); retv != C.SQLITE_OK {
+		err := connectionError(ptr, retv)
+		// handle is allocated even if open failed
+		C.sqlite3_close(ptr)
+		return nil, err
 	}
+	C.sqlite3_busy_timeout(ptr, C.int(busyTimeout.Milliseconds()))
+	return ptr, nil
+}
 
-	return true
+// error returns description of the last failure on the connection
+// (retv is a result code returned by the failed call).
+func (db *Database) error(retv C.int) error {
+	return connectionError(db.ptr, retv)
 }
 
-func (db *Database) checkError() {
-	_, fn, line, _ := runtime.Caller(1)
-	fmt.Printf("sqlite error: %s (%d): %s (%d)\n", fn, line, db.ErrorString(), db.ErrorCode())
+func connectionError(ptr *C.sqlite3, retv C.int) error {
+	if ptr == nil {
+		return &Error{Code: int(retv) & 0xff, ExtendedCode: int(retv), Message: C.GoString(C.sqlite3_errstr(retv))}
+	}
+	return &Error{
+		Code:         int(retv) & 0xff,
+		ExtendedCode: int(C.sqlite3_extended_errcode(ptr)),
+		Message:      C.GoString(C.sqlite3_errmsg(ptr)),
+	}
 }
@@ -0,0 +1,424 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"Timelancer/sqlite/field"
+	"Timelancer/sqlite/row"
+	"github.com/stretchr/testify/assert"
+)
+
+const testScheme = `
+CREATE TABLE company
+(
+	id       INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	shortcut TEXT NOT NULL COLLATE NOCASE UNIQUE,
+	name     TEXT NOT NULL COLLATE NOCASE UNIQUE,
+	used     INTEGER NOT NULL CHECK(used==0 OR used==1) DEFAULT 1
+);
+CREATE TABLE timer
+(
+	id         INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	company_id INTEGER NOT NULL,
+	start      INTEGER NOT NULL,
+	finish     INTEGER NOT NULL,
+	FOREIGN KEY (company_id) REFERENCES company(id)
+)
+`
+
+func newTestDatabase(t *testing.T) *Database {
+	db := New()
+	if err := db.Create(filepath.Join(t.TempDir(), "test.sqlite"), testScheme); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, shortcut := range []string{"ACME", "BEE", "CTX"} {
+		fields := []*field.Field{
+			field.NewWithValue("shortcut", shortcut),
+			field.NewWithValue("name", shortcut+" company"),
+		}
+		id, err := db.Insert("company", fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := int64(1); i <= 2; i++ {
+			fields := []*field.Field{
+				field.NewWithValue("company_id", id),
+				field.NewWithValue("start", i*100),
+				field.NewWithValue("finish", i*100+50),
+			}
+			if _, err := db.Insert("timer", fields); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return db
+}
+
+func Test_NestedSelectAndHandle(t *testing.T) {
+	db := newTestDatabase(t)
+
+	counts := make(map[string]int)
+	err := db.SelectAndHandle("SELECT id, shortcut FROM company ORDER BY id", func(r row.Row) {
+		id, _ := r.Field("id").Int64()
+		shortcut, _ := r.Field("shortcut").Text()
+
+		query := fmt.Sprintf("SELECT id FROM timer WHERE company_id=%d", id)
+		assert.Nil(t, db.SelectAndHandle(query, func(row.Row) {
+			counts[shortcut]++
+		}))
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"ACME": 2, "BEE": 2, "CTX": 2}, counts)
+}
+
+func Test_InterleavedStatements(t *testing.T) {
+	db := newTestDatabase(t)
+
+	companies, err := db.Prepare("SELECT shortcut FROM company ORDER BY id")
+	assert.Nil(t, err)
+	defer companies.Close()
+
+	timers, err := db.Prepare("SELECT start FROM timer ORDER BY id")
+	assert.Nil(t, err)
+	defer timers.Close()
+
+	var shortcuts []string
+	var starts []int64
+	for {
+		ok, err := companies.Step()
+		assert.Nil(t, err)
+		if !ok {
+			break
+		}
+		shortcuts = append(shortcuts, companies.Text(0))
+		if ok, _ := timers.Step(); ok {
+			starts = append(starts, timers.Int(0))
+		}
+	}
+
+	assert.Equal(t, []string{"ACME", "BEE", "CTX"}, shortcuts)
+	assert.Equal(t, []int64{100, 200, 100}, starts)
+}
+
+func Test_StatementReset(t *testing.T) {
+	db := newTestDatabase(t)
+
+	stmt, err := db.Prepare("SELECT name FROM company WHERE shortcut=?")
+	assert.Nil(t, err)
+	defer stmt.Close()
+
+	for _, shortcut := range []string{"ACME", "CTX"} {
+		assert.Nil(t, stmt.Reset())
+		assert.Nil(t, stmt.Bind(1, shortcut))
+
+		ok, err := stmt.Step()
+		assert.True(t, ok)
+		assert.Nil(t, err)
+		assert.Equal(t, shortcut+" company", stmt.Text(0))
+
+		ok, err = stmt.Step()
+		assert.False(t, ok)
+		assert.Nil(t, err)
+	}
+}
+
+func Test_SelectWithArgs(t *testing.T) {
+	db := newTestDatabase(t)
+
+	var tests = []struct {
+		query string
+		args  []interface{}
+		want  int
+		ok    bool
+	}{
+		{"SELECT * FROM company WHERE shortcut=?", []interface{}{"BEE"}, 1, true},
+		{"SELECT * FROM company WHERE shortcut=? OR shortcut=?", []interface{}{"BEE", "ACME"}, 2, true},
+		{"SELECT * FROM company WHERE shortcut=?", []interface{}{"x' OR '1'='1"}, 0, true},
+		{"SELECT * FROM company WHERE shortcut=:shortcut", []interface{}{field.NewWithValue("shortcut", "CTX")}, 1, true},
+		{"SELECT * FROM timer WHERE start>@start AND company_id=?", []interface{}{int64(1), field.NewWithValue("start", 100)}, 1, true},
+		{"SELECT * FROM company WHERE shortcut=?", []interface{}{"BEE", "ACME"}, 0, false},
+		{"SELECT * FROM company WHERE shortcut=:shortcut", []interface{}{field.NewWithValue("name", "BEE")}, 0, false},
+	}
+
+	for _, test := range tests {
+		result, err := db.Select(test.query, test.args...)
+		assert.Equal(t, test.want, len(result), test.query)
+		assert.Equal(t, test.ok, err == nil, test.query)
+	}
+}
+
+func Test_BindArgTypes(t *testing.T) {
+	db := newTestDatabase(t)
+	type companyID int16
+	type shortcut string
+
+	var tests = []struct {
+		condition string
+		arg       interface{}
+		want      int64
+		ok        bool
+	}{
+		{"company_id=?", int(2), 2, true},
+		{"company_id=?", int8(2), 2, true},
+		{"company_id=?", int16(2), 2, true},
+		{"company_id=?", uint(2), 2, true},
+		{"company_id=?", uint8(2), 2, true},
+		{"company_id=?", uint16(2), 2, true},
+		{"company_id=?", uint64(2), 2, true},
+		{"company_id=?", companyID(2), 2, true},
+		{"start=?", time.Unix(100, 0), 3, true},
+		{"company_id=(SELECT id FROM company WHERE shortcut=?)", shortcut("BEE"), 2, true},
+		{"company_id=?", uint64(math.MaxUint64), -1, false},
+		{"company_id=?", struct{}{}, -1, false},
+		{"company_id=?", []int{2}, -1, false},
+		{"company_id=:id", (*field.Field)(nil), -1, false},
+	}
+
+	for _, test := range tests {
+		n, err := db.CountWhere("timer", test.condition, test.arg)
+		assert.Equal(t, test.ok, err == nil, "%T", test.arg)
+		assert.Equal(t, test.want, n, "%T", test.arg)
+		if _, unsupported := test.arg.(*field.Field); !test.ok && !unsupported {
+			assert.ErrorIs(t, err, field.ErrUnsupportedType)
+		}
+	}
+}
+
+func Test_CountAndDeleteWithArgs(t *testing.T) {
+	db := newTestDatabase(t)
+
+	count := func(n int64, err error) int64 {
+		assert.Nil(t, err)
+		return n
+	}
+
+	assert.Equal(t, int64(6), count(db.Count("timer")))
+	assert.Equal(t, int64(2), count(db.CountWhereInt("timer", "company_id", 2)))
+	assert.Equal(t, int64(3), count(db.CountWhere("timer", "start>=? AND finish<?", 200, 300)))
+
+	assert.Nil(t, db.Exec("DELETE FROM timer WHERE company_id=?", 2))
+	assert.Nil(t, db.Delete("timer", "id", 1))
+	assert.Equal(t, int64(3), count(db.Count("timer")))
+}
+
+func Test_Errors(t *testing.T) {
+	db := newTestDatabase(t)
+
+	_, err := db.Insert("company", []*field.Field{
+		field.NewWithValue("shortcut", "acme"),
+		field.NewWithValue("name", "Another Acme"),
+	})
+	assert.True(t, IsConstraint(err))
+	assert.True(t, IsUniqueViolation(err))
+	assert.False(t, IsBusy(err))
+	assert.Contains(t, err.Error(), "company.shortcut")
+
+	_, err = db.Insert("company", []*field.Field{
+		field.NewWithValue("shortcut", "NN"),
+		field.NewWithValue("name", nil),
+	})
+	assert.True(t, IsNotNullViolation(err))
+	assert.False(t, IsUniqueViolation(err))
+
+	_, err = db.Select("SELECT * FROM nothing")
+	assert.NotNil(t, err)
+	assert.False(t, IsConstraint(err))
+
+	assert.ErrorIs(t, db.Open("test.sqlite"), ErrOpened)
+	assert.ErrorIs(t, New().Open(filepath.Join(t.TempDir(), "none.sqlite")), ErrNotExists)
+}
+
+func Test_BackupAndRestore(t *testing.T) {
+	db := newTestDatabase(t)
+	backupPath := filepath.Join(t.TempDir(), "backup.sqlite")
+
+	assert.Nil(t, db.Backup(backupPath))
+
+	// backup is a complete, independent database
+	backup := New()
+	assert.Nil(t, backup.Open(backupPath))
+	n, err := backup.Count("timer")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), n)
+	assert.Nil(t, backup.Close())
+
+	assert.Nil(t, db.Exec("DELETE FROM timer"))
+	assert.Nil(t, db.Restore(backupPath))
+	n, err = db.Count("timer")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), n)
+
+	assert.ErrorIs(t, db.Restore(filepath.Join(t.TempDir(), "none.sqlite")), ErrNotExists)
+}
+
+func Test_BackupDuringTransactions(t *testing.T) {
+	db := newTestDatabase(t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1000; i < 1050; i++ {
+			db.WithTx(func(tx *Tx) error {
+				if err := tx.Exec("INSERT INTO timer (company_id, start, finish) VALUES (?, ?, ?)", 1, i, i+1); err != nil {
+					return err
+				}
+				return tx.Exec("DELETE FROM timer WHERE start=?", i)
+			})
+		}
+	}()
+
+	// every transaction leaves 6 timers, backup never copies the half of it
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		backupPath := filepath.Join(dir, fmt.Sprintf("backup-%d.sqlite", i))
+		assert.Nil(t, db.Backup(backupPath))
+		backup := New()
+		if assert.Nil(t, backup.Open(backupPath)) {
+			n, err := backup.Count("timer")
+			assert.Nil(t, err)
+			assert.Equal(t, int64(6), n)
+			assert.Nil(t, backup.Close())
+		}
+	}
+	<-done
+}
+
+func Test_OpenIndependentHandles(t *testing.T) {
+	first, err := Open(Memory, nil)
+	assert.Nil(t, err)
+	defer first.Close()
+	second, err := Open(Memory, nil)
+	assert.Nil(t, err)
+	defer second.Close()
+
+	// every in-memory handle is a separate database
+	assert.Nil(t, first.ExecQuery(testScheme))
+	n, err := first.Count("company")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+	_, err = second.Count("company")
+	assert.NotNil(t, err)
+
+	filePath := filepath.Join(t.TempDir(), "test.sqlite")
+	_, err = Open(filePath, nil)
+	assert.ErrorIs(t, err, ErrNotExists)
+	_, err = Open(filePath, &Options{ReadOnly: true})
+	assert.ErrorIs(t, err, ErrNotExists)
+
+	db, err := Open(filePath, &Options{Create: true})
+	assert.Nil(t, err)
+	assert.Nil(t, db.ExecQuery(testScheme))
+	defer db.Close()
+
+	ro, err := Open(filePath, &Options{ReadOnly: true})
+	assert.Nil(t, err)
+	defer ro.Close()
+	n, err = ro.Count("timer")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+	err = ro.Exec("INSERT INTO company (shortcut, name) VALUES (?, ?)", "ACME", "Acme")
+	assert.True(t, IsReadOnly(err))
+}
+
+func Test_RowWithDuplicateColumns(t *testing.T) {
+	db := newTestDatabase(t)
+
+	query := "SELECT timer.id, company.id, company.shortcut, timer.finish-timer.start AS duration FROM timer, company WHERE timer.company_id=company.id AND company.shortcut=? ORDER BY timer.id"
+	result, err := db.Select(query, "BEE")
+	assert.Nil(t, err)
+	if assert.Len(t, result, 2) {
+		r := result[1]
+		assert.Equal(t, []string{"id", "id", "shortcut", "duration"}, r.Names())
+		assert.Equal(t, int64(4), r.At(0).Value)
+		assert.Equal(t, int64(2), r.At(1).Value)
+		assert.Nil(t, r.At(4))
+
+		assert.Equal(t, int64(4), r.Field("id").Value)
+		assert.Equal(t, int64(4), r.Field("timer.id").Value)
+		assert.Equal(t, int64(2), r.Field("company.id").Value)
+		assert.Equal(t, "company", r.Field("shortcut").Table)
+		assert.Equal(t, int64(50), r.Field("duration").Value)
+		assert.Equal(t, "", r.Field("duration").Table)
+		assert.Nil(t, r.Field("timer.shortcut"))
+	}
+}
+
+func Test_UpdateAndUpsert(t *testing.T) {
+	db := newTestDatabase(t)
+
+	// composite key
+	fields := []*field.Field{
+		field.NewWithValue("company_id", 2),
+		field.NewWithValue("start", 100),
+		field.NewWithValue("finish", 175),
+	}
+	n, err := db.Update("timer", []string{"company_id", "start"}, fields)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = db.CountWhere("timer", "finish=?", 175)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+
+	_, err = db.Update("timer", []string{"company_id"}, fields[:1])
+	assert.ErrorIs(t, err, ErrNoFields)
+	_, err = db.Update("timer", nil, fields)
+	assert.ErrorIs(t, err, ErrNoKeys)
+	_, err = db.Update("timer", []string{"id"}, fields)
+	assert.NotNil(t, err)
+
+	// natural key, shortcut is unique (case insensitive)
+	upsert := func(shortcut, name string) int64 {
+		n, err := db.Upsert("company", []string{"shortcut"}, []*field.Field{
+			field.NewWithValue("shortcut", shortcut),
+			field.NewWithValue("name", name),
+		})
+		assert.Nil(t, err)
+		return n
+	}
+	assert.Equal(t, int64(1), upsert("DOT", "Dot"))
+	assert.Equal(t, int64(1), upsert("dot", "Dot company"))
+	assert.Equal(t, int64(1), upsert("ACME", "Acme"))
+
+	result, err := db.Select("SELECT shortcut, name FROM company ORDER BY id")
+	assert.Nil(t, err)
+	var names []string
+	for _, r := range result {
+		name, _ := r.Field("name").Text()
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"Acme", "BEE company", "CTX company", "Dot company"}, names)
+}
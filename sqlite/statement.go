@@ -38,52 +38,230 @@ int bind_text(sqlite3_stmt *stmt, int index, const char* txt) {
 	return sqlite3_bind_text(stmt, index, txt, -1, SQLITE_TRANSIENT);
 }
 
+int bind_blob(sqlite3_stmt *stmt, int index, const void* data, int n) {
+	return sqlite3_bind_blob(stmt, index, data, n, SQLITE_TRANSIENT);
+}
+
 const char* column_text(sqlite3_stmt *stmt, int index) {
 	return (const char *)sqlite3_column_text(stmt, index);
 }
+
+// sqlite3_column_table_name exists only in libraries compiled
+// with SQLITE_ENABLE_COLUMN_METADATA
+#pragma weak sqlite3_column_table_name
+
+const char* column_table(sqlite3_stmt *stmt, int index) {
+	if (sqlite3_column_table_name) {
+		return sqlite3_column_table_name(stmt, index);
+	}
+	return NULL;
+}
 */
 import "C"
 import (
+	"fmt"
+	"strings"
 	"unsafe"
 
-	"Timelancer/shared/tr"
 	"Timelancer/sqlite/field"
 	"Timelancer/sqlite/row"
 	"Timelancer/sqlite/vtc"
 )
 
-func (db *Database) step() int {
-	return int(C.sqlite3_step(db.stmt))
+// Statement is a prepared statement owned by the caller.
+// Every statement has its own sqlite3_stmt, so any number of them
+// can be alive (and stepped) at the same time on one database.
+// A statement itself must not be used by many goroutines at once.
+type Statement struct {
+	db        *Database
+	ptr       *C.sqlite3_stmt
+	interrupt *C.int // set when the statement has to be stopped (see interruptOn)
 }
 
-func (db *Database) reset() int {
-	if retv := C.sqlite3_reset(db.stmt); retv == C.SQLITE_OK {
-		return int(C.sqlite3_clear_bindings(db.stmt))
-	} else {
-		return int(retv)
+func (db *Database) Prepare(query string) (*Statement, error) {
+	cstr := C.CString(query)
+	defer C.free(unsafe.Pointer(cstr))
+
+	db.lock()
+	defer db.unlock()
+
+	stmt := &Statement{db: db}
+	if retv := C.sqlite3_prepare_v2(db.ptr, cstr, -1, &stmt.ptr, nil); retv != C.SQLITE_OK {
+		return nil, db.error(retv)
 	}
+	if stmt.ptr == nil {
+		// only white spaces or comments
+		return nil, ErrEmptyQuery
+	}
+	return stmt, nil
 }
 
-func (db *Database) prepare(query string) bool {
-	cstr := C.CString(query)
-	defer C.free(unsafe.Pointer(cstr))
+func (s *Statement) Close() error {
+	if s.ptr == nil {
+		return nil
+	}
+	s.db.lock()
+	defer s.db.unlock()
+
+	retv := C.sqlite3_finalize(s.ptr)
+	s.ptr = nil
+	s.db.notifier.statementDone(retv == C.SQLITE_OK)
+	if retv != C.SQLITE_OK {
+		return s.db.error(retv)
+	}
+	return nil
+}
+
+// Step advances to the next row of the result.
+// Returns false (and nil error) when there are no more rows.
+func (s *Statement) Step() (bool, error) {
+	s.db.lock()
+	defer s.db.unlock()
+
+	if s.interrupt != nil {
+		// progress handler belongs to the connection, so it's set only for this step
+		setProgressHandler(s.db.ptr, s.interrupt)
+		defer setProgressHandler(s.db.ptr, nil)
+	}
+
+	retv := C.sqlite3_step(s.ptr)
+	s.db.notifier.statementDone(retv == C.SQLITE_ROW || retv == C.SQLITE_DONE)
+	switch retv {
+	case C.SQLITE_ROW:
+		return true, nil
+	case C.SQLITE_DONE:
+		return false, nil
+	default:
+		return false, s.db.error(retv)
+	}
+}
+
+func (s *Statement) Reset() error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	retv := C.sqlite3_reset(s.ptr)
+	s.db.notifier.statementDone(retv == C.SQLITE_OK)
+	if retv != C.SQLITE_OK {
+		return s.db.error(retv)
+	}
+	if retv := C.sqlite3_clear_bindings(s.ptr); retv != C.SQLITE_OK {
+		return s.db.error(retv)
+	}
+	return nil
+}
+
+// exec executes the statement and returns number of rows it changed.
+func (s *Statement) exec() (int64, error) {
+	_, changes, err := s.execResult()
+	return changes, err
+}
+
+// insert executes the statement and returns rowid of the inserted row.
+func (s *Statement) insert() (int64, error) {
+	rowID, _, err := s.execResult()
+	return rowID, err
+}
+
+// execResult executes the statement and returns the last inserted rowid
+// and number of changed rows, no other call on the connection can change
+// them in between.
+func (s *Statement) execResult() (int64, int64, error) {
+	s.db.lock()
+	defer s.db.unlock()
+
+	if s.interrupt != nil {
+		setProgressHandler(s.db.ptr, s.interrupt)
+		defer setProgressHandler(s.db.ptr, nil)
+	}
+
+	retv := C.sqlite3_step(s.ptr)
+	s.db.notifier.statementDone(retv == C.SQLITE_DONE || retv == C.SQLITE_ROW)
+	if retv != C.SQLITE_DONE && retv != C.SQLITE_ROW {
+		return 0, 0, s.db.error(retv)
+	}
+	return int64(C.sqlite3_last_insert_rowid(s.db.ptr)), int64(C.sqlite3_changes(s.db.ptr)), nil
+}
+
+func (s *Statement) Bind(index int, value interface{}) error {
+	f := &field.Field{}
+	if err := f.Set(value); err != nil {
+		return err
+	}
+	return s.bindField(index, f)
+}
+
+func (s *Statement) BindFields(fields []*field.Field) error {
+	for _, f := range fields {
+		if err := s.bindField(s.parameterIndex(":"+f.Name), f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BindArgs binds query arguments. A *field.Field is bound by name
+// (":name", "@name" or "$name" in the query), any other value is
+// bound by position, in the order the positional arguments were passed.
+// Fails with field.ErrUnsupportedType for values which can't be bound
+// (see field.Set), they are never bound as NULL silently.
+func (s *Statement) BindArgs(args ...interface{}) error {
+	positions := s.positionalParameters()
+	position := 0
+	for i, arg := range args {
+		var index int
+		var f *field.Field
+
+		if named, ok := arg.(*field.Field); ok {
+			if named == nil {
+				return fmt.Errorf("query argument %d is a nil field", i+1)
+			}
+			if index = s.namedParameterIndex(named.Name); index == 0 {
+				return fmt.Errorf("there is no parameter named %s in query", named.Name)
+			}
+			f = named
+		} else {
+			if position >= len(positions) {
+				return fmt.Errorf("too many query arguments (%d)", position+1)
+			}
+			index = positions[position]
+			position++
+			f = &field.Field{}
+			if err := f.Set(arg); err != nil {
+				return fmt.Errorf("query argument %d: %w", i+1, err)
+			}
+		}
 
-	if retv := C.sqlite3_prepare_v2(db.ptr, cstr, -1, &db.stmt, nil); retv == C.SQLITE_OK {
-		return true
+		if err := s.bindField(index, f); err != nil {
+			return err
+		}
 	}
-	db.checkError()
-	return false
+	return nil
+}
+
+func (s *Statement) ParameterCount() int {
+	return int(C.sqlite3_bind_parameter_count(s.ptr))
+}
+
+func (s *Statement) ColumnCount() int {
+	return int(C.sqlite3_column_count(s.ptr))
 }
 
-func (db *Database) finalize() {
-	if retv := C.sqlite3_finalize(db.stmt); retv == C.SQLITE_OK {
-		return
+func (s *Statement) ColumnName(index int) string {
+	return C.GoString(C.sqlite3_column_name(s.ptr, C.int(index)))
+}
+
+// ColumnTable returns name of the table the column comes from,
+// empty for expressions or if the library doesn't provide it.
+func (s *Statement) ColumnTable(index int) string {
+	if name := C.column_table(s.ptr, C.int(index)); name != nil {
+		return C.GoString(name)
 	}
-	db.checkError()
+	return ""
 }
 
-func (db *Database) columnType(idx int) vtc.ValueType {
-	ct := C.sqlite3_column_type(db.stmt, C.int(idx))
+func (s *Statement) ColumnType(index int) vtc.ValueType {
+	ct := C.sqlite3_column_type(s.ptr, C.int(index))
 	switch ct {
 	case C.SQLITE_INTEGER:
 		return vtc.Int
@@ -97,128 +275,137 @@ func (db *Database) columnType(idx int) vtc.ValueType {
 	return vtc.Null
 }
 
-func (db *Database) columnCount() int {
-	return int(C.sqlite3_column_count(db.stmt))
-}
-
-func (db *Database) columnIndex(columnName string) int {
-	cstr := C.CString(columnName)
-	defer C.free(unsafe.Pointer(cstr))
-	return int(C.sqlite3_bind_parameter_index(db.stmt, cstr))
-}
-
-func (db *Database) columnName(cindex int) string {
-	return C.GoString(C.sqlite3_column_name(db.stmt, C.int(cindex)))
-}
+// Row returns values of all columns of the current row in the order
+// of the select list (valid only after Step returned true).
+func (s *Statement) Row() row.Row {
+	n := s.ColumnCount()
+	if n == 0 {
+		return nil
+	}
 
-func (db *Database) bindFields(fields []*field.Field) {
-	for _, f := range fields {
-		columnIndex := db.columnIndex(":" + f.Name)
-		switch f.ValueType {
+	oneRow := row.New()
+	for i := 0; i < n; i++ {
+		f := field.New(s.ColumnName(i))
+		f.Table = s.ColumnTable(i)
+		switch s.ColumnType(i) {
 		case vtc.Null:
-			db.bindNull(columnIndex)
+			f.SetValue(nil)
 		case vtc.Int:
-			if value, err := f.Int64(); tr.IsOK(err) {
-				db.bindInt(columnIndex, value)
-			}
+			f.SetValue(s.Int(i))
 		case vtc.Float:
-			if value, err := f.Float64(); tr.IsOK(err) {
-				db.bindFloat(columnIndex, value)
-			}
+			f.SetValue(s.Float(i))
 		case vtc.Text:
-			if value, err := f.Text(); tr.IsOK(err) {
-				db.bindText(columnIndex, value)
-			}
+			f.SetValue(s.Text(i))
 		case vtc.Blob:
-			if value, err := f.Blob(); tr.IsOK(err) {
-				db.bindBlob(columnIndex, value)
-			}
+			f.SetValue(s.Blob(i))
 		}
+		oneRow.Append(f)
 	}
+	return oneRow
 }
 
-func (db *Database) fetchResult() row.Result {
-	var result row.Result
-
-	n := db.columnCount()
-	if n > 0 {
-		for db.step() == vtc.StatusRow {
-			oneRow := row.New()
-			for i := 0; i < n; i++ {
-				f := field.New(db.columnName(i))
-				switch db.columnType(i) {
-				case vtc.Null:
-					f.SetValue(nil)
-				case vtc.Int:
-					f.SetValue(db.fetchInt(i))
-				case vtc.Float:
-					f.SetValue(db.fetchFloat(i))
-				case vtc.Text:
-					f.SetValue(db.fetchText(i))
-				case vtc.Blob:
-					f.SetValue(db.fetchBlob(i))
-				}
-				oneRow.Append(f)
-			}
-			result = append(result, oneRow)
+/********************************************************************
+*                                                                   *
+*                          S E T T E R S                            *
+*                                                                   *
+********************************************************************/
+
+func (s *Statement) parameterIndex(name string) int {
+	cstr := C.CString(name)
+	defer C.free(unsafe.Pointer(cstr))
+	return int(C.sqlite3_bind_parameter_index(s.ptr, cstr))
+}
+
+// positionalParameters returns indexes of nameless ("?" and "?NNN") parameters.
+func (s *Statement) positionalParameters() []int {
+	var indexes []int
+	for i := 1; i <= s.ParameterCount(); i++ {
+		if name := C.sqlite3_bind_parameter_name(s.ptr, C.int(i)); name == nil || C.GoString(name)[0] == '?' {
+			indexes = append(indexes, i)
 		}
 	}
+	return indexes
+}
 
-	return result
-}
-
-func (db *Database) fetchAndHandle(handler func(row.Row)) int {
-	if n := db.columnCount(); n > 0 {
-		if db.step() == vtc.StatusRow {
-			oneRow := row.New()
-			for i := 0; i < n; i++ {
-				f := field.New(db.columnName(i))
-				switch db.columnType(i) {
-				case vtc.Null:
-					f.SetValue(nil)
-				case vtc.Int:
-					f.SetValue(db.fetchInt(i))
-				case vtc.Float:
-					f.SetValue(db.fetchFloat(i))
-				case vtc.Text:
-					f.SetValue(db.fetchText(i))
-				case vtc.Blob:
-					f.SetValue(db.fetchBlob(i))
-				}
-				oneRow.Append(f)
-			}
-			handler(oneRow)
+func (s *Statement) namedParameterIndex(name string) int {
+	if name == "" {
+		return 0
+	}
+	if strings.ContainsAny(name[:1], ":@$") {
+		return s.parameterIndex(name)
+	}
+	for _, prefix := range []string{":", "@", "$"} {
+		if index := s.parameterIndex(prefix + name); index != 0 {
+			return index
 		}
 	}
-	return db.ErrorCode()
+	return 0
 }
 
-/********************************************************************
-*                                                                   *
-*                          S E T T E R S                            *
-*                                                                   *
-********************************************************************/
+func (s *Statement) bindField(index int, f *field.Field) error {
+	s.db.lock()
+	defer s.db.unlock()
+
+	var retv C.int
+
+	switch f.ValueType {
+	case vtc.Int, vtc.Bool:
+		value, err := f.Int64()
+		if err != nil {
+			return err
+		}
+		retv = s.bindInt(index, value)
+	case vtc.Float:
+		value, err := f.Float64()
+		if err != nil {
+			return err
+		}
+		retv = s.bindFloat(index, value)
+	case vtc.Text:
+		value, err := f.Text()
+		if err != nil {
+			return err
+		}
+		retv = s.bindText(index, value)
+	case vtc.Blob:
+		value, err := f.Blob()
+		if err != nil {
+			return err
+		}
+		retv = s.bindBlob(index, value)
+	default:
+		retv = s.bindNull(index)
+	}
+
+	if retv != C.SQLITE_OK {
+		return s.db.error(retv)
+	}
+	return nil
+}
 
-func (db *Database) bindNull(colIndex int) int {
-	return int(C.sqlite3_bind_null(db.stmt, C.int(colIndex)))
+func (s *Statement) bindNull(index int) C.int {
+	return C.sqlite3_bind_null(s.ptr, C.int(index))
 }
 
-func (db *Database) bindInt(colIndex int, v int64) int {
-	return int(C.sqlite3_bind_int64(db.stmt, C.int(colIndex), C.sqlite3_int64(int64(v))))
+func (s *Statement) bindInt(index int, v int64) C.int {
+	return C.sqlite3_bind_int64(s.ptr, C.int(index), C.sqlite3_int64(v))
 }
 
-func (db *Database) bindFloat(colIndex int, v float64) int {
-	return int(C.sqlite3_bind_double(db.stmt, C.int(colIndex), C.double(v)))
+func (s *Statement) bindFloat(index int, v float64) C.int {
+	return C.sqlite3_bind_double(s.ptr, C.int(index), C.double(v))
 }
 
-func (db *Database) bindText(colIndex int, v string) int {
+func (s *Statement) bindText(index int, v string) C.int {
 	cstr := C.CString(v)
 	defer C.free(unsafe.Pointer(cstr))
-	return int(C.bind_text(db.stmt, C.int(colIndex), cstr))
+	return C.bind_text(s.ptr, C.int(index), cstr)
 }
 
-func (db *Database) bindBlob(colIndex int, v []byte) int {
-	return int(C.sqlite3_bind_blob(db.stmt, C.int(colIndex), unsafe.Pointer(&v[0]), C.int(len(v)), nil))
+func (s *Statement) bindBlob(index int, v []byte) C.int {
+	if len(v) == 0 {
+		return C.sqlite3_bind_zeroblob(s.ptr, C.int(index), 0)
+	}
+	return C.bind_blob(s.ptr, C.int(index), unsafe.Pointer(&v[0]), C.int(len(v)))
 }
 
 /********************************************************************
@@ -227,20 +414,20 @@ func (db *Database) bindBlob(colIndex int, v []byte) int {
 *                                                                   *
 ********************************************************************/
 
-func (db *Database) fetchInt(colIndex int) int64 {
-	return int64(C.sqlite3_column_int64(db.stmt, C.int(colIndex)))
+func (s *Statement) Int(index int) int64 {
+	return int64(C.sqlite3_column_int64(s.ptr, C.int(index)))
 }
 
-func (db *Database) fetchFloat(colIndex int) float64 {
-	return float64(C.sqlite3_column_double(db.stmt, C.int(colIndex)))
+func (s *Statement) Float(index int) float64 {
+	return float64(C.sqlite3_column_double(s.ptr, C.int(index)))
 }
 
-func (db *Database) fetchText(colIndex int) string {
-	return C.GoString(C.column_text(db.stmt, C.int(colIndex)))
+func (s *Statement) Text(index int) string {
+	return C.GoString(C.column_text(s.ptr, C.int(index)))
 }
 
-func (db *Database) fetchBlob(colIndex int) []byte {
-	n := C.int(C.sqlite3_column_bytes(db.stmt, C.int(colIndex)))
-	ptr := C.sqlite3_column_blob(db.stmt, C.int(colIndex))
+func (s *Statement) Blob(index int) []byte {
+	n := C.int(C.sqlite3_column_bytes(s.ptr, C.int(index)))
+	ptr := C.sqlite3_column_blob(s.ptr, C.int(index))
 	return C.GoBytes(ptr, n)
 }
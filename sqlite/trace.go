@@ -0,0 +1,144 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+/*
+#include <stdint.h>
+#include <sqlite3.h>
+
+#cgo LDFLAGS: -lsqlite3
+
+extern int goTraceCallback(unsigned int, void*, void*, void*);
+
+static int set_trace(sqlite3 *db, unsigned int mask, uintptr_t handle) {
+	return sqlite3_trace_v2(db, mask, mask ? goTraceCallback : NULL, (void *)handle);
+}
+*/
+import "C"
+import (
+	"runtime/cgo"
+	"time"
+	"unsafe"
+)
+
+// TraceEvent describes a finished statement.
+type TraceEvent struct {
+	SQL      string // with values of bound parameters
+	Duration time.Duration
+	Rows     int64 // number of returned rows
+	Slow     bool  // Duration reached the threshold
+}
+
+// tracer receives trace events of the connection. It's used only
+// in the callback, which runs under the connection lock.
+type tracer struct {
+	handle    cgo.Handle
+	threshold time.Duration
+	fn        func(TraceEvent)
+	rows      map[*C.sqlite3_stmt]int64
+}
+
+// SetTrace calls fn after every statement run on the connection, statements
+// running threshold or longer are marked as slow (zero threshold marks none).
+// fn is called inside of the statement, so it must not use the database.
+// nil fn turns tracing off.
+func (db *Database) SetTrace(threshold time.Duration, fn func(TraceEvent)) error {
+	db.lock()
+	defer db.unlock()
+
+	if db.ptr == nil {
+		return ErrNotOpened
+	}
+	if fn == nil {
+		if retv := C.set_trace(db.ptr, 0, 0); retv != C.SQLITE_OK {
+			return db.error(retv)
+		}
+		db.tracer.uninstall()
+		return nil
+	}
+
+	db.tracer.threshold = threshold
+	db.tracer.fn = fn
+	db.tracer.rows = make(map[*C.sqlite3_stmt]int64)
+	if db.tracer.handle == 0 {
+		db.tracer.handle = cgo.NewHandle(&db.tracer)
+	}
+	mask := C.uint(C.SQLITE_TRACE_STMT | C.SQLITE_TRACE_ROW | C.SQLITE_TRACE_PROFILE)
+	if retv := C.set_trace(db.ptr, mask, C.uintptr_t(db.tracer.handle)); retv != C.SQLITE_OK {
+		db.tracer.uninstall()
+		return db.error(retv)
+	}
+	return nil
+}
+
+/********************************************************************
+*                                                                   *
+*                         P R I V A T E                             *
+*                                                                   *
+********************************************************************/
+
+func (t *tracer) uninstall() {
+	if t.handle != 0 {
+		t.handle.Delete()
+		t.handle = 0
+	}
+	t.fn = nil
+	t.rows = nil
+}
+
+func (t *tracer) started(stmt *C.sqlite3_stmt) {
+	t.rows[stmt] = 0
+}
+
+func (t *tracer) row(stmt *C.sqlite3_stmt) {
+	t.rows[stmt]++
+}
+
+func (t *tracer) finished(stmt *C.sqlite3_stmt, duration time.Duration) {
+	rows := t.rows[stmt]
+	delete(t.rows, stmt)
+	if t.fn == nil {
+		return
+	}
+	t.fn(TraceEvent{
+		SQL:      expandedSQL(stmt),
+		Duration: duration,
+		Rows:     rows,
+		Slow:     t.threshold > 0 && duration >= t.threshold,
+	})
+}
+
+func expandedSQL(stmt *C.sqlite3_stmt) string {
+	// NULL if there is no memory or the text is too long
+	if cstr := C.sqlite3_expanded_sql(stmt); cstr != nil {
+		defer C.sqlite3_free(unsafe.Pointer(cstr))
+		return C.GoString(cstr)
+	}
+	return C.GoString(C.sqlite3_sql(stmt))
+}
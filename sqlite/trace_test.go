@@ -0,0 +1,73 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Trace(t *testing.T) {
+	db := newTestDatabase(t)
+
+	var events []TraceEvent
+	assert.Nil(t, db.SetTrace(0, func(e TraceEvent) { events = append(events, e) }))
+
+	_, err := db.Select("SELECT shortcut FROM company WHERE id<=? ORDER BY id", 2)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Exec("UPDATE company SET name=? WHERE id=?", "Acme", 1))
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "SELECT shortcut FROM company WHERE id<=2 ORDER BY id", events[0].SQL)
+		assert.Equal(t, int64(2), events[0].Rows)
+		assert.False(t, events[0].Slow)
+		assert.Equal(t, "UPDATE company SET name='Acme' WHERE id=1", events[1].SQL)
+		assert.Equal(t, int64(0), events[1].Rows)
+	}
+
+	// SQLite measures time in milliseconds, the query takes a few of them
+	events = nil
+	assert.Nil(t, db.SetTrace(time.Nanosecond, func(e TraceEvent) { events = append(events, e) }))
+	_, err = db.Select(`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i+1 FROM n WHERE i < 200000)
+		SELECT count(*) FROM n`)
+	assert.Nil(t, err)
+	if assert.Len(t, events, 1) {
+		assert.True(t, events[0].Slow)
+		assert.Equal(t, int64(1), events[0].Rows)
+	}
+
+	events = nil
+	assert.Nil(t, db.SetTrace(0, nil))
+	_, err = db.Count("company")
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+
+	assert.ErrorIs(t, New().SetTrace(0, nil), ErrNotOpened)
+}
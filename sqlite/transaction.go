@@ -0,0 +1,93 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"fmt"
+)
+
+// Tx is the database seen from inside of WithTx.
+type Tx struct {
+	*Database
+}
+
+// WithTx runs fn in a transaction. The transaction is committed when fn
+// returns nil and rolled back when fn returns an error or panics.
+// Nested calls (tx.WithTx inside fn) use savepoints, so an inner
+// failure rolls back only the inner part.
+// Other goroutines wait with their calls until the transaction ends,
+// so fn must use tx only (calls on db would deadlock).
+func (db *Database) WithTx(fn func(tx *Tx) error) (err error) {
+	if !db.inTx {
+		db.txMu.Lock()
+		defer db.txMu.Unlock()
+		db = &Database{connection: db.connection, inTx: true}
+	}
+
+	begin := "BEGIN IMMEDIATE TRANSACTION"
+	commit := "COMMIT TRANSACTION"
+	rollback := "ROLLBACK TRANSACTION"
+	if db.txDepth > 0 {
+		name := fmt.Sprintf("tx_%d", db.txDepth)
+		begin = "SAVEPOINT " + name
+		commit = "RELEASE SAVEPOINT " + name
+		rollback = fmt.Sprintf("ROLLBACK TO SAVEPOINT %s; RELEASE SAVEPOINT %s", name, name)
+	}
+
+	nested := db.txDepth > 0
+	if err := db.ExecQuery(begin); err != nil {
+		return err
+	}
+	if nested {
+		db.savepointStarted()
+	}
+	db.txDepth++
+
+	defer func() {
+		db.txDepth--
+		if p := recover(); p != nil {
+			db.ExecQuery(rollback)
+			if nested {
+				db.savepointEnded(true)
+			}
+			panic(p)
+		}
+		if err == nil {
+			err = db.ExecQuery(commit)
+		}
+		if err != nil {
+			db.ExecQuery(rollback)
+		}
+		if nested {
+			db.savepointEnded(err != nil)
+		}
+	}()
+
+	return fn(&Tx{db})
+}
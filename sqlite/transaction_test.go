@@ -0,0 +1,173 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sqlite
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func timersCount(t *testing.T, db *Database) int64 {
+	n, err := db.Count("timer")
+	assert.Nil(t, err)
+	return n
+}
+
+func Test_WithTxCommit(t *testing.T) {
+	db := newTestDatabase(t)
+
+	err := db.WithTx(func(tx *Tx) error {
+		if err := tx.Exec("DELETE FROM timer WHERE company_id=?", 1); err != nil {
+			return err
+		}
+		return tx.Exec("DELETE FROM timer WHERE company_id=?", 2)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), timersCount(t, db))
+}
+
+func Test_WithTxRollback(t *testing.T) {
+	db := newTestDatabase(t)
+	failure := errors.New("failure")
+
+	err := db.WithTx(func(tx *Tx) error {
+		if err := tx.Exec("DELETE FROM timer"); err != nil {
+			return err
+		}
+		return failure
+	})
+
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, int64(6), timersCount(t, db))
+}
+
+func Test_WithTxPanic(t *testing.T) {
+	db := newTestDatabase(t)
+
+	assert.Panics(t, func() {
+		db.WithTx(func(tx *Tx) error {
+			tx.Exec("DELETE FROM timer")
+			panic("failure")
+		})
+	})
+	assert.Equal(t, int64(6), timersCount(t, db))
+
+	// connection must be usable (no transaction left open)
+	assert.Nil(t, db.WithTx(func(tx *Tx) error {
+		return tx.Exec("DELETE FROM timer WHERE id=?", 1)
+	}))
+	assert.Equal(t, int64(5), timersCount(t, db))
+}
+
+func Test_WithTxNested(t *testing.T) {
+	db := newTestDatabase(t)
+	failure := errors.New("failure")
+
+	err := db.WithTx(func(tx *Tx) error {
+		if err := tx.Exec("DELETE FROM timer WHERE company_id=?", 1); err != nil {
+			return err
+		}
+		// inner failure rolls back only the savepoint
+		innerErr := tx.WithTx(func(tx *Tx) error {
+			tx.Exec("DELETE FROM timer")
+			return failure
+		})
+		assert.ErrorIs(t, innerErr, failure)
+
+		return tx.WithTx(func(tx *Tx) error {
+			return tx.Exec("DELETE FROM timer WHERE company_id=?", 2)
+		})
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), timersCount(t, db))
+
+	// inner success is undone by outer failure
+	err = db.WithTx(func(tx *Tx) error {
+		assert.Nil(t, tx.WithTx(func(tx *Tx) error {
+			return tx.Exec("DELETE FROM timer")
+		}))
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, int64(2), timersCount(t, db))
+}
+
+func Test_WithTxBlocksOtherGoroutines(t *testing.T) {
+	db := newTestDatabase(t)
+	failure := errors.New("failure")
+	started := make(chan struct{})
+	done := make(chan error)
+
+	err := db.WithTx(func(tx *Tx) error {
+		if err := tx.Exec("DELETE FROM timer"); err != nil {
+			return err
+		}
+		go func() {
+			close(started)
+			// waits for the end of the transaction, so it isn't rolled back with it
+			done <- db.Exec("DELETE FROM timer WHERE company_id=?", 1)
+		}()
+		<-started
+		time.Sleep(20 * time.Millisecond)
+		return failure
+	})
+
+	assert.ErrorIs(t, err, failure)
+	assert.Nil(t, <-done)
+	assert.Equal(t, int64(4), timersCount(t, db))
+}
+
+func Test_ConcurrentAccess(t *testing.T) {
+	db := newTestDatabase(t)
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				err := db.WithTx(func(tx *Tx) error {
+					return tx.Exec("INSERT INTO timer (company_id, start, finish) VALUES (?, ?, ?)", 1+i%3, j, j+1)
+				})
+				assert.Nil(t, err)
+				_, err = db.Select("SELECT * FROM timer WHERE company_id=?", 1+i%3)
+				assert.Nil(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(6+8*25), timersCount(t, db))
+}
@@ -60,6 +60,24 @@ const (
 	StatusDone = 101  // sqlite3_step() has finished executing
 )
 
+// Extended result codes (primary code in the lower 8 bits).
+const (
+	BusyRecovery         = Busy | (1 << 8)
+	BusySnapshot         = Busy | (2 << 8)
+	BusyTimeout          = Busy | (3 << 8)
+	LockedSharedCache    = Locked | (1 << 8)
+	ConstraintCheck      = Constraint | (1 << 8)
+	ConstraintCommitHook = Constraint | (2 << 8)
+	ConstraintForeignKey = Constraint | (3 << 8)
+	ConstraintFunction   = Constraint | (4 << 8)
+	ConstraintNotNull    = Constraint | (5 << 8)
+	ConstraintPrimaryKey = Constraint | (6 << 8)
+	ConstraintTrigger    = Constraint | (7 << 8)
+	ConstraintUnique     = Constraint | (8 << 8)
+	ConstraintVTab       = Constraint | (9 << 8)
+	ConstraintRowID      = Constraint | (10 << 8)
+)
+
 type ValueType uint8
 
 const (
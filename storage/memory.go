@@ -0,0 +1,891 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"Timelancer/model/company"
+	"Timelancer/model/invoice"
+	"Timelancer/model/project"
+	"Timelancer/model/rate"
+	"Timelancer/model/tag"
+	"Timelancer/model/timer"
+)
+
+// Memory keeps the data in memory only (tests, demos).
+// It follows the rules of the database: shortcuts and names of companies
+// are unique (case insensitive), companies with timers can't be removed.
+// Tags have unique names too, as projects have codes and names unique
+// in the company. Timers are invoiced once only.
+type Memory struct {
+	mu              sync.Mutex
+	companies       map[int]company.Company
+	projects        map[int]project.Project
+	rates           map[int64]rate.Rate
+	timers          map[int64]timer.Timer
+	tags            map[int64]tag.Tag
+	timerTags       map[int64]map[int64]bool
+	invoices        map[int64]invoice.Invoice
+	timerInvoices   map[int64]int64
+	nextCompanyID   int
+	nextProjectID   int
+	nextRateID      int64
+	nextTimerID     int64
+	nextTagID       int64
+	nextInvoiceID   int64
+	nextSubscribeID int
+	companyHandlers map[int]func()
+	projectHandlers map[int]func()
+	rateHandlers    map[int]func()
+	timerHandlers   map[int]func()
+	tagHandlers     map[int]func()
+	invoiceHandlers map[int]func()
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		companies:       make(map[int]company.Company),
+		projects:        make(map[int]project.Project),
+		rates:           make(map[int64]rate.Rate),
+		timers:          make(map[int64]timer.Timer),
+		tags:            make(map[int64]tag.Tag),
+		timerTags:       make(map[int64]map[int64]bool),
+		invoices:        make(map[int64]invoice.Invoice),
+		timerInvoices:   make(map[int64]int64),
+		nextCompanyID:   1,
+		nextProjectID:   1,
+		nextRateID:      1,
+		nextTimerID:     1,
+		nextTagID:       1,
+		nextInvoiceID:   1,
+		companyHandlers: make(map[int]func()),
+		projectHandlers: make(map[int]func()),
+		rateHandlers:    make(map[int]func()),
+		timerHandlers:   make(map[int]func()),
+		tagHandlers:     make(map[int]func()),
+		invoiceHandlers: make(map[int]func()),
+	}
+}
+
+func (m *Memory) Companies() ([]*company.Company, error) {
+	return m.selectCompanies(func(*company.Company) bool { return true }), nil
+}
+
+func (m *Memory) CompaniesInUse() ([]*company.Company, error) {
+	return m.selectCompanies((*company.Company).Used), nil
+}
+
+func (m *Memory) CompanyWithID(id int) (*company.Company, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.companies[id]; ok {
+		return &c, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) SaveCompany(c *company.Company) error {
+	m.mu.Lock()
+	for id, other := range m.companies {
+		if id == c.ID() {
+			continue
+		}
+		if strings.EqualFold(other.Shortcut(), c.Shortcut()) {
+			m.mu.Unlock()
+			return ErrShortcutExists
+		}
+		if strings.EqualFold(other.Name(), c.Name()) {
+			m.mu.Unlock()
+			return ErrNameExists
+		}
+	}
+	isNew := c.ID() == 0
+	if isNew {
+		c.SetID(m.nextCompanyID)
+		m.nextCompanyID++
+		m.insertProject(project.NewDefault(c.ID()))
+	} else if old, ok := m.companies[c.ID()]; !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	} else if old.Currency() != c.Currency() && m.hasRatesOrTimers(c.ID()) {
+		m.mu.Unlock()
+		return ErrCurrencyInUse
+	}
+	m.companies[c.ID()] = *c
+	m.mu.Unlock()
+
+	m.notify(m.companyHandlers)
+	if isNew {
+		m.notify(m.projectHandlers)
+	}
+	return nil
+}
+
+func (m *Memory) RemoveCompany(c *company.Company) error {
+	m.mu.Lock()
+	for _, tm := range m.timers {
+		if tm.CompanyID() == int64(c.ID()) {
+			m.mu.Unlock()
+			return ErrCompanyInUse
+		}
+	}
+	for _, inv := range m.invoices {
+		if inv.CompanyID() == c.ID() {
+			m.mu.Unlock()
+			return ErrCompanyInUse
+		}
+	}
+	delete(m.companies, c.ID())
+	for id, p := range m.projects {
+		if p.CompanyID() == c.ID() {
+			delete(m.projects, id)
+		}
+	}
+	for id, r := range m.rates {
+		if r.CompanyID() == c.ID() {
+			delete(m.rates, id)
+		}
+	}
+	m.mu.Unlock()
+
+	m.notify(m.companyHandlers)
+	m.notify(m.projectHandlers)
+	m.notify(m.rateHandlers)
+	return nil
+}
+
+func (m *Memory) SubscribeCompanies(fn func()) func() {
+	return m.subscribe(m.companyHandlers, fn)
+}
+
+func (m *Memory) Projects(companyID int) ([]*project.Project, error) {
+	return m.selectProjects(companyID, func(*project.Project) bool { return true }), nil
+}
+
+func (m *Memory) ActiveProjects(companyID int) ([]*project.Project, error) {
+	return m.selectProjects(companyID, (*project.Project).Active), nil
+}
+
+func (m *Memory) ProjectWithID(id int) (*project.Project, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if p, ok := m.projects[id]; ok {
+		return &p, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) SaveProject(p *project.Project) error {
+	m.mu.Lock()
+	if _, ok := m.companies[p.CompanyID()]; !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	for id, other := range m.projects {
+		if id == p.ID() || other.CompanyID() != p.CompanyID() {
+			continue
+		}
+		if strings.EqualFold(other.Code(), p.Code()) {
+			m.mu.Unlock()
+			return ErrCodeExists
+		}
+		if strings.EqualFold(other.Name(), p.Name()) {
+			m.mu.Unlock()
+			return ErrProjectExists
+		}
+	}
+	if p.ID() == 0 {
+		m.insertProject(p)
+	} else if old, ok := m.projects[p.ID()]; !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	} else if old.CompanyID() != p.CompanyID() && m.hasTimers(p.ID()) {
+		m.mu.Unlock()
+		return ErrProjectInUse
+	} else {
+		m.projects[p.ID()] = *p
+	}
+	m.mu.Unlock()
+
+	m.notify(m.projectHandlers)
+	return nil
+}
+
+func (m *Memory) RemoveProject(p *project.Project) error {
+	m.mu.Lock()
+	if m.hasTimers(p.ID()) {
+		m.mu.Unlock()
+		return ErrProjectInUse
+	}
+	delete(m.projects, p.ID())
+	for id, r := range m.rates {
+		if r.ProjectID() == p.ID() {
+			delete(m.rates, id)
+		}
+	}
+	m.mu.Unlock()
+
+	m.notify(m.projectHandlers)
+	m.notify(m.rateHandlers)
+	return nil
+}
+
+func (m *Memory) SubscribeProjects(fn func()) func() {
+	return m.subscribe(m.projectHandlers, fn)
+}
+
+func (m *Memory) Rates(companyID int) ([]*rate.Rate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var data []*rate.Rate
+	for _, r := range m.rates {
+		r := r
+		if r.CompanyID() == companyID {
+			data = append(data, &r)
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].ProjectID() != data[j].ProjectID() {
+			return data[i].ProjectID() < data[j].ProjectID()
+		}
+		if !data[i].ValidFrom().Equal(data[j].ValidFrom()) {
+			return data[i].ValidFrom().Before(data[j].ValidFrom())
+		}
+		return data[i].ID() < data[j].ID()
+	})
+	return data, nil
+}
+
+func (m *Memory) SaveRate(r *rate.Rate) error {
+	m.mu.Lock()
+	if _, ok := m.companies[r.CompanyID()]; !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	if r.ProjectID() != rate.AllProjects {
+		p, ok := m.projects[r.ProjectID()]
+		if !ok {
+			m.mu.Unlock()
+			return ErrNotFound
+		}
+		if p.CompanyID() != r.CompanyID() {
+			m.mu.Unlock()
+			return ErrWrongProject
+		}
+	}
+	if r.ID() == 0 {
+		r.SetID(m.nextRateID)
+		m.nextRateID++
+	} else if _, ok := m.rates[r.ID()]; !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	m.rates[r.ID()] = *r
+	m.mu.Unlock()
+
+	m.notify(m.rateHandlers)
+	return nil
+}
+
+func (m *Memory) RemoveRate(r *rate.Rate) error {
+	m.mu.Lock()
+	delete(m.rates, r.ID())
+	m.mu.Unlock()
+
+	m.notify(m.rateHandlers)
+	return nil
+}
+
+func (m *Memory) SubscribeRates(fn func()) func() {
+	return m.subscribe(m.rateHandlers, fn)
+}
+
+func (m *Memory) SaveTimer(tm *timer.Timer) error {
+	m.mu.Lock()
+	err := m.saveTimer(tm)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify(m.timerHandlers)
+	return nil
+}
+
+// saveTimer must be called with the lock.
+func (m *Memory) saveTimer(tm *timer.Timer) error {
+	if _, ok := m.companies[int(tm.CompanyID())]; !ok {
+		return ErrNotFound
+	}
+	if err := m.assignProject(tm); err != nil {
+		return err
+	}
+	if tm.ID() == 0 {
+		tm.SetID(m.nextTimerID)
+		m.nextTimerID++
+	} else if old, ok := m.timers[tm.ID()]; !ok {
+		return ErrNotFound
+	} else if m.timerInvoices[tm.ID()] != 0 && !sameWork(old, *tm) {
+		return ErrInvoiced
+	}
+	m.timers[tm.ID()] = *tm
+	return nil
+}
+
+func (m *Memory) RemoveTimer(tm *timer.Timer) error {
+	m.mu.Lock()
+	if m.timerInvoices[tm.ID()] != 0 {
+		m.mu.Unlock()
+		return ErrInvoiced
+	}
+	delete(m.timers, tm.ID())
+	delete(m.timerTags, tm.ID())
+	m.mu.Unlock()
+
+	m.notify(m.timerHandlers)
+	return nil
+}
+
+// sameWork tells whether timers differ in the description only
+// (the description of the invoiced timer may be changed).
+func sameWork(a, b timer.Timer) bool {
+	return a.CompanyID() == b.CompanyID() && a.ProjectID() == b.ProjectID() &&
+		a.StartTime().Equal(b.StartTime()) && a.FinishTime().Equal(b.FinishTime())
+}
+
+func (m *Memory) TimerWithID(id int64) (*timer.Timer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if tm, ok := m.timers[id]; ok {
+		return &tm, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) Descriptions(companyID int) ([]string, error) {
+	m.mu.Lock()
+	lastUse := make(map[string]int64)
+	for _, tm := range m.timers {
+		if tm.CompanyID() == int64(companyID) && tm.Description() != "" {
+			if tm.ID() > lastUse[tm.Description()] {
+				lastUse[tm.Description()] = tm.ID()
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	data := make([]string, 0, len(lastUse))
+	for text := range lastUse {
+		data = append(data, text)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return lastUse[data[i]] > lastUse[data[j]]
+	})
+	return data, nil
+}
+
+func (m *Memory) TimerEntries(ctx context.Context, filter EntryFilter, fn func(TimerEntry)) error {
+	m.mu.Lock()
+	var entries []TimerEntry
+	for _, tm := range m.timers {
+		if !m.accepts(filter, &tm) {
+			continue
+		}
+		// like the join in the database: timers without company are skipped
+		if c, ok := m.companies[int(tm.CompanyID())]; ok {
+			p := m.projects[int(tm.ProjectID())]
+			var names []string
+			for _, t := range m.tagsOfTimer(tm.ID()) {
+				names = append(names, t.Name())
+			}
+			entries = append(entries, TimerEntry{
+				ID:           tm.ID(),
+				CompanyID:    tm.CompanyID(),
+				CompanyName:  c.Name(),
+				ProjectID:    tm.ProjectID(),
+				ProjectName:  p.Name(),
+				Start:        tm.StartTime(),
+				Finish:       tm.FinishTime(),
+				Description:  tm.Description(),
+				Tags:         strings.Join(names, ", "),
+				Currency:     c.Currency(),
+				CentsPerHour: m.centsPerHour(&tm),
+				InvoiceID:    m.timerInvoices[tm.ID()],
+			})
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID > entries[j].ID
+	})
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fn(entry)
+	}
+	return nil
+}
+
+func (m *Memory) SubscribeTimers(fn func()) func() {
+	return m.subscribe(m.timerHandlers, fn)
+}
+
+func (m *Memory) Tags() ([]*tag.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data := make([]*tag.Tag, 0, len(m.tags))
+	for _, t := range m.tags {
+		t := t
+		data = append(data, &t)
+	}
+	sortTags(data)
+	return data, nil
+}
+
+func (m *Memory) SaveTag(t *tag.Tag) error {
+	m.mu.Lock()
+	for id, other := range m.tags {
+		if id != t.ID() && strings.EqualFold(other.Name(), t.Name()) {
+			m.mu.Unlock()
+			return ErrTagExists
+		}
+	}
+	if t.ID() == 0 {
+		t.SetID(m.nextTagID)
+		m.nextTagID++
+	} else if _, ok := m.tags[t.ID()]; !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	m.tags[t.ID()] = *t
+	m.mu.Unlock()
+
+	m.notify(m.tagHandlers)
+	// names of tags are part of timer entries
+	m.notify(m.timerHandlers)
+	return nil
+}
+
+func (m *Memory) RemoveTag(t *tag.Tag) error {
+	m.mu.Lock()
+	delete(m.tags, t.ID())
+	for _, ids := range m.timerTags {
+		delete(ids, t.ID())
+	}
+	m.mu.Unlock()
+
+	m.notify(m.tagHandlers)
+	m.notify(m.timerHandlers)
+	return nil
+}
+
+func (m *Memory) TimerTags(timerID int64) ([]*tag.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.tagsOfTimer(timerID), nil
+}
+
+func (m *Memory) SetTimerTags(timerID int64, tagIDs []int64) error {
+	m.mu.Lock()
+	if _, ok := m.timers[timerID]; !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	ids, err := m.tagSet(tagIDs)
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	m.timerTags[timerID] = ids
+	m.mu.Unlock()
+
+	m.notify(m.timerHandlers)
+	return nil
+}
+
+func (m *Memory) SaveTimerWithTags(tm *timer.Timer, tagIDs []int64) error {
+	m.mu.Lock()
+	// tags are checked first, so nothing is saved if any of them is missing
+	ids, err := m.tagSet(tagIDs)
+	if err == nil {
+		err = m.saveTimer(tm)
+	}
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	m.timerTags[tm.ID()] = ids
+	m.mu.Unlock()
+
+	m.notify(m.timerHandlers)
+	return nil
+}
+
+// tagSet returns ids of existing tags, must be called with the lock.
+func (m *Memory) tagSet(tagIDs []int64) (map[int64]bool, error) {
+	ids := make(map[int64]bool)
+	for _, id := range tagIDs {
+		if _, ok := m.tags[id]; !ok {
+			return nil, ErrNotFound
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+func (m *Memory) TagTotals(ctx context.Context, filter EntryFilter) ([]TagTotal, error) {
+	m.mu.Lock()
+	totals := make(map[int64]*TagTotal)
+	for _, tm := range m.timers {
+		if _, ok := m.companies[int(tm.CompanyID())]; !ok || !m.accepts(filter, &tm) {
+			continue
+		}
+		for id := range m.timerTags[tm.ID()] {
+			if filter.TagID != AnyTag && id != filter.TagID {
+				continue
+			}
+			total, ok := totals[id]
+			if !ok {
+				tg := m.tags[id]
+				total = &TagTotal{TagID: id, Name: tg.Name()}
+				totals[id] = total
+			}
+			total.Seconds += tm.FinishTime().Unix() - tm.StartTime().Unix()
+		}
+	}
+	m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var data []TagTotal
+	for _, total := range totals {
+		data = append(data, *total)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].Seconds != data[j].Seconds {
+			return data[i].Seconds > data[j].Seconds
+		}
+		return strings.ToLower(data[i].Name) < strings.ToLower(data[j].Name)
+	})
+	return data, nil
+}
+
+func (m *Memory) SubscribeTags(fn func()) func() {
+	return m.subscribe(m.tagHandlers, fn)
+}
+
+func (m *Memory) Invoices(year int) ([]*invoice.Invoice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var data []*invoice.Invoice
+	for _, inv := range m.invoices {
+		if inv.Year() == year {
+			data = append(data, m.invoiceCopy(inv))
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Sequence() < data[j].Sequence()
+	})
+	return data, nil
+}
+
+func (m *Memory) InvoiceWithID(id int64) (*invoice.Invoice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if inv, ok := m.invoices[id]; ok {
+		return m.invoiceCopy(inv), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) SaveInvoice(inv *invoice.Invoice) error {
+	if inv.ID() != 0 {
+		return ErrInvoiced
+	}
+
+	m.mu.Lock()
+	for _, id := range inv.TimerIDs() {
+		tm, ok := m.timers[id]
+		if !ok || tm.CompanyID() != int64(inv.CompanyID()) {
+			m.mu.Unlock()
+			return ErrNotFound
+		}
+		if m.timerInvoices[id] != 0 {
+			m.mu.Unlock()
+			return ErrInvoiced
+		}
+	}
+	last := 0
+	for _, other := range m.invoices {
+		if other.Year() == inv.Year() && other.Sequence() > last {
+			last = other.Sequence()
+		}
+	}
+	inv.SetID(m.nextInvoiceID)
+	inv.SetSequence(last + 1)
+	m.nextInvoiceID++
+	for _, id := range inv.TimerIDs() {
+		m.timerInvoices[id] = inv.ID()
+	}
+	saved := *inv
+	saved.SetLines(append([]invoice.Line(nil), inv.Lines()...))
+	m.invoices[inv.ID()] = saved
+	m.mu.Unlock()
+
+	m.notify(m.invoiceHandlers)
+	m.notify(m.timerHandlers)
+	return nil
+}
+
+func (m *Memory) SubscribeInvoices(fn func()) func() {
+	return m.subscribe(m.invoiceHandlers, fn)
+}
+
+/********************************************************************
+*                                                                   *
+*                         P R I V A T E                             *
+*                                                                   *
+********************************************************************/
+
+func (m *Memory) selectCompanies(accept func(*company.Company) bool) []*company.Company {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var data []*company.Company
+	for _, c := range m.companies {
+		c := c
+		if accept(&c) {
+			data = append(data, &c)
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return strings.ToLower(data[i].Shortcut()) < strings.ToLower(data[j].Shortcut())
+	})
+	return data
+}
+
+func (m *Memory) selectProjects(companyID int, accept func(*project.Project) bool) []*project.Project {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var data []*project.Project
+	for _, p := range m.projects {
+		p := p
+		if p.CompanyID() == companyID && accept(&p) {
+			data = append(data, &p)
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return strings.ToLower(data[i].Code()) < strings.ToLower(data[j].Code())
+	})
+	return data
+}
+
+// hasRatesOrTimers must be called with the lock.
+func (m *Memory) hasRatesOrTimers(companyID int) bool {
+	for _, r := range m.rates {
+		if r.CompanyID() == companyID {
+			return true
+		}
+	}
+	for _, tm := range m.timers {
+		if tm.CompanyID() == int64(companyID) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTimers must be called with the lock.
+func (m *Memory) hasTimers(projectID int) bool {
+	for _, tm := range m.timers {
+		if tm.ProjectID() == int64(projectID) {
+			return true
+		}
+	}
+	return false
+}
+
+// insertProject must be called with the lock.
+func (m *Memory) insertProject(p *project.Project) {
+	p.SetID(m.nextProjectID)
+	m.nextProjectID++
+	m.projects[p.ID()] = *p
+}
+
+// assignProject works like the one of SQLite, must be called with the lock.
+func (m *Memory) assignProject(tm *timer.Timer) error {
+	if tm.ProjectID() != 0 {
+		p, ok := m.projects[int(tm.ProjectID())]
+		if !ok {
+			return ErrNotFound
+		}
+		if int64(p.CompanyID()) != tm.CompanyID() {
+			return ErrWrongProject
+		}
+		return nil
+	}
+
+	defaultID := 0
+	for id, p := range m.projects {
+		if int64(p.CompanyID()) == tm.CompanyID() && (defaultID == 0 || id < defaultID) {
+			defaultID = id
+		}
+	}
+	if defaultID == 0 {
+		p := project.NewDefault(int(tm.CompanyID()))
+		m.insertProject(p)
+		defaultID = p.ID()
+	}
+	tm.SetProjectID(int64(defaultID))
+	return nil
+}
+
+// centsPerHour works like the subquery of SQLite, must be called with the lock.
+func (m *Memory) centsPerHour(tm *timer.Timer) int64 {
+	var best *rate.Rate
+	for _, r := range m.rates {
+		r := r
+		if int64(r.CompanyID()) != tm.CompanyID() || !r.EffectiveAt(tm.StartTime()) {
+			continue
+		}
+		if r.ProjectID() != rate.AllProjects && int64(r.ProjectID()) != tm.ProjectID() {
+			continue
+		}
+		if best == nil || betterRate(&r, best) {
+			best = &r
+		}
+	}
+	if best == nil {
+		return 0
+	}
+	return best.CentsPerHour()
+}
+
+// betterRate checks if a wins over b: the rate of a project over the rate
+// of the company, then the latest started one, then the last saved one.
+func betterRate(a, b *rate.Rate) bool {
+	if a.ProjectID() != b.ProjectID() {
+		return a.ProjectID() > b.ProjectID()
+	}
+	if !a.ValidFrom().Equal(b.ValidFrom()) {
+		return a.ValidFrom().After(b.ValidFrom())
+	}
+	return a.ID() > b.ID()
+}
+
+// accepts checks if the timer is selected by the filter, must be called with the lock.
+func (m *Memory) accepts(filter EntryFilter, tm *timer.Timer) bool {
+	if filter.CompanyID != AllCompanies && tm.CompanyID() != int64(filter.CompanyID) {
+		return false
+	}
+	if filter.ProjectID != AllProjects && tm.ProjectID() != int64(filter.ProjectID) {
+		return false
+	}
+	if filter.NotInvoiced && m.timerInvoices[tm.ID()] != 0 {
+		return false
+	}
+	return filter.TagID == AnyTag || m.timerTags[tm.ID()][filter.TagID]
+}
+
+// invoiceCopy returns the invoice with its own lines and ids of its timers
+// (ordered like in the database), must be called with the lock.
+func (m *Memory) invoiceCopy(inv invoice.Invoice) *invoice.Invoice {
+	timerIDs := make([]int64, 0)
+	for timerID, invoiceID := range m.timerInvoices {
+		if invoiceID == inv.ID() {
+			timerIDs = append(timerIDs, timerID)
+		}
+	}
+	sort.Slice(timerIDs, func(i, j int) bool { return timerIDs[i] < timerIDs[j] })
+	inv.SetLines(append([]invoice.Line(nil), inv.Lines()...))
+	inv.SetTimerIDs(timerIDs)
+	return &inv
+}
+
+// tagsOfTimer must be called with the lock.
+func (m *Memory) tagsOfTimer(timerID int64) []*tag.Tag {
+	var data []*tag.Tag
+	for id := range m.timerTags[timerID] {
+		t := m.tags[id]
+		data = append(data, &t)
+	}
+	sortTags(data)
+	return data
+}
+
+func sortTags(data []*tag.Tag) {
+	sort.Slice(data, func(i, j int) bool {
+		return strings.ToLower(data[i].Name()) < strings.ToLower(data[j].Name())
+	})
+}
+
+func (m *Memory) subscribe(handlers map[int]func(), fn func()) func() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextSubscribeID
+	m.nextSubscribeID++
+	handlers[id] = fn
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(handlers, id)
+	}
+}
+
+// notify calls the handlers synchronously, must be called without the lock.
+func (m *Memory) notify(handlers map[int]func()) {
+	m.mu.Lock()
+	fns := make([]func(), 0, len(handlers))
+	for _, fn := range handlers {
+		fns = append(fns, fn)
+	}
+	m.mu.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
@@ -0,0 +1,739 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"Timelancer/model/company"
+	"Timelancer/model/invoice"
+	"Timelancer/model/project"
+	"Timelancer/model/rate"
+	"Timelancer/model/tag"
+	"Timelancer/model/timer"
+	"Timelancer/sqlite"
+	"Timelancer/sqlite/mapper"
+)
+
+// SQLite keeps the data in the application database.
+type SQLite struct {
+	db *sqlite.Database
+}
+
+func NewSQLite(db *sqlite.Database) *SQLite {
+	return &SQLite{db: db}
+}
+
+func (s *SQLite) Companies() ([]*company.Company, error) {
+	return s.companies("SELECT * FROM company ORDER BY shortcut ASC")
+}
+
+func (s *SQLite) CompaniesInUse() ([]*company.Company, error) {
+	return s.companies("SELECT * FROM company WHERE used=1 ORDER BY shortcut ASC")
+}
+
+func (s *SQLite) CompanyWithID(id int) (*company.Company, error) {
+	data, err := s.companies("SELECT * FROM company WHERE id=?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, ErrNotFound
+	}
+	return data[0], nil
+}
+
+func (s *SQLite) SaveCompany(c *company.Company) error {
+	if c.ID() == 0 {
+		var id int64
+		err := s.db.WithTx(func(tx *sqlite.Tx) error {
+			var err error
+			if id, err = insert(tx.Database, "company", c); err != nil {
+				return err
+			}
+			_, err = insert(tx.Database, "project", project.NewDefault(int(id)))
+			return err
+		})
+		if err != nil {
+			return translateError(err)
+		}
+		c.SetID(int(id))
+		return nil
+	}
+	err := s.db.WithTx(func(tx *sqlite.Tx) error {
+		where := `id=? AND currency<>? AND (EXISTS (SELECT 1 FROM rate WHERE rate.company_id=company.id)
+		OR EXISTS (SELECT 1 FROM timer WHERE timer.company_id=company.id))`
+		n, err := tx.CountWhere("company", where, c.ID(), c.Currency())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrCurrencyInUse
+		}
+		return update(tx.Database, "company", c)
+	})
+	return translateError(err)
+}
+
+func (s *SQLite) RemoveCompany(c *company.Company) error {
+	err := s.db.WithTx(func(tx *sqlite.Tx) error {
+		n, err := tx.CountWhere("timer", "company_id=?", c.ID())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrCompanyInUse
+		}
+		if n, err = tx.CountWhere("invoice", "company_id=?", c.ID()); err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrCompanyInUse
+		}
+		if err := tx.Exec("DELETE FROM rate WHERE company_id=?", c.ID()); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM project WHERE company_id=?", c.ID()); err != nil {
+			return err
+		}
+		return tx.Delete("company", "id", c.ID())
+	})
+	return translateError(err)
+}
+
+func (s *SQLite) SubscribeCompanies(fn func()) func() {
+	return s.subscribe(fn, "company")
+}
+
+func (s *SQLite) Projects(companyID int) ([]*project.Project, error) {
+	return s.projects("SELECT * FROM project WHERE company_id=? ORDER BY code ASC", companyID)
+}
+
+func (s *SQLite) ActiveProjects(companyID int) ([]*project.Project, error) {
+	return s.projects("SELECT * FROM project WHERE company_id=? AND active=1 ORDER BY code ASC", companyID)
+}
+
+func (s *SQLite) ProjectWithID(id int) (*project.Project, error) {
+	p, err := sqlite.QueryOne[project.Project](s.db, "SELECT * FROM project WHERE id=?", id)
+	if errors.Is(err, sqlite.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &p, nil
+}
+
+func (s *SQLite) SaveProject(p *project.Project) error {
+	if p.ID() == 0 {
+		id, err := insert(s.db, "project", p)
+		if err != nil {
+			return err
+		}
+		p.SetID(int(id))
+		return nil
+	}
+	err := s.db.WithTx(func(tx *sqlite.Tx) error {
+		where := "id=? AND company_id<>? AND EXISTS (SELECT 1 FROM timer WHERE timer.project_id=project.id)"
+		n, err := tx.CountWhere("project", where, p.ID(), p.CompanyID())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrProjectInUse
+		}
+		return update(tx.Database, "project", p)
+	})
+	return translateError(err)
+}
+
+func (s *SQLite) RemoveProject(p *project.Project) error {
+	err := s.db.WithTx(func(tx *sqlite.Tx) error {
+		n, err := tx.CountWhere("timer", "project_id=?", p.ID())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrProjectInUse
+		}
+		if err := tx.Exec("DELETE FROM rate WHERE project_id=?", p.ID()); err != nil {
+			return err
+		}
+		return tx.Delete("project", "id", p.ID())
+	})
+	return translateError(err)
+}
+
+func (s *SQLite) SubscribeProjects(fn func()) func() {
+	return s.subscribe(fn, "project")
+}
+
+func (s *SQLite) Rates(companyID int) ([]*rate.Rate, error) {
+	result, err := sqlite.QueryAll[rate.Rate](s.db, "SELECT * FROM rate WHERE company_id=? ORDER BY project_id ASC, valid_from ASC, id ASC", companyID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	data := make([]*rate.Rate, len(result))
+	for i := range result {
+		data[i] = &result[i]
+	}
+	return data, nil
+}
+
+func (s *SQLite) SaveRate(r *rate.Rate) error {
+	err := s.db.WithTx(func(tx *sqlite.Tx) error {
+		if n, err := tx.CountWhere("company", "id=?", r.CompanyID()); err != nil || n == 0 {
+			return notFound(err)
+		}
+		if r.ProjectID() != rate.AllProjects {
+			p, err := sqlite.QueryOne[project.Project](tx.Database, "SELECT * FROM project WHERE id=?", r.ProjectID())
+			if errors.Is(err, sqlite.ErrNoRows) {
+				return ErrNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if p.CompanyID() != r.CompanyID() {
+				return ErrWrongProject
+			}
+		}
+		if r.ID() == 0 {
+			id, err := insert(tx.Database, "rate", r)
+			if err != nil {
+				return err
+			}
+			r.SetID(id)
+			return nil
+		}
+		return update(tx.Database, "rate", r)
+	})
+	return translateError(err)
+}
+
+func (s *SQLite) RemoveRate(r *rate.Rate) error {
+	return translateError(s.db.Exec("DELETE FROM rate WHERE id=?", r.ID()))
+}
+
+func (s *SQLite) SubscribeRates(fn func()) func() {
+	return s.subscribe(fn, "rate")
+}
+
+func (s *SQLite) SaveTimer(tm *timer.Timer) error {
+	err := s.db.WithTx(func(tx *sqlite.Tx) error {
+		return saveTimer(tx, tm)
+	})
+	return translateError(err)
+}
+
+// saveTimer is SaveTimer inside of the transaction.
+func saveTimer(tx *sqlite.Tx, tm *timer.Timer) error {
+	if n, err := tx.CountWhere("company", "id=?", tm.CompanyID()); err != nil || n == 0 {
+		return notFound(err)
+	}
+	if err := assignProject(tx, tm); err != nil {
+		return err
+	}
+	if tm.ID() != 0 {
+		// the description of the invoiced timer may be changed, the work itself not
+		where := "id=? AND invoice_id<>0 AND (company_id<>? OR start<>? OR finish<>? OR project_id IS NOT ?)"
+		n, err := tx.CountWhere("timer", where, tm.ID(), tm.CompanyID(), tm.StartTime().Unix(), tm.FinishTime().Unix(), tm.ProjectID())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrInvoiced
+		}
+	}
+	if tm.ID() == 0 {
+		id, err := insert(tx.Database, "timer", tm)
+		if err != nil {
+			return err
+		}
+		tm.SetID(id)
+		return nil
+	}
+	return update(tx.Database, "timer", tm)
+}
+
+func (s *SQLite) RemoveTimer(tm *timer.Timer) error {
+	err := s.db.WithTx(func(tx *sqlite.Tx) error {
+		n, err := tx.CountWhere("timer", "id=? AND invoice_id<>0", tm.ID())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrInvoiced
+		}
+		// tags of the timer are removed by the foreign key (cascade)
+		return tx.Exec("DELETE FROM timer WHERE id=?", tm.ID())
+	})
+	return translateError(err)
+}
+
+func (s *SQLite) TimerWithID(id int64) (*timer.Timer, error) {
+	tm, err := sqlite.QueryOne[timer.Timer](s.db, "SELECT * FROM timer WHERE id=?", id)
+	if errors.Is(err, sqlite.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &tm, nil
+}
+
+func (s *SQLite) Descriptions(companyID int) ([]string, error) {
+	type description struct {
+		Text string `db:"description"`
+	}
+	query := `SELECT description FROM timer WHERE company_id=? AND description<>''
+	GROUP BY description ORDER BY max(id) DESC`
+
+	result, err := sqlite.QueryAll[description](s.db, query, companyID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	data := make([]string, len(result))
+	for i, d := range result {
+		data[i] = d.Text
+	}
+	return data, nil
+}
+
+func (s *SQLite) TimerEntries(ctx context.Context, filter EntryFilter, fn func(TimerEntry)) error {
+	query := `SELECT timer.id AS id, timer.company_id AS company_id, company.name AS company_name,
+	ifnull(timer.project_id, 0) AS project_id, ifnull(project.name, '') AS project_name,
+	timer.start AS start, timer.finish AS finish, timer.description AS description,
+	ifnull((SELECT group_concat(name, ', ') FROM (SELECT tag.name AS name FROM timer_tag, tag
+		WHERE timer_tag.timer_id=timer.id AND timer_tag.tag_id=tag.id ORDER BY tag.name)), '') AS tags,
+	company.currency AS currency,
+	ifnull((SELECT rate.cents_per_hour FROM rate WHERE rate.company_id=timer.company_id
+		AND rate.project_id IN (0, ifnull(timer.project_id, 0))
+		AND rate.valid_from<=timer.start AND (rate.valid_until=0 OR timer.start<rate.valid_until)
+		ORDER BY rate.project_id DESC, rate.valid_from DESC, rate.id DESC LIMIT 1), 0) AS cents_per_hour,
+	timer.invoice_id AS invoice_id
+	FROM timer, company LEFT JOIN project ON timer.project_id=project.id
+	WHERE timer.company_id=company.id`
+	condition, args := filterCondition(filter)
+	query += condition + " ORDER BY timer.id DESC"
+
+	// entries are read one by one, whole history is never in memory
+	for entry, err := range sqlite.QueryContext[TimerEntry](ctx, s.db, query, args...) {
+		if err != nil {
+			return queryError(ctx, err)
+		}
+		fn(entry)
+	}
+	return nil
+}
+
+func (s *SQLite) SubscribeTimers(fn func()) func() {
+	return s.subscribe(fn, "timer", "timer_tag", "tag")
+}
+
+func (s *SQLite) Tags() ([]*tag.Tag, error) {
+	return s.tags("SELECT * FROM tag ORDER BY name ASC")
+}
+
+func (s *SQLite) SaveTag(t *tag.Tag) error {
+	if t.ID() == 0 {
+		id, err := insert(s.db, "tag", t)
+		if err != nil {
+			return err
+		}
+		t.SetID(id)
+		return nil
+	}
+	return update(s.db, "tag", t)
+}
+
+func (s *SQLite) RemoveTag(t *tag.Tag) error {
+	// the tag is removed from timers by the foreign key (cascade)
+	return translateError(s.db.Exec("DELETE FROM tag WHERE id=?", t.ID()))
+}
+
+func (s *SQLite) TimerTags(timerID int64) ([]*tag.Tag, error) {
+	return s.tags(`SELECT tag.id AS id, tag.name AS name FROM tag, timer_tag
+	WHERE tag.id=timer_tag.tag_id AND timer_tag.timer_id=? ORDER BY tag.name ASC`, timerID)
+}
+
+func (s *SQLite) SetTimerTags(timerID int64, tagIDs []int64) error {
+	err := s.db.WithTx(func(tx *sqlite.Tx) error {
+		return setTimerTags(tx, timerID, tagIDs)
+	})
+	return translateError(err)
+}
+
+func (s *SQLite) SaveTimerWithTags(tm *timer.Timer, tagIDs []int64) error {
+	id := tm.ID()
+	err := s.db.WithTx(func(tx *sqlite.Tx) error {
+		if err := saveTimer(tx, tm); err != nil {
+			return err
+		}
+		return setTimerTags(tx, tm.ID(), tagIDs)
+	})
+	if err != nil {
+		// the new timer was rolled back
+		tm.SetID(id)
+	}
+	return translateError(err)
+}
+
+// setTimerTags is SetTimerTags inside of the transaction.
+func setTimerTags(tx *sqlite.Tx, timerID int64, tagIDs []int64) error {
+	if n, err := tx.CountWhere("timer", "id=?", timerID); err != nil || n == 0 {
+		return notFound(err)
+	}
+	if err := tx.Exec("DELETE FROM timer_tag WHERE timer_id=?", timerID); err != nil {
+		return err
+	}
+	for _, tagID := range tagIDs {
+		if n, err := tx.CountWhere("tag", "id=?", tagID); err != nil || n == 0 {
+			return notFound(err)
+		}
+		if err := tx.Exec("INSERT OR IGNORE INTO timer_tag (timer_id, tag_id) VALUES (?, ?)", timerID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLite) TagTotals(ctx context.Context, filter EntryFilter) ([]TagTotal, error) {
+	query := `SELECT tag.id AS id, tag.name AS name, sum(timer.finish-timer.start) AS seconds
+	FROM timer, timer_tag, tag WHERE timer.id=timer_tag.timer_id AND timer_tag.tag_id=tag.id`
+	condition, args := filterCondition(filter)
+	if filter.TagID != AnyTag {
+		// only the total of the selected tag (not of other tags of these timers)
+		condition += " AND tag.id=?"
+		args = append(args, filter.TagID)
+	}
+	query += condition + " GROUP BY tag.id ORDER BY seconds DESC, tag.name ASC"
+
+	var data []TagTotal
+	for total, err := range sqlite.QueryContext[TagTotal](ctx, s.db, query, args...) {
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		data = append(data, total)
+	}
+	return data, nil
+}
+
+func (s *SQLite) SubscribeTags(fn func()) func() {
+	return s.subscribe(fn, "tag")
+}
+
+func (s *SQLite) Invoices(year int) ([]*invoice.Invoice, error) {
+	return s.invoices("SELECT * FROM invoice WHERE year=? ORDER BY sequence ASC", year)
+}
+
+func (s *SQLite) InvoiceWithID(id int64) (*invoice.Invoice, error) {
+	data, err := s.invoices("SELECT * FROM invoice WHERE id=?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, ErrNotFound
+	}
+	return data[0], nil
+}
+
+func (s *SQLite) SaveInvoice(inv *invoice.Invoice) error {
+	if inv.ID() != 0 {
+		return ErrInvoiced
+	}
+
+	var id int64
+	err := s.db.WithTx(func(tx *sqlite.Tx) error {
+		type sequenceRow struct {
+			Last int `db:"last"`
+		}
+		type timerRow struct {
+			CompanyID int   `db:"company_id"`
+			InvoiceID int64 `db:"invoice_id"`
+		}
+
+		last, err := sqlite.QueryOne[sequenceRow](tx.Database, "SELECT ifnull(max(sequence), 0) AS last FROM invoice WHERE year=?", inv.Year())
+		if err != nil {
+			return err
+		}
+		inv.SetSequence(last.Last + 1)
+		if id, err = insert(tx.Database, "invoice", inv); err != nil {
+			return err
+		}
+		for i, line := range inv.Lines() {
+			err := tx.Exec(`INSERT INTO invoice_line (invoice_id, position, description, seconds, cents_per_hour, net_cents)
+			VALUES (?, ?, ?, ?, ?, ?)`, id, i+1, line.Description, line.Seconds, line.CentsPerHour, line.NetCents)
+			if err != nil {
+				return err
+			}
+		}
+		for _, timerID := range inv.TimerIDs() {
+			tm, err := sqlite.QueryOne[timerRow](tx.Database, "SELECT company_id, invoice_id FROM timer WHERE id=?", timerID)
+			if errors.Is(err, sqlite.ErrNoRows) || (err == nil && tm.CompanyID != inv.CompanyID()) {
+				return ErrNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if tm.InvoiceID != 0 {
+				return ErrInvoiced
+			}
+			if err := tx.Exec("UPDATE timer SET invoice_id=? WHERE id=?", id, timerID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		inv.SetSequence(0)
+		return translateError(err)
+	}
+	inv.SetID(id)
+	return nil
+}
+
+func (s *SQLite) SubscribeInvoices(fn func()) func() {
+	return s.subscribe(fn, "invoice")
+}
+
+/********************************************************************
+*                                                                   *
+*                         P R I V A T E                             *
+*                                                                   *
+********************************************************************/
+
+func (s *SQLite) companies(query string, args ...interface{}) ([]*company.Company, error) {
+	result, err := sqlite.QueryAll[company.Company](s.db, query, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	data := make([]*company.Company, len(result))
+	for i := range result {
+		data[i] = &result[i]
+	}
+	return data, nil
+}
+
+func (s *SQLite) tags(query string, args ...interface{}) ([]*tag.Tag, error) {
+	result, err := sqlite.QueryAll[tag.Tag](s.db, query, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	data := make([]*tag.Tag, len(result))
+	for i := range result {
+		data[i] = &result[i]
+	}
+	return data, nil
+}
+
+func (s *SQLite) projects(query string, args ...interface{}) ([]*project.Project, error) {
+	result, err := sqlite.QueryAll[project.Project](s.db, query, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	data := make([]*project.Project, len(result))
+	for i := range result {
+		data[i] = &result[i]
+	}
+	return data, nil
+}
+
+// invoices reads invoices with their lines and timers.
+func (s *SQLite) invoices(query string, args ...interface{}) ([]*invoice.Invoice, error) {
+	type timerRow struct {
+		ID int64 `db:"id"`
+	}
+
+	result, err := sqlite.QueryAll[invoice.Invoice](s.db, query, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	data := make([]*invoice.Invoice, len(result))
+	for i := range result {
+		inv := &result[i]
+		lines, err := sqlite.QueryAll[invoice.Line](s.db, `SELECT description, seconds, cents_per_hour, net_cents
+		FROM invoice_line WHERE invoice_id=? ORDER BY position ASC`, inv.ID())
+		if err != nil {
+			return nil, translateError(err)
+		}
+		timers, err := sqlite.QueryAll[timerRow](s.db, "SELECT id FROM timer WHERE invoice_id=? ORDER BY id ASC", inv.ID())
+		if err != nil {
+			return nil, translateError(err)
+		}
+		timerIDs := make([]int64, len(timers))
+		for j, tm := range timers {
+			timerIDs[j] = tm.ID
+		}
+		inv.SetLines(lines)
+		inv.SetTimerIDs(timerIDs)
+		data[i] = inv
+	}
+	return data, nil
+}
+
+// assignProject sets the default project of the company if the timer
+// has no project, otherwise checks that the project is of the company.
+func assignProject(tx *sqlite.Tx, tm *timer.Timer) error {
+	type projectRow struct {
+		ID        int64 `db:"id"`
+		CompanyID int64 `db:"company_id"`
+	}
+
+	if tm.ProjectID() != 0 {
+		p, err := sqlite.QueryOne[projectRow](tx.Database, "SELECT id, company_id FROM project WHERE id=?", tm.ProjectID())
+		if errors.Is(err, sqlite.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if p.CompanyID != tm.CompanyID() {
+			return ErrWrongProject
+		}
+		return nil
+	}
+
+	p, err := sqlite.QueryOne[projectRow](tx.Database, "SELECT id, company_id FROM project WHERE company_id=? ORDER BY id ASC LIMIT 1", tm.CompanyID())
+	if errors.Is(err, sqlite.ErrNoRows) {
+		id, err := insert(tx.Database, "project", project.NewDefault(int(tm.CompanyID())))
+		if err != nil {
+			return err
+		}
+		tm.SetProjectID(id)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	tm.SetProjectID(p.ID)
+	return nil
+}
+
+func insert(db *sqlite.Database, table string, v interface{}) (int64, error) {
+	fields, err := mapper.Fields(v)
+	if err != nil {
+		return 0, err
+	}
+	id, err := db.Insert(table, fields)
+	return id, translateError(err)
+}
+
+func update(db *sqlite.Database, table string, v interface{}) error {
+	fields, err := mapper.Fields(v)
+	if err != nil {
+		return err
+	}
+	n, err := db.Update(table, mapper.Keys(v), fields)
+	if err != nil {
+		return translateError(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLite) subscribe(fn func(), tables ...string) func() {
+	return s.db.Subscribe(func(changes sqlite.Changes) {
+		if changes.Touches(tables...) {
+			fn()
+		}
+	})
+}
+
+// filterCondition returns the part of WHERE clause (with timer table)
+// selecting timers of the filter.
+func filterCondition(filter EntryFilter) (string, []interface{}) {
+	var condition string
+	var args []interface{}
+	if filter.CompanyID != AllCompanies {
+		condition += " AND timer.company_id=?"
+		args = append(args, filter.CompanyID)
+	}
+	if filter.ProjectID != AllProjects {
+		condition += " AND timer.project_id=?"
+		args = append(args, filter.ProjectID)
+	}
+	if filter.TagID != AnyTag {
+		condition += " AND timer.id IN (SELECT timer_id FROM timer_tag WHERE tag_id=?)"
+		args = append(args, filter.TagID)
+	}
+	if filter.NotInvoiced {
+		condition += " AND timer.invoice_id=0"
+	}
+	return condition, args
+}
+
+// queryError returns ctx.Err() if the query was interrupted because ctx is done.
+func queryError(ctx context.Context, err error) error {
+	if sqlite.IsInterrupt(err) && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return translateError(err)
+}
+
+func notFound(err error) error {
+	if err != nil {
+		return err
+	}
+	return ErrNotFound
+}
+
+// translateError turns failures of the database into errors of storage
+// (the original error is kept in the message). Foreign keys fail when
+// a row refers to a missing one (removals check their references first).
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case sqlite.IsUniqueViolation(err) && strings.Contains(err.Error(), "company.shortcut"):
+		return fmt.Errorf("%w (%v)", ErrShortcutExists, err)
+	case sqlite.IsUniqueViolation(err) && strings.Contains(err.Error(), "company.name"):
+		return fmt.Errorf("%w (%v)", ErrNameExists, err)
+	case sqlite.IsUniqueViolation(err) && strings.Contains(err.Error(), "tag.name"):
+		return fmt.Errorf("%w (%v)", ErrTagExists, err)
+	case sqlite.IsUniqueViolation(err) && strings.Contains(err.Error(), "project.code"):
+		return fmt.Errorf("%w (%v)", ErrCodeExists, err)
+	case sqlite.IsUniqueViolation(err) && strings.Contains(err.Error(), "project.name"):
+		return fmt.Errorf("%w (%v)", ErrProjectExists, err)
+	case sqlite.IsForeignKeyViolation(err):
+		return fmt.Errorf("%w (%v)", ErrNotFound, err)
+	case sqlite.IsBusy(err):
+		return fmt.Errorf("%w (%v)", ErrBusy, err)
+	}
+	return err
+}
@@ -0,0 +1,266 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"Timelancer/model/company"
+	"Timelancer/model/invoice"
+	"Timelancer/model/project"
+	"Timelancer/model/rate"
+	"Timelancer/model/tag"
+	"Timelancer/model/timer"
+)
+
+var (
+	ErrNotFound       = errors.New("not found")
+	ErrShortcutExists = errors.New("company shortcut already exists")
+	ErrNameExists     = errors.New("company name already exists")
+	ErrCompanyInUse   = errors.New("company has saved working times")
+	ErrTagExists      = errors.New("tag already exists")
+	ErrCodeExists     = errors.New("project code already exists")
+	ErrProjectExists  = errors.New("project name already exists")
+	ErrProjectInUse   = errors.New("project has saved working times")
+	ErrWrongProject   = errors.New("project belongs to other company")
+	ErrInvoiced       = errors.New("already invoiced")
+	ErrCurrencyInUse  = errors.New("company currency is used by rates or working times")
+	ErrBusy           = errors.New("storage is busy")
+)
+
+const (
+	// AllCompanies selects timers of all companies in EntryFilter.
+	AllCompanies = -1
+	// AllProjects selects timers of all projects in EntryFilter.
+	AllProjects = 0
+	// AnyTag selects timers with and without tags in EntryFilter.
+	AnyTag = 0
+)
+
+// EntryFilter selects timers for TimerEntries and TagTotals.
+type EntryFilter struct {
+	CompanyID   int   // or AllCompanies
+	ProjectID   int   // or AllProjects
+	TagID       int64 // or AnyTag
+	NotInvoiced bool  // skips invoiced timers
+}
+
+// CompanyRepository keeps companies. Returned companies are copies,
+// changes must be saved with SaveCompany.
+type CompanyRepository interface {
+	// Companies returns all companies ordered by shortcut.
+	Companies() ([]*company.Company, error)
+	// CompaniesInUse returns companies marked as used ordered by shortcut.
+	CompaniesInUse() ([]*company.Company, error)
+	// CompanyWithID returns ErrNotFound if there is no such company.
+	CompanyWithID(id int) (*company.Company, error)
+	// SaveCompany inserts a new company (and sets its id) with its default
+	// project or updates the existing one.
+	// Fails with ErrShortcutExists or ErrNameExists, and with
+	// ErrCurrencyInUse if the currency of the company with rates or timers
+	// is changed (their amounts are in that currency).
+	SaveCompany(c *company.Company) error
+	// RemoveCompany removes the company with its projects and rates.
+	// Fails with ErrCompanyInUse if the company has timers or invoices.
+	RemoveCompany(c *company.Company) error
+	// SubscribeCompanies calls fn after companies were changed.
+	// Returned function cancels the subscription.
+	SubscribeCompanies(fn func()) func()
+}
+
+// ProjectRepository keeps projects of companies.
+type ProjectRepository interface {
+	// Projects returns all projects of the company ordered by code.
+	Projects(companyID int) ([]*project.Project, error)
+	// ActiveProjects returns active projects of the company ordered by code.
+	ActiveProjects(companyID int) ([]*project.Project, error)
+	// ProjectWithID returns ErrNotFound if there is no such project.
+	ProjectWithID(id int) (*project.Project, error)
+	// SaveProject inserts a new project (and sets its id) or updates
+	// the existing one. Codes and names are unique in the company,
+	// fails with ErrCodeExists or ErrProjectExists (and with ErrNotFound
+	// if there is no such company). Fails with ErrProjectInUse if the project
+	// with timers is moved to other company (timers stay with their company).
+	SaveProject(p *project.Project) error
+	// RemoveProject removes the project with its rates.
+	// Fails with ErrProjectInUse if the project has timers.
+	RemoveProject(p *project.Project) error
+	// SubscribeProjects calls fn after projects were changed.
+	// Returned function cancels the subscription.
+	SubscribeProjects(fn func()) func()
+}
+
+// RateRepository keeps hourly rates of companies and projects.
+type RateRepository interface {
+	// Rates returns rates of the company, rates of the whole company
+	// first, ordered by start.
+	Rates(companyID int) ([]*rate.Rate, error)
+	// SaveRate inserts a new rate (and sets its id) or updates the existing one.
+	// Fails with ErrNotFound if there is no such company or project
+	// and with ErrWrongProject if the project is not of the company.
+	SaveRate(r *rate.Rate) error
+	RemoveRate(r *rate.Rate) error
+	// SubscribeRates calls fn after rates were changed.
+	// Returned function cancels the subscription.
+	SubscribeRates(fn func()) func()
+}
+
+// TimerEntry is a timer with names of its company and project
+// and the rate effective at its start.
+type TimerEntry struct {
+	ID          int64     `db:"id"`
+	CompanyID   int64     `db:"company_id"`
+	CompanyName string    `db:"company_name"`
+	ProjectID   int64     `db:"project_id"`
+	ProjectName string    `db:"project_name"`
+	Start       time.Time `db:"start"`
+	Finish      time.Time `db:"finish"`
+	Description string    `db:"description"`
+	// Tags are names of the tags separated by comma.
+	Tags string `db:"tags"`
+	// Currency is the currency of the company,
+	// CentsPerHour is 0 if no rate was effective.
+	Currency     string `db:"currency"`
+	CentsPerHour int64  `db:"cents_per_hour"`
+	// InvoiceID is 0 if the timer is not invoiced yet.
+	InvoiceID int64 `db:"invoice_id"`
+}
+
+func (e TimerEntry) Duration() time.Duration {
+	return e.Finish.Sub(e.Start)
+}
+
+// Amount returns the billable amount of the timer (in cents).
+func (e TimerEntry) Amount() int64 {
+	return rate.Amount(e.CentsPerHour, e.Duration())
+}
+
+// InvoiceItem returns the timer as an item of the line named by its project
+// or by its tags (invoice.NoTagsName if it has no tags).
+func (e TimerEntry) InvoiceItem(group invoice.Group) invoice.Item {
+	name := e.ProjectName
+	if group == invoice.ByTag {
+		name = e.Tags
+		if name == "" {
+			name = invoice.NoTagsName
+		}
+	}
+	return invoice.Item{TimerID: e.ID, Name: name, Seconds: int64(e.Duration() / time.Second), CentsPerHour: e.CentsPerHour}
+}
+
+// TimerRepository keeps the working times.
+type TimerRepository interface {
+	// SaveTimer inserts a new timer (and sets its id) or updates the existing one.
+	// Timer without a project is saved in the default project of its company
+	// (the oldest one, created again if the company has no projects).
+	// Fails with ErrNotFound if the company or the project doesn't exist,
+	// with ErrWrongProject if the project is not of the timer company
+	// and with ErrInvoiced if anything but the description of the invoiced
+	// timer is changed.
+	SaveTimer(tm *timer.Timer) error
+	// RemoveTimer fails with ErrInvoiced if the timer is invoiced.
+	RemoveTimer(tm *timer.Timer) error
+	// TimerWithID returns ErrNotFound if there is no such timer.
+	TimerWithID(id int64) (*timer.Timer, error)
+	// Descriptions returns distinct descriptions of timers of the company,
+	// the most recently used first.
+	Descriptions(companyID int) ([]string, error)
+	// TimerEntries calls fn for timers selected by the filter,
+	// the newest first. Returns ctx.Err() when ctx is done before the end.
+	TimerEntries(ctx context.Context, filter EntryFilter, fn func(TimerEntry)) error
+	// SubscribeTimers calls fn after timers (or their tags) were changed.
+	// Returned function cancels the subscription.
+	SubscribeTimers(fn func()) func()
+}
+
+// TagTotal is the working time of timers with the tag.
+type TagTotal struct {
+	TagID   int64  `db:"id"`
+	Name    string `db:"name"`
+	Seconds int64  `db:"seconds"`
+}
+
+func (t TagTotal) Duration() time.Duration {
+	return time.Duration(t.Seconds) * time.Second
+}
+
+// TagRepository keeps tags and tags given to timers.
+type TagRepository interface {
+	// Tags returns all tags ordered by name.
+	Tags() ([]*tag.Tag, error)
+	// SaveTag inserts a new tag (and sets its id) or updates
+	// the existing one. Fails with ErrTagExists.
+	SaveTag(t *tag.Tag) error
+	// RemoveTag removes the tag from all timers too.
+	RemoveTag(t *tag.Tag) error
+	// TimerTags returns tags of the timer ordered by name.
+	TimerTags(timerID int64) ([]*tag.Tag, error)
+	// SetTimerTags replaces tags of the timer.
+	// Fails with ErrNotFound if the timer or any of tags doesn't exist.
+	SetTimerTags(timerID int64, tagIDs []int64) error
+	// SaveTimerWithTags saves the timer (as SaveTimer) and replaces its tags
+	// in one transaction, nothing is saved if any of them fails.
+	SaveTimerWithTags(tm *timer.Timer, tagIDs []int64) error
+	// TagTotals returns working time per tag of timers selected
+	// by the filter, the longest first. With the tag in the filter
+	// only the total of that tag is returned.
+	TagTotals(ctx context.Context, filter EntryFilter) ([]TagTotal, error)
+	// SubscribeTags calls fn after tags were changed.
+	// Returned function cancels the subscription.
+	SubscribeTags(fn func()) func()
+}
+
+// InvoiceRepository keeps issued invoices, they are never changed.
+type InvoiceRepository interface {
+	// Invoices returns invoices of the year ordered by number.
+	Invoices(year int) ([]*invoice.Invoice, error)
+	// InvoiceWithID returns ErrNotFound if there is no such invoice.
+	InvoiceWithID(id int64) (*invoice.Invoice, error)
+	// SaveInvoice inserts the new invoice with the next number of its year
+	// (and sets its id and sequence) and marks its timers as invoiced.
+	// Fails with ErrNotFound if any of timers doesn't exist or is of another
+	// company and with ErrInvoiced if the invoice or any of timers is already
+	// invoiced (nothing is saved then).
+	SaveInvoice(inv *invoice.Invoice) error
+	// SubscribeInvoices calls fn after invoices were changed.
+	// Returned function cancels the subscription.
+	SubscribeInvoices(fn func()) func()
+}
+
+// Repositories is everything the application keeps.
+type Repositories interface {
+	CompanyRepository
+	ProjectRepository
+	RateRepository
+	TimerRepository
+	TagRepository
+	InvoiceRepository
+}
@@ -0,0 +1,750 @@
+/*
+ * BSD 2-Clause License
+ *
+ *	Copyright (c) 2019, Piotr Pszczółkowski
+ *	All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright notice, this
+ * list of conditions and the following disclaimer.
+ *
+ * 2. Redistributions in binary form must reproduce the above copyright notice,
+ * this list of conditions and the following disclaimer in the documentation
+ * and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+ * AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+ * DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+ * SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+ * CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+ * OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+ * OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"Timelancer/dbf"
+	"Timelancer/model/company"
+	"Timelancer/model/invoice"
+	"Timelancer/model/project"
+	"Timelancer/model/rate"
+	"Timelancer/model/tag"
+	"Timelancer/model/timer"
+	"Timelancer/sqlite"
+)
+
+// Every backend must pass the same tests.
+func backends(t *testing.T) map[string]func(t *testing.T) Repositories {
+	return map[string]func(t *testing.T) Repositories{
+		"sqlite": func(t *testing.T) Repositories {
+			db, err := sqlite.Open(sqlite.Memory, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			if err := dbf.Migrate(db); err != nil {
+				t.Fatal(err)
+			}
+			db.SetDispatcher(func(f func()) { f() })
+			return NewSQLite(db)
+		},
+		"memory": func(t *testing.T) Repositories {
+			return NewMemory()
+		},
+	}
+}
+
+func runContract(t *testing.T, test func(t *testing.T, store Repositories)) {
+	for name, open := range backends(t) {
+		open := open
+		t.Run(name, func(t *testing.T) {
+			test(t, open(t))
+		})
+	}
+}
+
+func newCompany(t *testing.T, store Repositories, shortcut string, used bool) *company.Company {
+	c := company.New()
+	c.SetShortcut(shortcut)
+	c.SetName(shortcut + " company")
+	c.SetUsed(used)
+	assert.Nil(t, store.SaveCompany(c))
+	return c
+}
+
+func shortcuts(data []*company.Company) []string {
+	var result []string
+	for _, c := range data {
+		result = append(result, c.Shortcut())
+	}
+	return result
+}
+
+func entries(t *testing.T, store Repositories, companyID int) []TimerEntry {
+	return filteredEntries(t, store, EntryFilter{CompanyID: companyID})
+}
+
+func filteredEntries(t *testing.T, store Repositories, filter EntryFilter) []TimerEntry {
+	var result []TimerEntry
+	assert.Nil(t, store.TimerEntries(context.Background(), filter, func(entry TimerEntry) {
+		result = append(result, entry)
+	}))
+	return result
+}
+
+func Test_SaveAndReadCompany(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		c := newCompany(t, store, "ACME", true)
+		assert.NotZero(t, c.ID())
+
+		c.SetName("Acme Corporation")
+		assert.Nil(t, store.SaveCompany(c))
+
+		saved, err := store.CompanyWithID(c.ID())
+		if assert.Nil(t, err) {
+			assert.Equal(t, *c, *saved)
+		}
+		_, err = store.CompanyWithID(c.ID() + 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		missing := company.New()
+		missing.SetID(c.ID() + 1)
+		missing.SetShortcut("BEE")
+		missing.SetName("Bee")
+		assert.ErrorIs(t, store.SaveCompany(missing), ErrNotFound)
+	})
+}
+
+func Test_Companies(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		data, err := store.CompaniesInUse()
+		assert.Nil(t, err)
+		assert.Empty(t, data)
+
+		newCompany(t, store, "CTX", true)
+		newCompany(t, store, "bee", false)
+		newCompany(t, store, "ACME", true)
+
+		inUse, err := store.CompaniesInUse()
+		assert.Nil(t, err)
+		all, err := store.Companies()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"ACME", "CTX"}, shortcuts(inUse))
+		assert.Equal(t, []string{"ACME", "bee", "CTX"}, shortcuts(all))
+	})
+}
+
+func Test_SaveDuplicateCompany(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		c := newCompany(t, store, "ACME", true)
+
+		duplicate := company.New()
+		duplicate.SetShortcut("acme")
+		duplicate.SetName("Other")
+		assert.ErrorIs(t, store.SaveCompany(duplicate), ErrShortcutExists)
+		assert.Zero(t, duplicate.ID())
+
+		duplicate.SetShortcut("OTHER")
+		duplicate.SetName(c.Name())
+		assert.ErrorIs(t, store.SaveCompany(duplicate), ErrNameExists)
+
+		// saving the company again is not a duplicate
+		assert.Nil(t, store.SaveCompany(c))
+	})
+}
+
+func Test_RemoveCompany(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		c := newCompany(t, store, "ACME", true)
+		tm := timer.NewWithData(int64(c.ID()), 100, 200)
+		assert.Nil(t, store.SaveTimer(tm))
+
+		assert.ErrorIs(t, store.RemoveCompany(c), ErrCompanyInUse)
+		_, err := store.CompanyWithID(c.ID())
+		assert.Nil(t, err)
+
+		assert.Nil(t, store.RemoveTimer(tm))
+		assert.Nil(t, store.RemoveCompany(c))
+		_, err = store.CompanyWithID(c.ID())
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func Test_SaveAndRemoveTimer(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		acme := newCompany(t, store, "ACME", true)
+		bee := newCompany(t, store, "BEE", true)
+
+		first := timer.NewWithData(int64(acme.ID()), 100, 200)
+		assert.Nil(t, store.SaveTimer(first))
+		assert.NotZero(t, first.ID())
+		assert.True(t, first.Valid())
+		second := timer.NewWithData(int64(bee.ID()), 300, 400)
+		assert.Nil(t, store.SaveTimer(second))
+
+		updated := timer.NewWithData(int64(acme.ID()), 100, 250)
+		updated.SetID(first.ID())
+		assert.Nil(t, store.SaveTimer(updated))
+
+		// the company must exist
+		missing := timer.NewWithData(int64(bee.ID()+1), 100, 200)
+		assert.ErrorIs(t, store.SaveTimer(missing), ErrNotFound)
+		assert.ErrorIs(t, store.SaveProject(project.New(bee.ID()+1)), ErrNotFound)
+
+		assert.Equal(t, []TimerEntry{
+			{ID: second.ID(), CompanyID: int64(bee.ID()), CompanyName: bee.Name(), ProjectID: second.ProjectID(), ProjectName: project.DefaultName, Currency: company.DefaultCurrency, Start: time.Unix(300, 0), Finish: time.Unix(400, 0)},
+			{ID: first.ID(), CompanyID: int64(acme.ID()), CompanyName: acme.Name(), ProjectID: updated.ProjectID(), ProjectName: project.DefaultName, Currency: company.DefaultCurrency, Start: time.Unix(100, 0), Finish: time.Unix(250, 0)},
+		}, entries(t, store, AllCompanies))
+		if data := entries(t, store, acme.ID()); assert.Len(t, data, 1) {
+			assert.Equal(t, first.ID(), data[0].ID)
+		}
+
+		assert.Nil(t, store.RemoveTimer(first))
+		assert.Nil(t, store.RemoveTimer(second))
+		assert.Empty(t, entries(t, store, AllCompanies))
+	})
+}
+
+func codes(data []*project.Project) []string {
+	var result []string
+	for _, p := range data {
+		result = append(result, p.Code())
+	}
+	return result
+}
+
+func Test_Projects(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		acme := newCompany(t, store, "ACME", true)
+		bee := newCompany(t, store, "BEE", true)
+
+		// every new company gets the default project
+		data, err := store.Projects(acme.ID())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{project.DefaultCode}, codes(data))
+
+		web := project.New(acme.ID())
+		web.SetCode("WEB")
+		web.SetName("web shop")
+		web.SetBudgetHours(120)
+		assert.Nil(t, store.SaveProject(web))
+		assert.NotZero(t, web.ID())
+
+		app := project.New(acme.ID())
+		app.SetCode("app")
+		app.SetName("mobile app")
+		app.SetActive(false)
+		assert.Nil(t, store.SaveProject(app))
+
+		saved, err := store.ProjectWithID(web.ID())
+		if assert.Nil(t, err) {
+			assert.Equal(t, *web, *saved)
+			assert.True(t, saved.HasBudget())
+		}
+		_, err = store.ProjectWithID(app.ID() + 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		data, err = store.Projects(acme.ID())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"app", project.DefaultCode, "WEB"}, codes(data))
+		data, err = store.ActiveProjects(acme.ID())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{project.DefaultCode, "WEB"}, codes(data))
+
+		// codes and names are unique in the company only
+		duplicate := project.New(acme.ID())
+		duplicate.SetCode("web")
+		duplicate.SetName("other")
+		assert.ErrorIs(t, store.SaveProject(duplicate), ErrCodeExists)
+		duplicate.SetCode("OTHER")
+		duplicate.SetName("Web Shop")
+		assert.ErrorIs(t, store.SaveProject(duplicate), ErrProjectExists)
+		other := project.New(bee.ID())
+		other.SetCode("WEB")
+		other.SetName("web shop")
+		assert.Nil(t, store.SaveProject(other))
+
+		// a timer without project goes to the default one
+		tm := timer.NewWithData(int64(acme.ID()), 100, 200)
+		assert.Nil(t, store.SaveTimer(tm))
+		if data, err := store.Projects(acme.ID()); assert.Nil(t, err) && assert.Len(t, data, 3) {
+			assert.Equal(t, int64(data[1].ID()), tm.ProjectID())
+		}
+
+		wrong := timer.NewWithData(int64(acme.ID()), 100, 200)
+		wrong.SetProjectID(int64(other.ID()))
+		assert.ErrorIs(t, store.SaveTimer(wrong), ErrWrongProject)
+
+		tm.SetProjectID(int64(web.ID()))
+		assert.Nil(t, store.SaveTimer(tm))
+		result := filteredEntries(t, store, EntryFilter{CompanyID: AllCompanies, ProjectID: web.ID()})
+		if assert.Len(t, result, 1) {
+			assert.Equal(t, "web shop", result[0].ProjectName)
+		}
+		assert.Empty(t, filteredEntries(t, store, EntryFilter{CompanyID: AllCompanies, ProjectID: app.ID()}))
+
+		// a project with timers can't be moved to other company
+		moved := project.New(bee.ID())
+		moved.SetID(web.ID())
+		moved.SetCode("MOVED")
+		moved.SetName("moved")
+		assert.ErrorIs(t, store.SaveProject(moved), ErrProjectInUse)
+		if saved, err := store.ProjectWithID(web.ID()); assert.Nil(t, err) {
+			assert.Equal(t, *web, *saved)
+		}
+		moved.SetID(app.ID())
+		assert.Nil(t, store.SaveProject(moved))
+		if saved, err := store.ProjectWithID(app.ID()); assert.Nil(t, err) {
+			assert.Equal(t, bee.ID(), saved.CompanyID())
+		}
+
+		assert.ErrorIs(t, store.RemoveProject(web), ErrProjectInUse)
+		assert.Nil(t, store.RemoveProject(app))
+		assert.Nil(t, store.RemoveTimer(tm))
+		assert.Nil(t, store.RemoveCompany(acme))
+		_, err = store.ProjectWithID(web.ID())
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func Test_Rates(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		acme := newCompany(t, store, "ACME", true)
+		bee := newCompany(t, store, "BEE", true)
+
+		bee.SetCurrency("PLN")
+		assert.Nil(t, store.SaveCompany(bee))
+		if saved, err := store.CompanyWithID(bee.ID()); assert.Nil(t, err) {
+			assert.Equal(t, "PLN", saved.Currency())
+		}
+
+		web := project.New(acme.ID())
+		web.SetCode("WEB")
+		web.SetName("web shop")
+		assert.Nil(t, store.SaveProject(web))
+
+		base := rate.New(acme.ID())
+		base.SetCentsPerHour(6000)
+		assert.Nil(t, store.SaveRate(base))
+		assert.NotZero(t, base.ID())
+		raise := rate.New(acme.ID())
+		raise.SetCentsPerHour(9000)
+		raise.SetValidFrom(time.Unix(2000, 0))
+		assert.Nil(t, store.SaveRate(raise))
+		special := rate.New(acme.ID())
+		special.SetProjectID(web.ID())
+		special.SetCentsPerHour(12000)
+		assert.Nil(t, store.SaveRate(special))
+
+		wrong := rate.New(acme.ID())
+		other, err := store.Projects(bee.ID())
+		if assert.Nil(t, err) && assert.Len(t, other, 1) {
+			wrong.SetProjectID(other[0].ID())
+			assert.ErrorIs(t, store.SaveRate(wrong), ErrWrongProject)
+		}
+
+		data, err := store.Rates(acme.ID())
+		assert.Nil(t, err)
+		if assert.Len(t, data, 3) {
+			assert.Equal(t, *base, *data[0])
+			assert.Equal(t, *raise, *data[1])
+			assert.Equal(t, *special, *data[2])
+		}
+
+		// one hour before the raise, half an hour after it, quarter in the project
+		before := timer.NewWithData(int64(acme.ID()), 100, 3700)
+		assert.Nil(t, store.SaveTimer(before))
+		after := timer.NewWithData(int64(acme.ID()), 2000, 3800)
+		assert.Nil(t, store.SaveTimer(after))
+		inProject := timer.NewWithData(int64(acme.ID()), 5000, 5900)
+		inProject.SetProjectID(int64(web.ID()))
+		assert.Nil(t, store.SaveTimer(inProject))
+		unpaid := timer.NewWithData(int64(bee.ID()), 100, 3700)
+		assert.Nil(t, store.SaveTimer(unpaid))
+
+		amounts := make(map[int64]int64)
+		for _, entry := range filteredEntries(t, store, EntryFilter{CompanyID: AllCompanies}) {
+			amounts[entry.ID] = entry.Amount()
+			if entry.CompanyID == int64(bee.ID()) {
+				assert.Equal(t, "PLN", entry.Currency)
+			} else {
+				assert.Equal(t, company.DefaultCurrency, entry.Currency)
+			}
+		}
+		assert.Equal(t, map[int64]int64{before.ID(): 6000, after.ID(): 4500, inProject.ID(): 3000, unpaid.ID(): 0}, amounts)
+
+		// amounts are in the currency of the company, it can't be changed now
+		acme.SetCurrency("USD")
+		assert.ErrorIs(t, store.SaveCompany(acme), ErrCurrencyInUse)
+		bee.SetCurrency("USD")
+		assert.ErrorIs(t, store.SaveCompany(bee), ErrCurrencyInUse)
+		acme.SetCurrency(company.DefaultCurrency)
+		acme.SetAddress("Main Street 1")
+		assert.Nil(t, store.SaveCompany(acme))
+
+		assert.Nil(t, store.RemoveRate(raise))
+		if data, err := store.Rates(acme.ID()); assert.Nil(t, err) {
+			assert.Len(t, data, 2)
+		}
+		assert.Nil(t, store.RemoveTimer(inProject))
+		assert.Nil(t, store.RemoveProject(web))
+		if data, err := store.Rates(acme.ID()); assert.Nil(t, err) && assert.Len(t, data, 1) {
+			assert.Equal(t, base.ID(), data[0].ID())
+		}
+	})
+}
+
+func invoiceItems(data []TimerEntry, group invoice.Group) []invoice.Item {
+	var items []invoice.Item
+	for _, entry := range data {
+		items = append(items, entry.InvoiceItem(group))
+	}
+	return items
+}
+
+func numbers(data []*invoice.Invoice) []string {
+	var result []string
+	for _, inv := range data {
+		result = append(result, inv.Number())
+	}
+	return result
+}
+
+func Test_Invoices(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		seller := newCompany(t, store, "ME", false)
+		seller.SetAddress("Main Street 1")
+		seller.SetTaxID("PL1234567890")
+		assert.Nil(t, store.SaveCompany(seller))
+		acme := newCompany(t, store, "ACME", true)
+		acme.SetCurrency("PLN")
+		acme.SetVATPercent(23)
+		assert.Nil(t, store.SaveCompany(acme))
+		bee := newCompany(t, store, "BEE", true)
+
+		r := rate.New(acme.ID())
+		r.SetCentsPerHour(10000)
+		assert.Nil(t, store.SaveRate(r))
+		first := timer.NewWithData(int64(acme.ID()), 0, 3600)
+		assert.Nil(t, store.SaveTimer(first))
+		second := timer.NewWithData(int64(acme.ID()), 3600, 5400)
+		assert.Nil(t, store.SaveTimer(second))
+		other := timer.NewWithData(int64(bee.ID()), 0, 1800)
+		assert.Nil(t, store.SaveTimer(other))
+
+		notInvoiced := EntryFilter{CompanyID: acme.ID(), NotInvoiced: true}
+		data := filteredEntries(t, store, notInvoiced)
+		assert.Len(t, data, 2)
+		assert.Equal(t, invoice.NoTagsName, data[0].InvoiceItem(invoice.ByTag).Name)
+
+		issued := time.Date(2024, time.March, 31, 12, 0, 0, 0, time.Local)
+		inv := invoice.New(seller, acme, issued, invoiceItems(data, invoice.ByProject))
+		assert.Nil(t, store.SaveInvoice(inv))
+		assert.NotZero(t, inv.ID())
+		assert.Equal(t, "2024/0001", inv.Number())
+		assert.Equal(t, int64(18450), inv.Gross())
+
+		// timers can't be billed twice
+		again := invoice.New(seller, acme, issued, invoiceItems(data, invoice.ByProject))
+		assert.ErrorIs(t, store.SaveInvoice(again), ErrInvoiced)
+		assert.Equal(t, "", again.Number())
+		assert.ErrorIs(t, store.SaveInvoice(inv), ErrInvoiced)
+		assert.Empty(t, filteredEntries(t, store, notInvoiced))
+		for _, entry := range entries(t, store, acme.ID()) {
+			assert.Equal(t, inv.ID(), entry.InvoiceID)
+		}
+
+		// timers of other companies are not billed
+		wrong := invoice.New(seller, acme, issued, invoiceItems(entries(t, store, bee.ID()), invoice.ByTag))
+		assert.ErrorIs(t, store.SaveInvoice(wrong), ErrNotFound)
+		if data := entries(t, store, bee.ID()); assert.Len(t, data, 1) {
+			assert.Zero(t, data[0].InvoiceID)
+		}
+
+		later := invoice.New(seller, bee, issued, invoiceItems(entries(t, store, bee.ID()), invoice.ByTag))
+		assert.Nil(t, store.SaveInvoice(later))
+		assert.Equal(t, "2024/0002", later.Number())
+		nextYear := invoice.New(seller, bee, issued.AddDate(1, 0, 0), nil)
+		assert.Nil(t, store.SaveInvoice(nextYear))
+		assert.Equal(t, "2025/0001", nextYear.Number())
+
+		list, err := store.Invoices(2024)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"2024/0001", "2024/0002"}, numbers(list))
+		saved, err := store.InvoiceWithID(inv.ID())
+		if assert.Nil(t, err) {
+			assert.Equal(t, inv.Lines(), saved.Lines())
+			assert.Equal(t, []int64{first.ID(), second.ID()}, saved.TimerIDs())
+			assert.Equal(t, invoice.Party{Name: "ME company", Address: "Main Street 1", TaxID: "PL1234567890"}, saved.Seller())
+			assert.Equal(t, "PLN", saved.Currency())
+			assert.Equal(t, 23.0, saved.VATPercent())
+			assert.Equal(t, inv.Issued(), saved.Issued())
+		}
+		_, err = store.InvoiceWithID(nextYear.ID() + 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		assert.ErrorIs(t, store.RemoveCompany(acme), ErrCompanyInUse)
+	})
+}
+
+func Test_InvoicedTimers(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		seller := newCompany(t, store, "ME", false)
+		acme := newCompany(t, store, "ACME", true)
+		web := project.New(acme.ID())
+		web.SetCode("WEB")
+		web.SetName("web shop")
+		assert.Nil(t, store.SaveProject(web))
+
+		tm := timer.NewWithData(int64(acme.ID()), 0, 3600)
+		assert.Nil(t, store.SaveTimer(tm))
+		inv := invoice.New(seller, acme, time.Now(), invoiceItems(entries(t, store, acme.ID()), invoice.ByProject))
+		assert.Nil(t, store.SaveInvoice(inv))
+
+		// the description may be changed, the work itself not
+		tm.SetDescription("design")
+		assert.Nil(t, store.SaveTimer(tm))
+
+		moved := timer.NewWithData(int64(acme.ID()), 0, 7200)
+		moved.SetID(tm.ID())
+		moved.SetProjectID(tm.ProjectID())
+		assert.ErrorIs(t, store.SaveTimer(moved), ErrInvoiced)
+
+		other := *tm
+		other.SetProjectID(int64(web.ID()))
+		assert.ErrorIs(t, store.SaveTimer(&other), ErrInvoiced)
+
+		assert.ErrorIs(t, store.RemoveTimer(tm), ErrInvoiced)
+		if data := entries(t, store, acme.ID()); assert.Len(t, data, 1) {
+			assert.Equal(t, inv.ID(), data[0].InvoiceID)
+			assert.Equal(t, "design", data[0].Description)
+			assert.Equal(t, time.Hour, data[0].Duration())
+			assert.Equal(t, project.DefaultName, data[0].ProjectName)
+		}
+		saved, err := store.InvoiceWithID(inv.ID())
+		if assert.Nil(t, err) {
+			assert.Equal(t, []int64{tm.ID()}, saved.TimerIDs())
+		}
+	})
+}
+
+func Test_TimerDescriptions(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		acme := newCompany(t, store, "ACME", true)
+		bee := newCompany(t, store, "BEE", true)
+
+		for i, text := range []string{"design", "", "review", "design", "bugfix"} {
+			tm := timer.NewWithData(int64(acme.ID()), int64(i*100+100), int64(i*100+150))
+			tm.SetDescription(text)
+			assert.Nil(t, store.SaveTimer(tm))
+		}
+		other := timer.NewWithData(int64(bee.ID()), 100, 200)
+		other.SetDescription("meeting")
+		assert.Nil(t, store.SaveTimer(other))
+
+		data, err := store.Descriptions(acme.ID())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"bugfix", "design", "review"}, data)
+
+		saved, err := store.TimerWithID(other.ID())
+		if assert.Nil(t, err) {
+			assert.Equal(t, *other, *saved)
+		}
+		_, err = store.TimerWithID(other.ID() + 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// the description can be changed later
+		saved.SetDescription("planning")
+		assert.Nil(t, store.SaveTimer(saved))
+		if data := entries(t, store, bee.ID()); assert.Len(t, data, 1) {
+			assert.Equal(t, "planning", data[0].Description)
+		}
+	})
+}
+
+func newTag(t *testing.T, store Repositories, name string) *tag.Tag {
+	tg := tag.New(name)
+	assert.Nil(t, store.SaveTag(tg))
+	return tg
+}
+
+func tagNames(data []*tag.Tag) []string {
+	var result []string
+	for _, tg := range data {
+		result = append(result, tg.Name())
+	}
+	return result
+}
+
+func Test_Tags(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		meeting := newTag(t, store, "meeting")
+		bugfix := newTag(t, store, "bugfix")
+		travel := newTag(t, store, "Travel")
+		assert.ErrorIs(t, store.SaveTag(tag.New("Meeting")), ErrTagExists)
+
+		data, err := store.Tags()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"bugfix", "meeting", "Travel"}, tagNames(data))
+
+		c := newCompany(t, store, "ACME", true)
+		tm := timer.NewWithData(int64(c.ID()), 100, 200)
+		assert.Nil(t, store.SaveTimer(tm))
+		assert.Nil(t, store.SetTimerTags(tm.ID(), []int64{travel.ID(), meeting.ID()}))
+		assert.ErrorIs(t, store.SetTimerTags(tm.ID()+1, []int64{meeting.ID()}), ErrNotFound)
+		assert.ErrorIs(t, store.SetTimerTags(tm.ID(), []int64{travel.ID() + 1}), ErrNotFound)
+
+		data, err = store.TimerTags(tm.ID())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"meeting", "Travel"}, tagNames(data))
+		if result := entries(t, store, AllCompanies); assert.Len(t, result, 1) {
+			assert.Equal(t, "meeting, Travel", result[0].Tags)
+		}
+
+		// tags are replaced, not added
+		assert.Nil(t, store.SetTimerTags(tm.ID(), []int64{bugfix.ID()}))
+		data, err = store.TimerTags(tm.ID())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"bugfix"}, tagNames(data))
+
+		assert.Nil(t, store.RemoveTag(bugfix))
+		data, err = store.TimerTags(tm.ID())
+		assert.Nil(t, err)
+		assert.Empty(t, data)
+
+		assert.Nil(t, store.SetTimerTags(tm.ID(), []int64{meeting.ID()}))
+		assert.Nil(t, store.RemoveTimer(tm))
+		data, err = store.TimerTags(tm.ID())
+		assert.Nil(t, err)
+		assert.Empty(t, data)
+
+		// the timer is not saved without its tags
+		other := timer.NewWithData(int64(c.ID()), 300, 400)
+		assert.ErrorIs(t, store.SaveTimerWithTags(other, []int64{meeting.ID(), bugfix.ID()}), ErrNotFound)
+		assert.Zero(t, other.ID())
+		assert.Empty(t, entries(t, store, AllCompanies))
+		assert.Nil(t, store.SaveTimerWithTags(other, []int64{meeting.ID()}))
+		if result := entries(t, store, AllCompanies); assert.Len(t, result, 1) {
+			assert.Equal(t, other.ID(), result[0].ID)
+			assert.Equal(t, "meeting", result[0].Tags)
+		}
+	})
+}
+
+func Test_TagFilterAndTotals(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		meeting := newTag(t, store, "meeting")
+		travel := newTag(t, store, "travel")
+		newTag(t, store, "bugfix")
+		acme := newCompany(t, store, "ACME", true)
+		bee := newCompany(t, store, "BEE", true)
+
+		newTimer := func(c *company.Company, seconds int64, tags ...int64) *timer.Timer {
+			tm := timer.NewWithData(int64(c.ID()), 1000, 1000+seconds)
+			assert.Nil(t, store.SaveTimer(tm))
+			assert.Nil(t, store.SetTimerTags(tm.ID(), tags))
+			return tm
+		}
+		first := newTimer(acme, 3600, meeting.ID())
+		second := newTimer(acme, 1800, meeting.ID(), travel.ID())
+		third := newTimer(bee, 7200, travel.ID())
+		newTimer(bee, 600)
+
+		ids := func(data []TimerEntry) []int64 {
+			var result []int64
+			for _, entry := range data {
+				result = append(result, entry.ID)
+			}
+			return result
+		}
+		assert.Equal(t, []int64{second.ID(), first.ID()}, ids(filteredEntries(t, store, EntryFilter{CompanyID: AllCompanies, TagID: meeting.ID()})))
+		assert.Equal(t, []int64{third.ID()}, ids(filteredEntries(t, store, EntryFilter{CompanyID: bee.ID(), TagID: travel.ID()})))
+
+		totals, err := store.TagTotals(context.Background(), EntryFilter{CompanyID: AllCompanies})
+		assert.Nil(t, err)
+		assert.Equal(t, []TagTotal{
+			{TagID: travel.ID(), Name: "travel", Seconds: 9000},
+			{TagID: meeting.ID(), Name: "meeting", Seconds: 5400},
+		}, totals)
+		if assert.Len(t, totals, 2) {
+			assert.Equal(t, 150*time.Minute, totals[0].Duration())
+		}
+
+		totals, err = store.TagTotals(context.Background(), EntryFilter{CompanyID: acme.ID()})
+		assert.Nil(t, err)
+		assert.Equal(t, []TagTotal{
+			{TagID: meeting.ID(), Name: "meeting", Seconds: 5400},
+			{TagID: travel.ID(), Name: "travel", Seconds: 1800},
+		}, totals)
+
+		totals, err = store.TagTotals(context.Background(), EntryFilter{CompanyID: AllCompanies, TagID: meeting.ID()})
+		assert.Nil(t, err)
+		assert.Equal(t, []TagTotal{{TagID: meeting.ID(), Name: "meeting", Seconds: 5400}}, totals)
+	})
+}
+
+func Test_TimerEntriesCancelled(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		c := newCompany(t, store, "ACME", true)
+		assert.Nil(t, store.SaveTimer(timer.NewWithData(int64(c.ID()), 100, 200)))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		called := false
+		err := store.TimerEntries(ctx, EntryFilter{CompanyID: AllCompanies}, func(TimerEntry) { called = true })
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, called)
+
+		_, err = store.TagTotals(ctx, EntryFilter{CompanyID: AllCompanies})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func Test_Subscriptions(t *testing.T) {
+	runContract(t, func(t *testing.T, store Repositories) {
+		var companies, timers int
+		unsubscribe := store.SubscribeCompanies(func() { companies++ })
+		store.SubscribeTimers(func() { timers++ })
+
+		c := newCompany(t, store, "ACME", true)
+		assert.Equal(t, 1, companies)
+		assert.Equal(t, 0, timers)
+
+		assert.Nil(t, store.SaveTimer(timer.NewWithData(int64(c.ID()), 100, 200)))
+		assert.Equal(t, 1, companies)
+		assert.Equal(t, 1, timers)
+
+		var tags int
+		store.SubscribeTags(func() { tags++ })
+		tg := newTag(t, store, "meeting")
+		assert.Equal(t, 1, tags)
+		timers = 0
+		assert.Nil(t, store.SetTimerTags(1, []int64{tg.ID()}))
+		assert.Equal(t, 1, tags)
+		assert.Equal(t, 1, timers)
+
+		var invoices int
+		store.SubscribeInvoices(func() { invoices++ })
+		timers = 0
+		items := []invoice.Item{{TimerID: 1, Name: "work", Seconds: 100}}
+		assert.Nil(t, store.SaveInvoice(invoice.New(c, c, time.Now(), items)))
+		assert.Equal(t, 1, invoices)
+		assert.Equal(t, 1, timers)
+
+		unsubscribe()
+		newCompany(t, store, "BEE", true)
+		assert.Equal(t, 1, companies)
+	})
+}
@@ -30,18 +30,55 @@ package window
 
 import (
 	"Timelancer/model/company"
+	"Timelancer/model/project"
+	"Timelancer/shared/tr"
 )
 
 var companiesData []*company.Company
 
+// projectsData are active projects of the selected company.
+var projectsData []*project.Project
+
 func (mw *MainWindow) populateCompanyCombo() {
 	mw.companyCombo.RemoveAll()
 	mw.companyCombo.AppendText("Select a company")
 	mw.companyCombo.SetActive(0)
 
-	companiesData = company.CompaniesInUse()
-	for _, c := range companiesData {
-		mw.companyCombo.AppendText(c.Name())
+	var err error
+	if companiesData, err = mw.store.CompaniesInUse(); tr.IsOK(err) {
+		for _, c := range companiesData {
+			mw.companyCombo.AppendText(c.Name())
+		}
+	}
+}
+
+// companiesChanged refreshes the combo when companies were changed
+// (e.g. in the companies dialog), the selection is kept if possible.
+// While the timer runs the combo is left untouched (it is disabled and its
+// selection tells where the timer will be saved), the refresh is postponed
+// until the timer is stopped.
+func (mw *MainWindow) companiesChanged() {
+	if mw.workTimeRunned {
+		mw.companiesOutdated = true
+		return
+	}
+	mw.companiesOutdated = false
+
+	id := mw.selectedCompanyID()
+	mw.populateCompanyCombo()
+	if id != -1 {
+		mw.selectCompanyWithID(id)
+	}
+}
+
+// refreshOutdatedCombos applies changes postponed while the timer was running.
+func (mw *MainWindow) refreshOutdatedCombos() {
+	if mw.companiesOutdated {
+		mw.companiesChanged()
+		mw.projectsOutdated = false // the project combo was rebuilt as well
+	}
+	if mw.projectsOutdated {
+		mw.projectsChanged()
 	}
 }
 
@@ -67,17 +104,84 @@ func (mw *MainWindow) selectCompanyWithID(id int) bool {
 func (mw *MainWindow) selectedCompanyChanged() {
 	mw.companyIndex = mw.companyCombo.GetActive()
 
+	mw.populateProjectCombo()
+
 	if mw.companyIndex == 0 || mw.companyIndex == -1 {
 		mw.companyLabel.SetSensitive(false)
+		mw.projectLabel.SetSensitive(false)
+		mw.projectCombo.SetSensitive(false)
+		mw.projectAddBtn.SetSensitive(false)
 		mw.timerLabel.SetSensitive(false)
 		mw.timerValue.SetSensitive(false)
 		mw.timerStartBtn.SetSensitive(false)
 		mw.timerStopBtn.SetSensitive(false)
 	} else {
 		mw.companyLabel.SetSensitive(true)
+		mw.projectLabel.SetSensitive(true)
+		mw.projectCombo.SetSensitive(true)
+		mw.projectAddBtn.SetSensitive(true)
 		mw.timerLabel.SetSensitive(true)
 		mw.timerValue.SetSensitive(true)
 		mw.timerStartBtn.SetSensitive(true)
 		mw.timerStopBtn.SetSensitive(false)
 	}
 }
+
+// populateProjectCombo fills the combo with active projects of the selected
+// company, the first one (the default project usually) is selected.
+func (mw *MainWindow) populateProjectCombo() {
+	mw.projectCombo.RemoveAll()
+	projectsData = nil
+
+	if id := mw.selectedCompanyID(); id != -1 {
+		var err error
+		if projectsData, err = mw.store.ActiveProjects(id); tr.IsOK(err) {
+			defaultIndex := 0
+			for index, p := range projectsData {
+				mw.projectCombo.AppendText(p.Name())
+				if p.Code() == project.DefaultCode {
+					defaultIndex = index
+				}
+			}
+			if len(projectsData) > 0 {
+				mw.projectCombo.SetActive(defaultIndex)
+			}
+		}
+	}
+}
+
+// projectsChanged refreshes the combo when projects were changed
+// (e.g. in the projects dialog), the selection is kept if possible.
+// As with companies the refresh is postponed while the timer runs.
+func (mw *MainWindow) projectsChanged() {
+	if mw.workTimeRunned {
+		mw.projectsOutdated = true
+		return
+	}
+	mw.projectsOutdated = false
+
+	id := mw.selectedProjectID()
+	mw.populateProjectCombo()
+	if id != 0 {
+		mw.selectProjectWithID(id)
+	}
+}
+
+// selectedProjectID returns 0 if there is no project selected
+// (the timer is saved in the default project then).
+func (mw *MainWindow) selectedProjectID() int {
+	if row := mw.projectCombo.GetActive(); row > -1 && row < len(projectsData) {
+		return projectsData[row].ID()
+	}
+	return 0
+}
+
+func (mw *MainWindow) selectProjectWithID(id int) bool {
+	for index, p := range projectsData {
+		if p.ID() == id {
+			mw.projectCombo.SetActive(index)
+			return true
+		}
+	}
+	return false
+}
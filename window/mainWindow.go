@@ -37,11 +37,14 @@ import (
 	"Timelancer/dialog/alarm"
 	"Timelancer/dialog/companies"
 	"Timelancer/dialog/company"
+	"Timelancer/dialog/project"
 	"Timelancer/dialog/statistic"
+	"Timelancer/dialog/worktime"
 	"Timelancer/model/timer"
 	"Timelancer/shared"
 	"Timelancer/shared/tr"
 	"Timelancer/sound"
+	"Timelancer/storage"
 	"github.com/gotk3/gotk3/glib"
 	"github.com/gotk3/gotk3/gtk"
 )
@@ -82,23 +85,20 @@ OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.`
 
 	alarmAfterActiveFormat   = "<span font_desc='18' foreground='#AAA555'>%02d:%02d:%02d</span>"
 	alarmAfterInactiveFormat = "<span font_desc='18' foreground='#999999'>%02d:%02d:%02d</span>"
-
-	// you worked %dh%2dmin.\nWould you like to save this information to database?
-
-	workedTimeFormat = "<span font_desc='12' foreground='#BBBBBBBB'>you worked </span>" +
-		"<span font_desc='16' foreground='#CCC777'> %d</span><span font_desc='10' foreground='#BBBBBBBB'>h </span>" +
-		"<span font_desc='16' foreground='#CCC777'>%02d</span><span font_desc='10' foreground='#BBBBBBBB'>min </span>" +
-		"<span font_desc='12' foreground='#BBBBBBBB'>\nwould you like to save this information to database?</span>"
 )
 
 type MainWindow struct {
 	app                *gtk.Application
+	store              storage.Repositories
 	win                *gtk.ApplicationWindow
 	timeLabel          *gtk.Label
 	headerBar          *gtk.HeaderBar
 	companyLabel       *gtk.Label
 	companyCombo       *gtk.ComboBoxText
 	companyAddBtn      *gtk.Button
+	projectLabel       *gtk.Label
+	projectCombo       *gtk.ComboBoxText
+	projectAddBtn      *gtk.Button
 	timerLabel         *gtk.Label
 	timerValue         *gtk.Label
 	timerStartBtn      *gtk.Button
@@ -115,11 +115,14 @@ type MainWindow struct {
 	alarmAtStopBtn     *gtk.Button
 
 	wg     sync.WaitGroup
+	ctx    context.Context
 	cancel context.CancelFunc
 
 	lastTime              time.Time
 	workTimeStart         time.Time
 	workTimeRunned        bool
+	companiesOutdated     bool
+	projectsOutdated      bool
 	alarmAfterDuration    uint
 	alarmAfterDurationPrv uint
 	alarmAfterRunned      bool
@@ -128,11 +131,12 @@ type MainWindow struct {
 	companyIndex          int
 }
 
-func New(app *gtk.Application) *MainWindow {
+func New(app *gtk.Application, store storage.Repositories) *MainWindow {
 	if win, err := gtk.ApplicationWindowNew(app); tr.IsOK(err) {
-		mw := &MainWindow{app: app, win: win}
+		mw := &MainWindow{app: app, store: store, win: win}
 		if mw.setupHeaderBar() && mw.setupMenu() && mw.setupContent() {
 			ctx, cancel := context.WithCancel(context.Background())
+			mw.ctx = ctx
 			mw.cancel = cancel
 			ticker := time.NewTicker(1 * time.Second)
 
@@ -143,6 +147,8 @@ func New(app *gtk.Application) *MainWindow {
 			mw.selectedCompanyChanged()
 
 			mw.companyCombo.Connect("changed", mw.selectedCompanyChanged)
+			mw.store.SubscribeCompanies(mw.companiesChanged)
+			mw.store.SubscribeProjects(mw.projectsChanged)
 
 			mw.wg.Add(1)
 			go mw.timeHandler(ctx, &mw.wg, ticker)
@@ -227,11 +233,13 @@ func (mw *MainWindow) setupContent() bool {
 		grid.SetColumnSpacing(8)
 
 		if mw.createCompanyWidgets(grid) {
-			if mw.createTimerWidgets(grid) {
-				if mw.createAlarmAfterWidgets(grid) {
-					if mw.createAlarmAtWidgets(grid) {
-						mw.win.Container.Add(grid)
-						return true
+			if mw.createProjectWidgets(grid) {
+				if mw.createTimerWidgets(grid) {
+					if mw.createAlarmAfterWidgets(grid) {
+						if mw.createAlarmAtWidgets(grid) {
+							mw.win.Container.Add(grid)
+							return true
+						}
 					}
 				}
 			}
@@ -263,6 +271,27 @@ func (mw *MainWindow) createCompanyWidgets(grid *gtk.Grid) bool {
 	return false
 }
 
+func (mw *MainWindow) createProjectWidgets(grid *gtk.Grid) bool {
+	var err error
+
+	if mw.projectLabel, err = gtk.LabelNew("Project:"); tr.IsOK(err) {
+		if mw.projectCombo, err = gtk.ComboBoxTextNew(); tr.IsOK(err) {
+			if mw.projectAddBtn, err = gtk.ButtonNewWithLabel("Add"); tr.IsOK(err) {
+				mw.projectLabel.SetHAlign(gtk.ALIGN_END)
+				mw.projectAddBtn.SetTooltipText("Add new project of the company")
+
+				mw.projectAddBtn.Connect("clicked", mw.addProjectHandler)
+
+				grid.Attach(mw.projectLabel, 0, 1, 1, 1)
+				grid.Attach(mw.projectCombo, 1, 1, 3, 1)
+				grid.Attach(mw.projectAddBtn, 4, 1, 1, 1)
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (mw *MainWindow) createTimerWidgets(grid *gtk.Grid) bool {
 	var err error
 
@@ -280,6 +309,8 @@ func (mw *MainWindow) createTimerWidgets(grid *gtk.Grid) bool {
 					mw.timerStartBtn.Connect("clicked", func() {
 						mw.companyCombo.SetSensitive(false)
 						mw.companyAddBtn.SetSensitive(false)
+						mw.projectCombo.SetSensitive(false)
+						mw.projectAddBtn.SetSensitive(false)
 						mw.timerLabel.SetSensitive(true)
 						mw.timerStopBtn.SetSensitive(true)
 						mw.timerStartBtn.SetSensitive(false)
@@ -292,16 +323,19 @@ func (mw *MainWindow) createTimerWidgets(grid *gtk.Grid) bool {
 
 						mw.companyCombo.SetSensitive(true)
 						mw.companyAddBtn.SetSensitive(true)
+						mw.projectCombo.SetSensitive(true)
+						mw.projectAddBtn.SetSensitive(true)
 						mw.timerLabel.SetSensitive(false)
 						mw.timerStopBtn.SetSensitive(false)
 						mw.timerStartBtn.SetSensitive(true)
 						mw.updateWorkTime(uint(0))
+						mw.refreshOutdatedCombos()
 					})
 
-					grid.Attach(mw.timerLabel, 0, 1, 1, 1)
-					grid.Attach(mw.timerValue, 1, 1, 1, 1)
-					grid.Attach(mw.timerStartBtn, 2, 1, 1, 1)
-					grid.Attach(mw.timerStopBtn, 3, 1, 1, 1)
+					grid.Attach(mw.timerLabel, 0, 2, 1, 1)
+					grid.Attach(mw.timerValue, 1, 2, 1, 1)
+					grid.Attach(mw.timerStartBtn, 2, 2, 1, 1)
+					grid.Attach(mw.timerStopBtn, 3, 2, 1, 1)
 
 					return true
 				}
@@ -334,13 +368,13 @@ func (mw *MainWindow) createAlarmAfterWidgets(grid *gtk.Grid) bool {
 						mw.alarmAfterStopBtn.Connect("clicked", mw.alarmAfterStopHandler)
 
 						separator, _ := gtk.SeparatorNew(gtk.ORIENTATION_HORIZONTAL)
-						grid.Attach(separator, 0, 2, 5, 1)
+						grid.Attach(separator, 0, 3, 5, 1)
 
-						grid.Attach(mw.alarmAfterLabel, 0, 3, 1, 1)
-						grid.Attach(mw.alarmAfterValue, 1, 3, 1, 1)
-						grid.Attach(mw.alarmAfterStartBtn, 2, 3, 1, 1)
-						grid.Attach(mw.alarmAfterSetBtn, 3, 3, 1, 1)
-						grid.Attach(mw.alarmAfterStopBtn, 4, 3, 1, 1)
+						grid.Attach(mw.alarmAfterLabel, 0, 4, 1, 1)
+						grid.Attach(mw.alarmAfterValue, 1, 4, 1, 1)
+						grid.Attach(mw.alarmAfterStartBtn, 2, 4, 1, 1)
+						grid.Attach(mw.alarmAfterSetBtn, 3, 4, 1, 1)
+						grid.Attach(mw.alarmAfterStopBtn, 4, 4, 1, 1)
 
 						return true
 					}
@@ -373,11 +407,11 @@ func (mw *MainWindow) createAlarmAtWidgets(grid *gtk.Grid) bool {
 						mw.alarmAtStartBtn.Connect("clicked", mw.alarmAtStartHandler)
 						mw.alarmAtStopBtn.Connect("clicked", mw.alarmAtStopHandler)
 
-						grid.Attach(mw.alarmAtLabel, 0, 4, 1, 1)
-						grid.Attach(mw.alarmAtValue, 1, 4, 1, 1)
-						grid.Attach(mw.alarmAtStartBtn, 2, 4, 1, 1)
-						grid.Attach(mw.alarmAtSetBtn, 3, 4, 1, 1)
-						grid.Attach(mw.alarmAtStopBtn, 4, 4, 1, 1)
+						grid.Attach(mw.alarmAtLabel, 0, 5, 1, 1)
+						grid.Attach(mw.alarmAtValue, 1, 5, 1, 1)
+						grid.Attach(mw.alarmAtStartBtn, 2, 5, 1, 1)
+						grid.Attach(mw.alarmAtSetBtn, 3, 5, 1, 1)
+						grid.Attach(mw.alarmAtStopBtn, 4, 5, 1, 1)
 
 						return true
 					}
@@ -428,13 +462,19 @@ func (mw *MainWindow) saveTimerAfterStopIfNeeded() {
 				}
 			}
 
-			if dialog := gtk.MessageDialogNew(mw.app.GetActiveWindow(), gtk.DIALOG_MODAL, gtk.MESSAGE_QUESTION, gtk.BUTTONS_YES_NO, "working time"); dialog != nil {
-				defer dialog.Destroy()
+			if id := mw.selectedCompanyID(); id != -1 {
+				descriptions, err := mw.store.Descriptions(id)
+				tr.IsOK(err)
+
+				if dialog := worktime.New(mw.app.GetActiveWindow(), h, m, descriptions, mw.store); dialog != nil {
+					defer dialog.Destroy()
 
-				dialog.FormatSecondaryMarkup(workedTimeFormat, h, m)
-				if dialog.Run() == gtk.RESPONSE_YES {
-					if id := mw.selectedCompanyID(); id != -1 {
-						if timer.NewWithData(int64(id), mw.workTimeStart.Unix(), mw.lastTime.Unix()).Save() {
+					dialog.ShowAll()
+					if dialog.Run() == gtk.RESPONSE_YES {
+						tm := timer.NewWithData(int64(id), mw.workTimeStart.Unix(), mw.lastTime.Unix())
+						tm.SetProjectID(int64(mw.selectedProjectID()))
+						tm.SetDescription(dialog.Description())
+						if err := mw.store.SaveTimerWithTags(tm, dialog.TagIDs()); tr.IsOK(err) {
 							return
 						}
 					}
@@ -547,12 +587,36 @@ func (mw *MainWindow) addCompanyHandler() {
 		dialog.ShowAll()
 		if dialog.Run() == gtk.RESPONSE_OK {
 			if c := dialog.Company(); c != nil && c.Valid() {
-				if c.Save() {
+				err := mw.store.SaveCompany(c)
+				if err == nil {
 					mw.populateCompanyCombo()
 					mw.selectCompanyWithID(c.ID())
 					return
 				}
-				tr.Error("can't save the company data")
+				tr.Error("can't save the company data: %v", err)
+				company.SaveFailure(mw.app.GetActiveWindow(), c, err)
+			}
+		}
+	}
+}
+
+func (mw *MainWindow) addProjectHandler() {
+	if id := mw.selectedCompanyID(); id != -1 {
+		if dialog := project.New(mw.app.GetActiveWindow(), id, nil); dialog != nil {
+			defer dialog.Destroy()
+
+			dialog.ShowAll()
+			if dialog.Run() == gtk.RESPONSE_OK {
+				if p := dialog.Project(); p != nil && p.Valid() {
+					err := mw.store.SaveProject(p)
+					if err == nil {
+						mw.populateProjectCombo()
+						mw.selectProjectWithID(p.ID())
+						return
+					}
+					tr.Error("can't save the project data: %v", err)
+					project.SaveFailure(mw.app.GetActiveWindow(), p, err)
+				}
 			}
 		}
 	}
@@ -626,7 +690,7 @@ func (mw *MainWindow) alarmAtStopHandler() {
 }
 
 func (mw *MainWindow) companiesActionHandler() {
-	if dialog := companies.New(mw.app.GetActiveWindow()); dialog != nil {
+	if dialog := companies.New(mw.app.GetActiveWindow(), mw.store); dialog != nil {
 		defer dialog.Destroy()
 
 		dialog.UpdateTable()
@@ -636,7 +700,7 @@ func (mw *MainWindow) companiesActionHandler() {
 }
 
 func (mw *MainWindow) statisticActionHandler() {
-	if dialog := statistic.New(mw.app.GetActiveWindow()); dialog != nil {
+	if dialog := statistic.New(mw.ctx, mw.app.GetActiveWindow(), mw.store); dialog != nil {
 		defer dialog.Destroy()
 
 		dialog.ShowAll()